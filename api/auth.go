@@ -5,13 +5,13 @@ import (
 	"net/http"
 )
 
-func (s *Server) authenticateUser(w http.ResponseWriter, u TrustedUser) error {
+func (s *Server) authenticateUser(w http.ResponseWriter, r *http.Request, u TrustedUser) error {
 	if u.Login == "" || u.Password == "" {
 		s.respondWithError(w, http.StatusBadRequest, "Login or password is empty")
 		return fmt.Errorf("empty credentials")
 	}
 
-	userExists, correctPassword, err := s.DB.CheckTrustedUser(u.Login)
+	userExists, correctPassword, err := s.db(r).CheckTrustedUser(u.Login)
 	if err != nil {
 		s.respondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return err
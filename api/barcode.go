@@ -0,0 +1,51 @@
+package api
+
+import "strings"
+
+// units is the set of accepted units of measure for products.
+var units = map[string]bool{
+	"pcs":  true,
+	"box":  true,
+	"pack": true,
+	"kg":   true,
+	"g":    true,
+	"l":    true,
+	"ml":   true,
+	"m":    true,
+}
+
+// validBarcode reports whether code is an EAN-8, UPC-A, EAN-13 or GTIN-14
+// with a correct check digit.
+func validBarcode(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		digit := int(c - '0')
+		// Weights alternate 3, 1, 3, ... starting next to the check digit.
+		if (len(code)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	check := code[len(code)-1]
+	if check < '0' || check > '9' {
+		return false
+	}
+	return (10-sum%10)%10 == int(check-'0')
+}
+
+// normalizeUnit lower-cases a unit of measure and reports whether it is known.
+func normalizeUnit(unit string) (string, bool) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	return unit, units[unit]
+}
@@ -0,0 +1,37 @@
+package api
+
+import "testing"
+
+func TestValidBarcode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"EAN-8", "73513537", true},
+		{"EAN-8 wrong check digit", "73513538", false},
+		{"EAN-8 other", "96385074", true},
+		{"UPC-A", "036000291452", true},
+		{"UPC-A wrong check digit", "036000291453", false},
+		{"EAN-13", "4006381333931", true},
+		{"EAN-13 wrong check digit", "4006381333932", false},
+		{"GTIN-14", "10012345678902", true},
+		{"GTIN-14 wrong check digit", "10012345678900", false},
+		{"GTIN-14 padded", "00012345600012", true},
+		{"empty", "", false},
+		{"too short", "1234567", false},
+		{"between lengths", "12345678901", false},
+		{"too long", "100123456789020", false},
+		{"letter", "4006381A33931", false},
+		{"letter as check digit", "400638133393X", false},
+		{"space", "4006381 33931", false},
+		{"sign", "+006381333931", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validBarcode(tt.code); got != tt.want {
+				t.Errorf("validBarcode(%q) = %t, want %t", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	codeBadRequest         = "bad_request"
+	codeUnauthorized       = "unauthorized"
+	codeNotFound           = "not_found"
+	codeConflict           = "conflict"
+	codeConstraint         = "constraint_violation"
+	codeInsufficientStock  = "insufficient_stock"
+	codeInternal           = "internal_error"
+	codeServiceUnavailable = "service_unavailable"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details document extended with a stable
+// error code and the id of the request that produced it.
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	Code       string `json:"code"`
+	RequestID  string `json:"request_id,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// statusCode is the default error code for responses that do not carry a
+// more specific one.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeBadRequest
+	case http.StatusUnauthorized:
+		return codeUnauthorized
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeConflict
+	case http.StatusUnprocessableEntity:
+		return codeConstraint
+	case http.StatusServiceUnavailable:
+		return codeServiceUnavailable
+	default:
+		return codeInternal
+	}
+}
+
+// errorStatus maps a database domain error onto an HTTP status and a stable
+// machine-readable code. Unknown errors are internal errors.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusConflict, codeConflict
+	case errors.Is(err, database.ErrInsufficientStock):
+		return http.StatusUnprocessableEntity, codeInsufficientStock
+	case errors.Is(err, database.ErrConstraint):
+		return http.StatusUnprocessableEntity, codeConstraint
+	case errors.Is(err, database.ErrInvalidArgument):
+		return http.StatusBadRequest, codeBadRequest
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+// respondWithDBError is the single place where errors returned by the
+// database layer become HTTP responses. Internal errors are logged and hidden
+// behind a generic message; domain errors expose their own message.
+func (s *Server) respondWithDBError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		s.Log.Error("unexpected database error",
+			slog.String("request_id", w.Header().Get(requestIDHeader)),
+			slog.String("error", err.Error()))
+		s.respondWithErrorCode(w, status, code, "Problem on the server side, please try again later", "")
+		return
+	}
+
+	var dbErr *database.Error
+	constraint := ""
+	if errors.As(err, &dbErr) {
+		constraint = dbErr.Constraint
+	}
+	s.respondWithErrorCode(w, status, code, err.Error(), constraint)
+}
+
+// respondWithErrorCode writes a problem+json document. The request id is
+// taken from the response header set by the requestID middleware.
+func (s *Server) respondWithErrorCode(w http.ResponseWriter, status int, code, message, constraint string) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     message,
+		Code:       code,
+		RequestID:  w.Header().Get(requestIDHeader),
+		Constraint: constraint,
+	})
+}
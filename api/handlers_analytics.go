@@ -7,9 +7,22 @@ import (
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// defaultSalesWindowDays is the number of days of sales that daily averages
+// are taken over when no days parameter is given.
+const defaultSalesWindowDays = 30
+
+// Defaults of the demand forecast query parameters.
+const (
+	defaultForecastHistoryDays = 90
+	defaultForecastHorizon     = 14
+	defaultForecastWindow      = 7
+	defaultForecastConfidence  = 0.95
+)
+
 func (s *Server) showSalesReport(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	defer r.Body.Close()
@@ -20,9 +33,39 @@ func (s *Server) showSalesReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reports, err := s.DB.FetchSalesReport()
+	from, err := parseReportBound(r, "from", false)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve sales report")
+		s.respondWithError(w, http.StatusBadRequest, "Invalid from format, use ISO8601 date or date-time format")
+		return
+	}
+	to, err := parseReportBound(r, "to", true)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid to format, use ISO8601 date or date-time format")
+		return
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		s.respondWithError(w, http.StatusBadRequest, "to must be after from")
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = database.SalesByProduct
+	}
+	if _, ok := salesReportKeys[groupBy]; !ok {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid group_by value, use day, week, month, product, category, customer or supplier")
+		return
+	}
+
+	includeDeleted, err := parseIncludeDeleted(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
+		return
+	}
+
+	reports, err := s.db(r).FetchSalesReport(from, to, groupBy, includeDeleted)
+	if err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
@@ -39,14 +82,14 @@ func (s *Server) showSalesReport(w http.ResponseWriter, r *http.Request) {
 		}
 	case "csv":
 		w.Header().Set("Content-Type", "text/csv")
-		if err := exportSalesReportCSV(w, reports); err != nil {
+		if err := exportSalesReportCSV(w, reports, groupBy); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
 			return
 		}
 	case "excel":
 		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"sales_report-%d.xlsx\"", time.Now().Unix()))
-		if err := exportSalesReportExcel(w, reports); err != nil {
+		if err := exportSalesReportExcel(w, reports, groupBy); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
 			return
 		}
@@ -54,23 +97,55 @@ func (s *Server) showSalesReport(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s accessed the sales report in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s accessed the sales report by %s in %s format", user, groupBy, format))
+}
+
+// salesReportKeys are the headers of the column a sales report export is
+// grouped by.
+var salesReportKeys = map[string]string{
+	database.SalesByDay:      "Day",
+	database.SalesByWeek:     "Week",
+	database.SalesByMonth:    "Month",
+	database.SalesByProduct:  "ProductID",
+	database.SalesByCategory: "CategoryID",
+	database.SalesByCustomer: "CustomerID",
+	database.SalesBySupplier: "SupplierID",
+}
+
+// salesReportKey is the value of the column a sales report row is grouped by.
+func salesReportKey(report database.SalesReport) string {
+	switch {
+	case report.Period != nil:
+		return report.Period.Format(time.DateOnly)
+	case report.ProductID != nil:
+		return formatOptionalID(report.ProductID)
+	case report.CategoryID != nil:
+		return formatOptionalID(report.CategoryID)
+	case report.CustomerID != nil:
+		return formatOptionalID(report.CustomerID)
+	default:
+		return formatOptionalID(report.SupplierID)
+	}
 }
 
-func exportSalesReportCSV(w http.ResponseWriter, reports []database.SalesReport) error {
+func exportSalesReportCSV(w http.ResponseWriter, reports []database.SalesReport, groupBy string) error {
 	cw := csv.NewWriter(w)
 	defer cw.Flush()
 
-	headers := []string{"OrderID", "TotalSales"}
+	headers := []string{salesReportKeys[groupBy], "Name", "Units", "TotalSales", "TotalCost", "Margin", "MarginPercent"}
 	if err := cw.Write(headers); err != nil {
 		return err
 	}
 
 	for _, report := range reports {
 		record := []string{
-			fmt.Sprintf("%d", report.ProductID),
+			salesReportKey(report),
 			report.Name,
+			fmt.Sprintf("%d", report.Units),
 			fmt.Sprintf("%.2f", report.TotalSales),
+			formatOptionalAmount(report.TotalCost),
+			formatOptionalAmount(report.Margin),
+			formatOptionalAmount(report.MarginPercent),
 		}
 		if err := cw.Write(record); err != nil {
 			return err
@@ -79,13 +154,13 @@ func exportSalesReportCSV(w http.ResponseWriter, reports []database.SalesReport)
 	return nil
 }
 
-func exportSalesReportExcel(w http.ResponseWriter, reports []database.SalesReport) error {
+func exportSalesReportExcel(w http.ResponseWriter, reports []database.SalesReport, groupBy string) error {
 	f := excelize.NewFile()
 	sheetName := "Sales Report"
 	f.NewSheet(sheetName)
 	f.SetActiveSheet(f.NewSheet(sheetName))
 
-	headers := []string{"OrderID", "TotalSales"}
+	headers := []string{salesReportKeys[groupBy], "Name", "Units", "TotalSales", "TotalCost", "Margin", "MarginPercent"}
 	for i, header := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, header)
@@ -93,9 +168,13 @@ func exportSalesReportExcel(w http.ResponseWriter, reports []database.SalesRepor
 
 	for i, report := range reports {
 		row := i + 2
-		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), report.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), salesReportKey(report))
 		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), report.Name)
-		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), report.TotalSales)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), report.Units)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), report.TotalSales)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), formatOptionalAmount(report.TotalCost))
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), formatOptionalAmount(report.Margin))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), formatOptionalAmount(report.MarginPercent))
 	}
 
 	if err := f.Write(w); err != nil {
@@ -108,7 +187,7 @@ func exportRequirementsReportCSV(w http.ResponseWriter, reports []database.Produ
 	cw := csv.NewWriter(w)
 	defer cw.Flush()
 
-	headers := []string{"ProductID", "AvgSold"}
+	headers := []string{"ProductID", "AvgSold", "AvgDailySold"}
 	if err := cw.Write(headers); err != nil {
 		return err
 	}
@@ -117,6 +196,7 @@ func exportRequirementsReportCSV(w http.ResponseWriter, reports []database.Produ
 		record := []string{
 			fmt.Sprintf("%d", report.ProductID),
 			fmt.Sprintf("%.2f", report.AvgSold),
+			fmt.Sprintf("%.2f", report.AvgDailySold),
 		}
 		if err := cw.Write(record); err != nil {
 			return err
@@ -131,7 +211,7 @@ func exportRequirementsReportExcel(w http.ResponseWriter, reports []database.Pro
 	f.NewSheet(sheetName)
 	f.SetActiveSheet(f.NewSheet(sheetName))
 
-	headers := []string{"ProductID", "AvgSold"}
+	headers := []string{"ProductID", "AvgSold", "AvgDailySold"}
 	for i, h := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, h)
@@ -140,6 +220,7 @@ func exportRequirementsReportExcel(w http.ResponseWriter, reports []database.Pro
 	for i, report := range reports {
 		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+2), report.ProductID)
 		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+2), fmt.Sprintf("%.2f", report.AvgSold))
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), fmt.Sprintf("%.2f", report.AvgDailySold))
 	}
 
 	if err := f.Write(w); err != nil {
@@ -158,9 +239,17 @@ func (s *Server) showRequirementsReport(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	reports, err := s.DB.FetchRequirementsReport()
+	days := int64(defaultSalesWindowDays)
+	if v := r.URL.Query().Get("days"); v != "" {
+		if days, err = strconv.ParseInt(v, 10, 64); err != nil || days <= 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid days value")
+			return
+		}
+	}
+
+	reports, err := s.db(r).FetchRequirementsReport(days)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve requirements report")
+		s.respondWithDBError(w, err)
 		return
 	}
 
@@ -193,5 +282,176 @@ func (s *Server) showRequirementsReport(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	s.Log.Info(fmt.Sprintf("User %s accessed the requirements report in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s accessed the requirements report in %s format", user, format))
+}
+
+func exportForecastCSV(w http.ResponseWriter, forecasts []database.ProductForecast) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"ProductID", "ProductName", "Model", "Date", "Quantity", "Lower", "Upper"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, forecast := range forecasts {
+		for _, point := range forecast.Forecast {
+			record := []string{
+				fmt.Sprintf("%d", forecast.ProductID),
+				forecast.ProductName,
+				forecast.Model,
+				point.Date.Format(time.DateOnly),
+				fmt.Sprintf("%.2f", point.Quantity),
+				fmt.Sprintf("%.2f", point.Lower),
+				fmt.Sprintf("%.2f", point.Upper),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportForecastExcel(w http.ResponseWriter, forecasts []database.ProductForecast) error {
+	f := excelize.NewFile()
+	sheetName := "Demand Forecast"
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"ProductID", "ProductName", "Model", "Date", "Quantity", "Lower", "Upper"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	row := 2
+	for _, forecast := range forecasts {
+		for _, point := range forecast.Forecast {
+			f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), forecast.ProductID)
+			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), forecast.ProductName)
+			f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), forecast.Model)
+			f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), point.Date.Format(time.DateOnly))
+			f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), fmt.Sprintf("%.2f", point.Quantity))
+			f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), fmt.Sprintf("%.2f", point.Lower))
+			f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), fmt.Sprintf("%.2f", point.Upper))
+			row++
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showDemandForecast(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+
+	var productID *int64
+	if v := query.Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	model := query.Get("model")
+	if model == "" {
+		model = database.ForecastHoltWinters
+	}
+	if model != database.ForecastHoltWinters && model != database.ForecastMovingAverage {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid model, use holt_winters or moving_average")
+		return
+	}
+
+	history := defaultForecastHistoryDays
+	if v := query.Get("history"); v != "" {
+		if history, err = strconv.Atoi(v); err != nil || history < 1 || history > 730 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid history value, use 1 to 730 days")
+			return
+		}
+	}
+
+	horizon := defaultForecastHorizon
+	if v := query.Get("horizon"); v != "" {
+		if horizon, err = strconv.Atoi(v); err != nil || horizon < 1 || horizon > 365 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid horizon value, use 1 to 365 days")
+			return
+		}
+	}
+
+	window := defaultForecastWindow
+	if v := query.Get("window"); v != "" {
+		if window, err = strconv.Atoi(v); err != nil || window < 1 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid window value")
+			return
+		}
+	}
+
+	confidence := defaultForecastConfidence
+	if v := query.Get("confidence"); v != "" {
+		if confidence, err = strconv.ParseFloat(v, 64); err != nil || confidence <= 0 || confidence >= 1 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid confidence value, use a level between 0 and 1")
+			return
+		}
+	}
+
+	includeHistory := false
+	if v := query.Get("include_history"); v != "" {
+		if includeHistory, err = strconv.ParseBool(v); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid include_history value")
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "excel" {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+
+	forecasts, err := s.db(r).ForecastDemand(productID, model, history, horizon, window, confidence, includeHistory)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(forecasts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := exportForecastCSV(w, forecasts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"demand_forecast-%d.xlsx\"", time.Now().Unix()))
+		if err := exportForecastExcel(w, forecasts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+
+	s.logger(r).Info(fmt.Sprintf("User %s accessed the %s demand forecast for %d days in %s format", user, model, horizon, format))
 }
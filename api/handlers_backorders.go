@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *Server) exportBackordersCSV(w io.Writer, backorders []database.Backorder) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"OrderID", "OrderDetailID", "CustomerID", "CustomerName", "ProductID", "ProductName", "WarehouseID", "Quantity", "Price", "OrderedAt", "Ahead", "Available", "OnOrder"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, b := range backorders {
+		record := []string{
+			fmt.Sprintf("%d", b.OrderID),
+			fmt.Sprintf("%d", b.OrderDetailID),
+			fmt.Sprintf("%d", b.CustomerID),
+			b.CustomerName,
+			fmt.Sprintf("%d", b.ProductID),
+			b.ProductName,
+			formatOptionalID(b.WarehouseID),
+			fmt.Sprintf("%d", b.Quantity),
+			fmt.Sprintf("%.2f", b.Price),
+			b.OrderedAt.Format(time.RFC3339),
+			fmt.Sprintf("%d", b.Ahead),
+			fmt.Sprintf("%d", b.Available),
+			fmt.Sprintf("%d", b.OnOrder),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportBackordersExcel(w io.Writer, backorders []database.Backorder) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Backorders-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"OrderID", "OrderDetailID", "CustomerID", "CustomerName", "ProductID", "ProductName", "WarehouseID", "Quantity", "Price", "OrderedAt", "Ahead", "Available", "OnOrder"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, b := range backorders {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), b.OrderID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), b.OrderDetailID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), b.CustomerID)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), b.CustomerName)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), b.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), b.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), formatOptionalID(b.WarehouseID))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), b.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), b.Price)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), b.OrderedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), b.Ahead)
+		f.SetCellValue(sheetName, fmt.Sprintf("L%d", row), b.Available)
+		f.SetCellValue(sheetName, fmt.Sprintf("M%d", row), b.OnOrder)
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showBackorderReport(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	var customerID *int64
+	if v := r.URL.Query().Get("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid customer_id value")
+			return
+		}
+		customerID = &id
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	backorders, err := s.db(r).ShowBackorders(productID, customerID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(backorders); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportBackordersCSV(w, backorders); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"backorder_report-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportBackordersExcel(w, backorders); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s accessed the backorder report in %s format", user, format))
+}
@@ -0,0 +1,601 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BinLocation struct {
+	ID           int64   `json:"id"`
+	WarehouseID  *int64  `json:"warehouse_id"`
+	Zone         *string `json:"zone"`
+	Aisle        *string `json:"aisle"`
+	Shelf        *string `json:"shelf"`
+	Bin          *string `json:"bin"`
+	PickSequence *int    `json:"pick_sequence"`
+}
+
+type PutAway struct {
+	BinLocationID *int64 `json:"bin_location_id"`
+	ProductID     *int64 `json:"product_id"`
+	Quantity      *int64 `json:"quantity"`
+}
+
+type BinStockMove struct {
+	ProductID         *int64 `json:"product_id"`
+	FromBinLocationID *int64 `json:"from_bin_location_id"`
+	ToBinLocationID   *int64 `json:"to_bin_location_id"`
+	Quantity          *int64 `json:"quantity"`
+}
+
+type PickListInput struct {
+	OrderID *int64         `json:"order_id"`
+	Serials []PickedSerial `json:"serials"`
+}
+
+type PickedSerial struct {
+	OrderDetailID *int64   `json:"order_detail_id"`
+	SerialNumbers []string `json:"serial_numbers"`
+}
+
+// maxBinPartLength is the size of the zone, aisle, shelf and bin columns.
+const maxBinPartLength = 16
+
+// trimBinParts trims the address parts of a bin and reports the first one
+// that is too long.
+func trimBinParts(b *BinLocation) string {
+	parts := map[string]*string{"Zone": b.Zone, "Aisle": b.Aisle, "Shelf": b.Shelf, "Bin": b.Bin}
+	for _, name := range []string{"Zone", "Aisle", "Shelf", "Bin"} {
+		part := parts[name]
+		if part == nil {
+			continue
+		}
+		*part = strings.TrimSpace(*part)
+		if len(*part) > maxBinPartLength {
+			return fmt.Sprintf("%s cannot be longer than %d characters", name, maxBinPartLength)
+		}
+	}
+	return ""
+}
+
+func valueOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (s *Server) addBinLocation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var b BinLocation
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if b.WarehouseID == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Warehouse ID is required")
+		return
+	}
+	if msg := trimBinParts(&b); msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	zone, aisle, shelf, bin := valueOrEmpty(b.Zone), valueOrEmpty(b.Aisle), valueOrEmpty(b.Shelf), valueOrEmpty(b.Bin)
+	if zone == "" && aisle == "" && shelf == "" && bin == "" {
+		s.respondWithError(w, http.StatusBadRequest, "At least one of zone, aisle, shelf and bin is required")
+		return
+	}
+
+	sequence := 0
+	if b.PickSequence != nil {
+		sequence = *b.PickSequence
+	}
+
+	id, err := s.db(r).AddBinLocation(*b.WarehouseID, zone, aisle, shelf, bin, sequence)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s created new bin location with id %d in warehouse %d", user, id, *b.WarehouseID))
+}
+
+func (s *Server) updateBinLocation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var b BinLocation
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if b.WarehouseID != nil {
+		s.respondWithError(w, http.StatusBadRequest, "A bin location cannot be moved to another warehouse")
+		return
+	}
+	if msg := trimBinParts(&b); msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.db(r).UpdateBinLocation(b.ID, b.Zone, b.Aisle, b.Shelf, b.Bin, b.PickSequence); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s updated information of bin location with id %d", user, b.ID))
+}
+
+func (s *Server) showBinLocations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	bins, err := s.db(r).ShowBinLocations(warehouseID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(bins); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on bin locations", user))
+}
+
+func (s *Server) exportBinStockCSV(w io.Writer, stock []database.BinStock) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"BinLocationID", "WarehouseID", "Code", "ProductID", "ProductName", "Quantity", "UpdatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, st := range stock {
+		record := []string{
+			fmt.Sprintf("%d", st.BinLocationID),
+			fmt.Sprintf("%d", st.WarehouseID),
+			st.Code,
+			fmt.Sprintf("%d", st.ProductID),
+			st.ProductName,
+			fmt.Sprintf("%d", st.Quantity),
+			st.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportBinStockExcel(w io.Writer, stock []database.BinStock) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("BinStock-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"BinLocationID", "WarehouseID", "Code", "ProductID", "ProductName", "Quantity", "UpdatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, st := range stock {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), st.BinLocationID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), st.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), st.Code)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), st.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), st.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), st.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), st.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showBinStock(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	var binLocationID *int64
+	if v := r.URL.Query().Get("bin_location_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid bin_location_id value")
+			return
+		}
+		binLocationID = &id
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	stock, err := s.db(r).ShowBinStock(warehouseID, productID, binLocationID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportBinStockCSV(w, stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"bin_stock-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportBinStockExcel(w, stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested bin stock in %s format", user, format))
+}
+
+func (s *Server) putAway(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var p PutAway
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if p.BinLocationID == nil || p.ProductID == nil || p.Quantity == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Bin location ID, product ID and quantity are required")
+		return
+	}
+	if *p.Quantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+
+	if err := s.db(r).PutAway(*p.BinLocationID, *p.ProductID, *p.Quantity); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s put away %d units of product %d into bin %d", user, *p.Quantity, *p.ProductID, *p.BinLocationID))
+}
+
+func (s *Server) moveBinStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var m BinStockMove
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if m.ProductID == nil || m.FromBinLocationID == nil || m.ToBinLocationID == nil || m.Quantity == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Product ID, both bin location IDs and quantity are required")
+		return
+	}
+	if *m.Quantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+	if *m.FromBinLocationID == *m.ToBinLocationID {
+		s.respondWithError(w, http.StatusBadRequest, "Source and destination bins must differ")
+		return
+	}
+
+	if err := s.db(r).MoveBinStock(*m.ProductID, *m.FromBinLocationID, *m.ToBinLocationID, *m.Quantity); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s moved %d units of product %d from bin %d to bin %d", user, *m.Quantity, *m.ProductID, *m.FromBinLocationID, *m.ToBinLocationID))
+}
+
+func (s *Server) exportPickListCSV(w io.Writer, list database.PickList) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"Step", "WarehouseID", "BinLocationID", "Code", "ProductID", "ProductName", "Quantity", "OrderDetailID"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, line := range list.Lines {
+		record := []string{
+			fmt.Sprintf("%d", line.Step),
+			fmt.Sprintf("%d", line.WarehouseID),
+			formatOptionalID(line.BinLocationID),
+			line.Code,
+			fmt.Sprintf("%d", line.ProductID),
+			line.ProductName,
+			fmt.Sprintf("%d", line.Quantity),
+			fmt.Sprintf("%d", line.OrderDetailID),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportPickListExcel(w io.Writer, list database.PickList) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("PickList-%d", list.OrderID)
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"Step", "WarehouseID", "BinLocationID", "Code", "ProductID", "ProductName", "Quantity", "OrderDetailID"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, line := range list.Lines {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), line.Step)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), line.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), formatOptionalID(line.BinLocationID))
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.Code)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), line.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), line.OrderDetailID)
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+// exportPickListPDF renders the pick list as a printable A4 sheet with a
+// check box per line.
+func (s *Server) exportPickListPDF(w io.Writer, list database.PickList) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, fmt.Sprintf("Pick List - Order #%d", list.OrderID))
+	pdf.Ln(12)
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(30, 6, "Printed:", "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, time.Now().Format(time.DateTime), "", 1, "L", false, 0, "")
+	if list.PickedAt != nil {
+		pdf.CellFormat(30, 6, "Picked:", "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, list.PickedAt.Format(time.DateTime), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	widths := []float64{12, 22, 34, 20, 72, 20, 10}
+	columns := []string{"Step", "Warehouse", "Bin", "Product", "Name", "Qty", ""}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, c := range columns {
+		pdf.CellFormat(widths[i], 7, c, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range list.Lines {
+		code := line.Code
+		if line.BinLocationID == nil {
+			code = "unbinned"
+		}
+		pdf.CellFormat(widths[0], 6, strconv.Itoa(line.Step), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[1], 6, strconv.FormatInt(line.WarehouseID, 10), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 6, tr(code), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 6, strconv.FormatInt(line.ProductID, 10), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, tr(line.ProductName), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[5], 6, strconv.FormatInt(line.Quantity, 10), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[6], 6, "", "1", 1, "C", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+func (s *Server) pickList(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idParam := r.URL.Query().Get("order_id")
+	if idParam == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Order ID is required")
+		return
+	}
+
+	orderID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid order ID format")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	list, err := s.db(r).PickList(orderID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportPickListCSV(w, list); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"pick_list-%d.xlsx\"", orderID))
+		if err := s.exportPickListExcel(w, list); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"pick_list-%d.pdf\"", orderID))
+		if err := s.exportPickListPDF(w, list); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate PDF file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested the pick list of order %d in %s format", user, orderID, format))
+}
+
+func (s *Server) confirmPickList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input PickListInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if input.OrderID == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Order ID is required")
+		return
+	}
+
+	serials := make(map[int64][]string)
+	for _, picked := range input.Serials {
+		if picked.OrderDetailID == nil {
+			s.respondWithError(w, http.StatusBadRequest, "Serial numbers need an order detail ID")
+			return
+		}
+		if _, ok := serials[*picked.OrderDetailID]; ok {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Order detail %d is listed more than once", *picked.OrderDetailID))
+			return
+		}
+		cleaned, msg := cleanSerials(picked.SerialNumbers)
+		if msg != "" {
+			s.respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+		serials[*picked.OrderDetailID] = cleaned
+	}
+
+	list, err := s.db(r).ConfirmPickList(*input.OrderID, serials, user)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s confirmed the pick list of order %d", user, *input.OrderID))
+}
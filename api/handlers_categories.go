@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Category struct {
+	ParentID *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+func (s *Server) addCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var c Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	if c.ParentID != nil && *c.ParentID > 0 {
+		if exists, err := s.db(r).CheckCategoryExists(*c.ParentID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("category with ID %d does not exist", *c.ParentID), "fk_parent_category")
+			return
+		}
+	}
+
+	id, err := s.db(r).AddCategory(c.ParentID, c.Name)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s created new category with name %s and id %d", user, c.Name, id))
+}
+
+func (s *Server) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var deleteStruct struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&deleteStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if err = s.db(r).DeleteCategory(deleteStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s removed category with id %d", user, deleteStruct.ID))
+}
+
+func (s *Server) updateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var updateStruct struct {
+		ID       int64   `json:"id"`
+		Name     *string `json:"name"`
+		ParentID *int64  `json:"parent_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&updateStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if updateStruct.Name != nil {
+		name := strings.TrimSpace(*updateStruct.Name)
+		updateStruct.Name = &name
+	}
+
+	if updateStruct.ParentID != nil && *updateStruct.ParentID < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Parent ID cannot be negative")
+		return
+	}
+
+	if updateStruct.ParentID != nil && *updateStruct.ParentID > 0 {
+		if exists, err := s.db(r).CheckCategoryExists(*updateStruct.ParentID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("category with ID %d does not exist", *updateStruct.ParentID), "fk_parent_category")
+			return
+		}
+	}
+
+	if err := s.db(r).UpdateCategory(updateStruct.ID, updateStruct.Name, updateStruct.ParentID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s updated information of category with id %d", user, updateStruct.ID))
+}
+
+func (s *Server) exportCategoriesCSV(w io.Writer, categories []database.Category) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"CategoryID", "ParentID", "Name", "Path", "Depth", "CreatedAt", "UpdatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		record := []string{
+			fmt.Sprintf("%d", category.CategoryID),
+			formatOptionalID(category.ParentID),
+			category.Name,
+			category.Path,
+			fmt.Sprintf("%d", category.Depth),
+			category.CreatedAt.Format(time.RFC3339),
+			category.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportCategoriesExcel(w io.Writer, categories []database.Category) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Categories-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"CategoryID", "ParentID", "Name", "Path", "Depth", "CreatedAt", "UpdatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, category := range categories {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+2), category.CategoryID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+2), formatOptionalID(category.ParentID))
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), category.Name)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), category.Path)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), category.Depth)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), category.CreatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), category.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showCategories(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	var limit *int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if lmt, err := strconv.Atoi(l); err == nil {
+			limit = &lmt
+		} else {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
+			return
+		}
+	}
+
+	categories, err := s.db(r).ShowCategories(limit)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(categories); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportCategoriesCSV(w, categories); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"categories-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportCategoriesExcel(w, categories); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on categories in %s format", user, format))
+}
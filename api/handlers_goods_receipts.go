@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GoodsReceiptLine struct {
+	PurchaseOrderLineID *int64     `json:"purchase_order_line_id"`
+	Quantity            *int64     `json:"quantity"`
+	DamagedQuantity     int64      `json:"damaged_quantity"`
+	LotNumber           string     `json:"lot_number"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	SerialNumbers       []string   `json:"serial_numbers"`
+}
+
+type GoodsReceipt struct {
+	PurchaseOrderID   *int64             `json:"purchase_order_id"`
+	Notes             string             `json:"notes"`
+	AllowOverDelivery bool               `json:"allow_over_delivery"`
+	Close             bool               `json:"close"`
+	Lines             []GoodsReceiptLine `json:"lines"`
+}
+
+func (s *Server) receiveGoods(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var gr GoodsReceipt
+	if err := json.NewDecoder(r.Body).Decode(&gr); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if gr.PurchaseOrderID == nil || len(gr.Lines) == 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	lines := make([]database.GoodsReceiptLine, 0, len(gr.Lines))
+	seen := make(map[int64]bool, len(gr.Lines))
+	for _, line := range gr.Lines {
+		if line.PurchaseOrderLineID == nil || line.Quantity == nil {
+			s.respondWithError(w, http.StatusBadRequest, "Every line needs a purchase order line ID and a quantity")
+			return
+		}
+		if *line.Quantity < 0 || line.DamagedQuantity < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Received quantities cannot be negative")
+			return
+		}
+		if line.DamagedQuantity > *line.Quantity {
+			s.respondWithError(w, http.StatusBadRequest, "Damaged quantity cannot exceed the delivered quantity")
+			return
+		}
+		if seen[*line.PurchaseOrderLineID] {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Purchase order line %d is listed more than once", *line.PurchaseOrderLineID))
+			return
+		}
+		seen[*line.PurchaseOrderLineID] = true
+
+		line.LotNumber = strings.TrimSpace(line.LotNumber)
+		if msg := validLot(line.LotNumber, line.ExpiresAt); msg != "" {
+			s.respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		serials, msg := cleanSerials(line.SerialNumbers)
+		if msg != "" {
+			s.respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		lines = append(lines, database.GoodsReceiptLine{
+			PurchaseOrderLineID: *line.PurchaseOrderLineID,
+			Quantity:            *line.Quantity,
+			DamagedQuantity:     line.DamagedQuantity,
+			LotNumber:           line.LotNumber,
+			ExpiresAt:           line.ExpiresAt,
+			SerialNumbers:       serials,
+		})
+	}
+
+	receipt, err := s.db(r).ReceiveGoods(*gr.PurchaseOrderID, lines, strings.TrimSpace(gr.Notes), gr.AllowOverDelivery, gr.Close, user)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s received goods %d against purchase order %d, new status %s", user, receipt.GoodsReceiptID, *gr.PurchaseOrderID, receipt.Status))
+}
+
+func (s *Server) exportGoodsReceiptsCSV(w io.Writer, receipts []database.GoodsReceipt) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"GoodsReceiptID", "PurchaseOrderID", "ReceivedAt", "PurchaseOrderLineID", "ProductID", "ProductName", "Quantity", "DamagedQuantity", "AcceptedQuantity", "LotNumber", "ExpiresAt", "Notes"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, receipt := range receipts {
+		for _, line := range receipt.Lines {
+			record := []string{
+				fmt.Sprintf("%d", receipt.GoodsReceiptID),
+				fmt.Sprintf("%d", receipt.PurchaseOrderID),
+				receipt.ReceivedAt.Format(time.RFC3339),
+				fmt.Sprintf("%d", line.PurchaseOrderLineID),
+				fmt.Sprintf("%d", line.ProductID),
+				line.ProductName,
+				fmt.Sprintf("%d", line.Quantity),
+				fmt.Sprintf("%d", line.DamagedQuantity),
+				fmt.Sprintf("%d", line.AcceptedQuantity),
+				line.LotNumber,
+				formatOptionalDate(line.ExpiresAt),
+				receipt.Notes,
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportGoodsReceiptsExcel(w io.Writer, receipts []database.GoodsReceipt) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("GoodsReceipts-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"GoodsReceiptID", "PurchaseOrderID", "ReceivedAt", "PurchaseOrderLineID", "ProductID", "ProductName", "Quantity", "DamagedQuantity", "AcceptedQuantity", "LotNumber", "ExpiresAt", "Notes"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	row := 2
+	for _, receipt := range receipts {
+		for _, line := range receipt.Lines {
+			f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), receipt.GoodsReceiptID)
+			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), receipt.PurchaseOrderID)
+			f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), receipt.ReceivedAt.Format(time.RFC3339))
+			f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.PurchaseOrderLineID)
+			f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.ProductID)
+			f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.ProductName)
+			f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), line.Quantity)
+			f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), line.DamagedQuantity)
+			f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), line.AcceptedQuantity)
+			f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), line.LotNumber)
+			f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), formatOptionalDate(line.ExpiresAt))
+			f.SetCellValue(sheetName, fmt.Sprintf("L%d", row), receipt.Notes)
+			row++
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showGoodsReceipts(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idParam := r.URL.Query().Get("purchase_order_id")
+	if idParam == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Purchase order ID is required")
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid purchase order ID format")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	receipts, err := s.db(r).ShowGoodsReceipts(purchaseOrderID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(receipts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportGoodsReceiptsCSV(w, receipts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"goods_receipts-%d.xlsx\"", purchaseOrderID))
+		if err := s.exportGoodsReceiptsExcel(w, receipts); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested goods receipts of purchase order %d in %s format", user, purchaseOrderID, format))
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxLotNumberLength is the size of the lot_number column.
+const maxLotNumberLength = 64
+
+// defaultExpiringDays is the window of the expiring lots report when no
+// days parameter is given.
+const defaultExpiringDays = 30
+
+// validLot checks the lot fields of an incoming stock change.
+func validLot(lotNumber string, expiresAt *time.Time) string {
+	if len(lotNumber) > maxLotNumberLength {
+		return fmt.Sprintf("Lot number cannot be longer than %d characters", maxLotNumberLength)
+	}
+	if expiresAt != nil && lotNumber == "" {
+		return "An expiry date needs a lot number"
+	}
+	return ""
+}
+
+func (s *Server) exportLotsCSV(w io.Writer, lots []database.Lot) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"LotID", "ProductID", "ProductName", "WarehouseID", "WarehouseName", "LotNumber", "ExpiresAt", "Quantity", "Expired"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		record := []string{
+			fmt.Sprintf("%d", l.LotID),
+			fmt.Sprintf("%d", l.ProductID),
+			l.ProductName,
+			fmt.Sprintf("%d", l.WarehouseID),
+			l.WarehouseName,
+			l.LotNumber,
+			formatOptionalDate(l.ExpiresAt),
+			fmt.Sprintf("%d", l.Quantity),
+			strconv.FormatBool(l.Expired),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportLotsExcel(w io.Writer, lots []database.Lot) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Lots-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"LotID", "ProductID", "ProductName", "WarehouseID", "WarehouseName", "LotNumber", "ExpiresAt", "Quantity", "Expired"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, l := range lots {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), l.LotID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), l.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), l.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), l.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), l.WarehouseName)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), l.LotNumber)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), formatOptionalDate(l.ExpiresAt))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), l.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), l.Expired)
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showLots(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	includeEmpty := false
+	if v := r.URL.Query().Get("include_empty"); v != "" {
+		if includeEmpty, err = strconv.ParseBool(v); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid include_empty value")
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	lots, err := s.db(r).ShowLots(productID, warehouseID, includeEmpty, nil)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportLotsCSV(w, lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"lots-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportLotsExcel(w, lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested lots in %s format", user, format))
+}
+
+func (s *Server) expiringLots(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	days := defaultExpiringDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		if days, err = strconv.Atoi(v); err != nil || days < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid days value")
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	lots, err := s.db(r).ShowLots(nil, warehouseID, false, &days)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportLotsCSV(w, lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"expiring_lots-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportLotsExcel(w, lots); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested lots expiring within %d days in %s format", user, days, format))
+}
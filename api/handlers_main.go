@@ -22,8 +22,23 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.authenticateUser(w, u); err != nil {
-		s.Log.Error("Authentication failed", slog.String("error", err.Error()))
+	if err := s.authenticateUser(w, r, u); err != nil {
+		s.logger(r).Error("Authentication failed", slog.String("error", err.Error()))
 	}
-	s.Log.Info(fmt.Sprintf("User: %s created new token", u.Login))
+	s.logger(r).Info(fmt.Sprintf("User: %s created new token", u.Login))
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.respondWithStatus(w, http.StatusOK, "alive")
+}
+
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.DB.IsReady() {
+		w.Header().Set("Retry-After", "5")
+		s.respondWithStatus(w, http.StatusServiceUnavailable, "waiting for database")
+		return
+	}
+	s.respondWithStatus(w, http.StatusOK, "ready")
 }
@@ -17,6 +17,10 @@ type OrderInformationInput struct {
 	ProductID  *int64   `json:"product_id"`
 	Quantity   *int64   `json:"quantity"`
 	Price      *float64 `json:"price"`
+
+	WarehouseID *int64   `json:"warehouse_id"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
 }
 
 type OrderDetailInput struct {
@@ -62,38 +66,48 @@ func (s *Server) addOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if exists, err := s.DB.CheckCustomerExists(*o.CustomerID); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if (o.Latitude == nil) != (o.Longitude == nil) {
+		s.respondWithError(w, http.StatusBadRequest, "Latitude and longitude must be given together")
+		return
+	}
+
+	if exists, err := s.db(r).CheckCustomerExists(*o.CustomerID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("customer with id %d  not exist", *o.CustomerID))
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("customer with id %d not exist", *o.CustomerID), "fk_customer")
 		return
 	}
 
-	if exists, err := s.DB.CheckProductExists(*o.ProductID); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if exists, err := s.db(r).CheckProductExists(*o.ProductID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("product with id %d not exist", *o.ProductID))
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("product with id %d not exist", *o.ProductID), "fk_products")
 		return
 	}
 
-	if can, err := s.DB.CheckProductAvailability(*o.ProductID, *o.Quantity); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if can, err := s.db(r).CheckProductAvailability(*o.ProductID, *o.Quantity); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !can {
-		s.respondWithError(w, http.StatusBadRequest, "Don't have that amount of product in stock")
-		return
+		if backorders, err := s.db(r).AllowsBackorders(*o.ProductID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !backorders {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeInsufficientStock, "Don't have that amount of product in stock", "")
+			return
+		}
 	}
 
-	idOrder, idOrderDetail, err := s.DB.AddOrder(*o.CustomerID, *o.ProductID, *o.Quantity, *o.Price)
+	idOrder, idOrderDetail, status, err := s.db(r).AddOrder(*o.CustomerID, *o.ProductID, *o.Quantity, *o.Price, o.WarehouseID, o.Latitude, o.Longitude, user)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Problem when adding a new order")
+		s.respondWithDBError(w, err)
 		return
 	}
 
-	s.respondNewOrder(w, idOrder, idOrderDetail)
-	s.Log.Info(fmt.Sprintf("User %s created new order with order id %d and order detail id %d", user, idOrder, idOrderDetail))
+	s.respondNewOrder(w, idOrder, idOrderDetail, status)
+	s.logger(r).Info(fmt.Sprintf("User %s created new %s order with order id %d and order detail id %d", user, status, idOrder, idOrderDetail))
 }
 
 func (s *Server) refundOrder(w http.ResponseWriter, r *http.Request) {
@@ -120,22 +134,33 @@ func (s *Server) refundOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if exists, err := s.DB.CheckOrderExists(*input.OrderID); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if exists, err := s.db(r).CheckOrderExists(*input.OrderID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Order with ID %d does not exist", *input.OrderID))
+		s.respondWithErrorCode(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("Order with ID %d does not exist", *input.OrderID), "")
+		return
+	}
+
+	if status, err := s.db(r).GetOrderStatus(*input.OrderID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if status == database.OrderBackordered {
+		s.respondWithError(w, http.StatusBadRequest, "A backordered order has not shipped, cancel it instead")
+		return
+	} else if status == database.OrderRefunded || status == database.OrderCancelled {
+		s.respondWithErrorCode(w, http.StatusConflict, codeConflict, fmt.Sprintf("Order with ID %d is already %s", *input.OrderID, status), "")
 		return
 	}
 
-	err = s.DB.RefundOrder(*input.OrderID)
+	err = s.db(r).RefundOrder(*input.OrderID, user)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process refund for order %d", *input.OrderID))
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondWithStatus(w, http.StatusOK, fmt.Sprintf("Refund processed successfully for order %d", *input.OrderID))
-	s.Log.Info(fmt.Sprintf("User %s make refund order with ID %d", user, *input.OrderID))
+	s.logger(r).Info(fmt.Sprintf("User %s make refund order with ID %d", user, *input.OrderID))
 }
 
 func (s *Server) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
@@ -159,24 +184,21 @@ func (s *Server) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentStatus, err := s.DB.GetOrderStatus(*input.OrderID)
-	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve current order status")
+	switch *input.Status {
+	case database.OrderAccepted, database.OrderShipped, database.OrderDelivered, database.OrderCancelled:
+	case database.OrderRefunded, "refund":
+		s.respondWithError(w, http.StatusBadRequest, "Use a special command to process refunds")
 		return
-	}
-
-	if currentStatus == "refunded" || currentStatus == "refund" {
-		s.respondWithError(w, http.StatusBadRequest, "Updating status from 'refund' is not safe and not allowed")
+	case database.OrderNew, database.OrderBackordered:
+		s.respondWithError(w, http.StatusBadRequest, "Orders are new or backordered only when they are placed")
 		return
-	}
-
-	if *input.Status == "refunded" || *input.Status == "refund" {
-		s.respondWithError(w, http.StatusBadRequest, "Use a special command to process refunds")
+	default:
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown order status %s", *input.Status))
 		return
 	}
 
-	if err = s.DB.UpdateStatusOrder(*input.OrderID, *input.Status); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to update order status")
+	if err = s.db(r).UpdateStatusOrder(*input.OrderID, *input.Status, user); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
@@ -253,26 +275,24 @@ func (s *Server) showCustomerOrders(w http.ResponseWriter, r *http.Request) {
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	orders, err := s.DB.ShowByCustomerOrders(customerID, limit)
+	orders, next, err := s.db(r).ShowByCustomerOrders(customerID, page)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		s.respondWithDBError(w, err)
 		return
 	}
 
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(orders); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -293,7 +313,7 @@ func (s *Server) showCustomerOrders(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested orders for customer %d in %s format", user, customerID, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested orders for customer %d in %s format", user, customerID, format))
 }
 
 func (s *Server) showOrdersByDate(w http.ResponseWriter, r *http.Request) {
@@ -326,28 +346,26 @@ func (s *Server) showOrdersByDate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	orders, err := s.DB.ShowByDateOrders(startDate, endDate, limit)
+	orders, next, err := s.db(r).ShowByDateOrders(startDate, endDate, page)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		s.respondWithDBError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(orders); err != nil {
+	setNextLink(w, r, next)
+
+	if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
 		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 		return
 	}
 
-	s.Log.Info(fmt.Sprintf("User %s requested orders between %s and %s", user, startDate.Format(time.RFC3339), endDate.Format(time.RFC3339)))
+	s.logger(r).Info(fmt.Sprintf("User %s requested orders between %s and %s", user, startDate.Format(time.RFC3339), endDate.Format(time.RFC3339)))
 }
 
 func (s *Server) showOrdersByStatus(w http.ResponseWriter, r *http.Request) {
@@ -366,28 +384,26 @@ func (s *Server) showOrdersByStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	orders, err := s.DB.ShowByStatusOrders(status, limit)
+	orders, next, err := s.db(r).ShowByStatusOrders(status, page)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		s.respondWithDBError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(orders); err != nil {
+	setNextLink(w, r, next)
+
+	if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
 		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 		return
 	}
 
-	s.Log.Info(fmt.Sprintf("User %s requested orders with status '%s'", user, status))
+	s.logger(r).Info(fmt.Sprintf("User %s requested orders with status '%s'", user, status))
 }
 
 func exportOrdersFullCSV(w http.ResponseWriter, orders []database.Order) error {
@@ -464,26 +480,24 @@ func (s *Server) showCustomerOrdersFull(w http.ResponseWriter, r *http.Request)
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	orders, err := s.DB.ShowCustomerOrders(customerID, limit)
+	orders, next, err := s.db(r).ShowCustomerOrders(customerID, page)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		s.respondWithDBError(w, err)
 		return
 	}
 
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(orders); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -505,7 +519,7 @@ func (s *Server) showCustomerOrdersFull(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	s.Log.Info(fmt.Sprintf("User %s requested orders for customer %d in %s format", user, customerID, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested orders for customer %d in %s format", user, customerID, format))
 }
 
 func exportOrderDetailsCSV(w http.ResponseWriter, details []database.OrderDetail) error {
@@ -593,9 +607,9 @@ func (s *Server) showOrderDetails(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	orderDetails, err := s.DB.ShowOrderDetails(orderID, limit)
+	orderDetails, err := s.db(r).ShowOrderDetails(orderID, limit)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve order details")
+		s.respondWithDBError(w, err)
 		return
 	}
 
@@ -623,7 +637,7 @@ func (s *Server) showOrderDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Log.Info(fmt.Sprintf("User %s requested order details for order %d in %s format", user, orderID, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested order details for order %d in %s format", user, orderID, format))
 }
 
 func (s *Server) showOrdersFullByStatus(w http.ResponseWriter, r *http.Request) {
@@ -642,22 +656,20 @@ func (s *Server) showOrdersFullByStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var limit *int
-	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
-		limitParsed, err := strconv.Atoi(limitParam)
-		if err != nil {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
-		limit = &limitParsed
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	orders, err := s.DB.ShowByStatusFullOrders(status, limit)
+	orders, next, err := s.db(r).ShowByStatusFullOrders(status, page)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve orders")
+		s.respondWithDBError(w, err)
 		return
 	}
 
+	setNextLink(w, r, next)
+
 	format := r.URL.Query().Get("format")
 	if format == "" {
 		format = "json"
@@ -665,7 +677,7 @@ func (s *Server) showOrdersFullByStatus(w http.ResponseWriter, r *http.Request)
 
 	switch format {
 	case "json":
-		if err := json.NewEncoder(w).Encode(orders); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -686,5 +698,5 @@ func (s *Server) showOrdersFullByStatus(w http.ResponseWriter, r *http.Request)
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested orders with status '%s' in %s format", user, status, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested orders with status '%s' in %s format", user, status, format))
 }
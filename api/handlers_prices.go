@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *Server) schedulePriceChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var scheduleStruct struct {
+		ProductID     int64      `json:"product_id"`
+		Price         *float64   `json:"price"`
+		EffectiveFrom *time.Time `json:"effective_from"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&scheduleStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if scheduleStruct.ProductID <= 0 || scheduleStruct.Price == nil || scheduleStruct.EffectiveFrom == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	if *scheduleStruct.Price < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "The price of the product cannot be negative")
+		return
+	}
+
+	if !scheduleStruct.EffectiveFrom.After(time.Now()) {
+		s.respondWithError(w, http.StatusBadRequest, "Effective date must be in the future, use /update_product to change the price now")
+		return
+	}
+
+	id, err := s.db(r).SchedulePriceChange(scheduleStruct.ProductID, *scheduleStruct.Price, *scheduleStruct.EffectiveFrom)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s scheduled price %.2f for product with id %d from %s", user, *scheduleStruct.Price, scheduleStruct.ProductID, scheduleStruct.EffectiveFrom.Format(time.RFC3339)))
+}
+
+func (s *Server) cancelPriceChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var cancelStruct struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&cancelStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if err = s.db(r).CancelPriceChange(cancelStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s cancelled scheduled price change with id %d", user, cancelStruct.ID))
+}
+
+func (s *Server) exportPriceHistoryCSV(w io.Writer, prices []database.ProductPrice) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"PriceID", "ProductID", "Price", "EffectiveFrom", "EffectiveTo", "AppliedAt", "CreatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, price := range prices {
+		record := []string{
+			fmt.Sprintf("%d", price.PriceID),
+			fmt.Sprintf("%d", price.ProductID),
+			fmt.Sprintf("%.2f", price.Price),
+			price.EffectiveFrom.Format(time.RFC3339),
+			formatOptionalTime(price.EffectiveTo),
+			formatOptionalTime(price.AppliedAt),
+			price.CreatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportPriceHistoryExcel(w io.Writer, prices []database.ProductPrice) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Prices-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"PriceID", "ProductID", "Price", "EffectiveFrom", "EffectiveTo", "AppliedAt", "CreatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, price := range prices {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+2), price.PriceID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+2), price.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), price.Price)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), price.EffectiveFrom.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), formatOptionalTime(price.EffectiveTo))
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), formatOptionalTime(price.AppliedAt))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), price.CreatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) productPriceHistory(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	productIDParam := r.URL.Query().Get("product_id")
+	if productIDParam == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Product ID is required")
+		return
+	}
+
+	productID, err := strconv.ParseInt(productIDParam, 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid product ID format")
+		return
+	}
+
+	var from, to *time.Time
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid from format, use ISO8601 format")
+			return
+		}
+		from = &t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid to format, use ISO8601 format")
+			return
+		}
+		to = &t
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if exists, err := s.db(r).CheckProductExists(productID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithDBError(w, database.ErrNoProductFound)
+		return
+	}
+
+	prices, err := s.db(r).ProductPriceHistory(productID, from, to)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(prices); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportPriceHistoryCSV(w, prices); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"prices-%d-%d.xlsx\"", productID, time.Now().Unix()))
+		if err := s.exportPriceHistoryExcel(w, prices); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested price history of product with id %d in %s format", user, productID, format))
+}
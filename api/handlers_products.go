@@ -3,25 +3,32 @@ package api
 import (
 	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Product struct {
-	SupplierID  *int64    `json:"supplier_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       *float64  `json:"price"`
-	Quantity    *int64    `json:"quantity"`
-	Category    string    `json:"category"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	SupplierID      *int64    `json:"supplier_id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Price           *float64  `json:"price"`
+	Quantity        *int64    `json:"quantity"`
+	CategoryID      *int64    `json:"category_id"`
+	SKU             string    `json:"sku"`
+	Barcode         string    `json:"barcode"`
+	Unit            string    `json:"unit_of_measure"`
+	PackSize        *int64    `json:"pack_size"`
+	TrackLots       bool      `json:"track_lots"`
+	TrackSerials    bool      `json:"track_serials"`
+	AllowBackorders bool      `json:"allow_backorders"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 func (s *Server) addProduct(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +47,7 @@ func (s *Server) addProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if p.SupplierID == nil || p.Name == "" || p.Description == "" || p.Price == nil || p.Quantity == nil || p.Category == "" {
+	if p.SupplierID == nil || p.Name == "" || p.Description == "" || p.Price == nil || p.Quantity == nil || p.CategoryID == nil {
 		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
 		return
 	}
@@ -55,31 +62,67 @@ func (s *Server) addProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if exists, err := s.DB.IDProduct(p.Name); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Barcode = strings.TrimSpace(p.Barcode)
+	if p.Barcode != "" && !validBarcode(p.Barcode) {
+		s.respondWithError(w, http.StatusBadRequest, "Barcode must be a valid EAN-8, UPC-A, EAN-13 or GTIN-14 code")
+		return
+	}
+
+	if p.Unit == "" {
+		p.Unit = "pcs"
+	}
+	unit, ok := normalizeUnit(p.Unit)
+	if !ok {
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown unit of measure %s", p.Unit))
+		return
+	}
+
+	packSize := int64(1)
+	if p.PackSize != nil {
+		packSize = *p.PackSize
+	}
+	if packSize <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Pack size must be positive")
+		return
+	}
+	if p.TrackSerials && *p.Quantity > 0 {
+		s.respondWithError(w, http.StatusBadRequest, "A product tracked by serial number is added without stock")
+		return
+	}
+
+	if exists, err := s.db(r).IDProduct(p.Name); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("product with name %s exist", p.Name))
+		s.respondWithErrorCode(w, http.StatusConflict, codeConflict, fmt.Sprintf("product with name %s exist", p.Name), "products_name_key")
 		return
 	}
 
-	if exists, err := s.DB.CheckSupplierExists(*p.SupplierID); err != nil {
-		s.Log.Error("server side -> addProduct() -> s.DB.CheckSupplierExists()", err)
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if exists, err := s.db(r).CheckSupplierExists(*p.SupplierID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("supplier with ID %d does not exist", *p.SupplierID))
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("supplier with ID %d does not exist", *p.SupplierID), "fk_suppliers")
 		return
 	}
 
-	id, err := s.DB.AddProduct(*p.SupplierID, p.Name, p.Description, *p.Price, *p.Quantity, p.Category)
+	if exists, err := s.db(r).CheckCategoryExists(*p.CategoryID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("category with ID %d does not exist", *p.CategoryID), "fk_categories")
+		return
+	}
+
+	id, err := s.db(r).AddProduct(*p.SupplierID, p.Name, p.Description, *p.Price, *p.Quantity, *p.CategoryID, p.SKU, p.Barcode, unit, packSize, p.TrackLots, p.TrackSerials, p.AllowBackorders, user)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Problem when adding a new product")
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondWithNew(w, id)
-	s.Log.Info(fmt.Sprintf("User %s created new product with name %s and id %d", user, p.Name, id))
+	s.logger(r).Info(fmt.Sprintf("User %s created new product with name %s and id %d", user, p.Name, id))
 }
 
 func (s *Server) deleteProduct(w http.ResponseWriter, r *http.Request) {
@@ -101,16 +144,41 @@ func (s *Server) deleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.DB.DeleteProduct(deleteStruct.ID); errors.Is(err, database.ErrNoProductFound) {
-		s.respondWithError(w, http.StatusBadRequest, "No product found with the provided ID")
+	if err = s.db(r).DeleteProduct(deleteStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s removed product with id %d", user, deleteStruct.ID))
+}
+
+func (s *Server) restoreProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var restoreStruct struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&restoreStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
 		return
-	} else if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	}
+
+	if err = s.db(r).RestoreProduct(restoreStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondNoContent(w)
-	s.Log.Info(fmt.Sprintf("User %s removed product with id %d", user, deleteStruct.ID))
+	s.logger(r).Info(fmt.Sprintf("User %s restored product with id %d", user, restoreStruct.ID))
 }
 
 func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request) {
@@ -124,13 +192,21 @@ func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var updateStruct struct {
-		ID          int64    `json:"id"`
-		SupplierID  *int64   `json:"supplier_id"`
-		Name        *string  `json:"name"`
-		Description *string  `json:"description"`
-		Price       *float64 `json:"price"`
-		Quantity    *int64   `json:"quantity"`
-		Category    *string  `json:"category"`
+		ID              int64    `json:"id"`
+		SupplierID      *int64   `json:"supplier_id"`
+		Name            *string  `json:"name"`
+		Description     *string  `json:"description"`
+		Price           *float64 `json:"price"`
+		Quantity        *int64   `json:"quantity"`
+		WarehouseID     *int64   `json:"warehouse_id"`
+		CategoryID      *int64   `json:"category_id"`
+		SKU             *string  `json:"sku"`
+		Barcode         *string  `json:"barcode"`
+		Unit            *string  `json:"unit_of_measure"`
+		PackSize        *int64   `json:"pack_size"`
+		TrackLots       *bool    `json:"track_lots"`
+		TrackSerials    *bool    `json:"track_serials"`
+		AllowBackorders *bool    `json:"allow_backorders"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateStruct); err != nil {
@@ -148,6 +224,34 @@ func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if updateStruct.SKU != nil {
+		sku := strings.TrimSpace(*updateStruct.SKU)
+		updateStruct.SKU = &sku
+	}
+
+	if updateStruct.Barcode != nil {
+		barcode := strings.TrimSpace(*updateStruct.Barcode)
+		if barcode != "" && !validBarcode(barcode) {
+			s.respondWithError(w, http.StatusBadRequest, "Barcode must be a valid EAN-8, UPC-A, EAN-13 or GTIN-14 code")
+			return
+		}
+		updateStruct.Barcode = &barcode
+	}
+
+	if updateStruct.Unit != nil && *updateStruct.Unit != "" {
+		unit, ok := normalizeUnit(*updateStruct.Unit)
+		if !ok {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown unit of measure %s", *updateStruct.Unit))
+			return
+		}
+		updateStruct.Unit = &unit
+	}
+
+	if updateStruct.PackSize != nil && *updateStruct.PackSize <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Pack size must be positive")
+		return
+	}
+
 	if updateStruct.Name == nil {
 		s.respondWithError(w, http.StatusBadRequest, "Name cannot be empty")
 		return
@@ -158,41 +262,47 @@ func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if exists, err := s.DB.IDProduct(*updateStruct.Name); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if exists, err := s.db(r).IDProduct(*updateStruct.Name); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("product with name %s exist", *updateStruct.Name))
+		s.respondWithErrorCode(w, http.StatusConflict, codeConflict, fmt.Sprintf("product with name %s exist", *updateStruct.Name), "products_name_key")
 		return
 	}
 
-	if exists, err := s.DB.CheckSupplierExists(*updateStruct.SupplierID); err != nil {
-		s.Log.Error("server side -> addProduct() -> s.DB.CheckSupplierExists()", err)
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if exists, err := s.db(r).CheckSupplierExists(*updateStruct.SupplierID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if !exists {
-		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("supplier with ID %d does not exist", *updateStruct.SupplierID))
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("supplier with ID %d does not exist", *updateStruct.SupplierID), "fk_suppliers")
 		return
 	}
 
-	if err := s.DB.UpdateProduct(updateStruct.ID, updateStruct.Name, updateStruct.SupplierID, updateStruct.Description, updateStruct.Price, updateStruct.Quantity, updateStruct.Category); err != nil {
-		if errors.Is(err, database.ErrNoProductFound) {
-			s.respondWithError(w, http.StatusBadRequest, "No product found with the provided ID")
-		} else {
-			s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if updateStruct.CategoryID != nil {
+		if exists, err := s.db(r).CheckCategoryExists(*updateStruct.CategoryID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("category with ID %d does not exist", *updateStruct.CategoryID), "fk_categories")
+			return
 		}
+	}
+
+	if err := s.db(r).UpdateProduct(updateStruct.ID, updateStruct.Name, updateStruct.SupplierID, updateStruct.Description, updateStruct.Price, updateStruct.Quantity, updateStruct.WarehouseID, updateStruct.CategoryID,
+		updateStruct.SKU, updateStruct.Barcode, updateStruct.Unit, updateStruct.PackSize, updateStruct.TrackLots, updateStruct.TrackSerials, updateStruct.AllowBackorders, user); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondNoContent(w)
-	s.Log.Info(fmt.Sprintf("User %s updated information of product with id %d", user, updateStruct.ID))
+	s.logger(r).Info(fmt.Sprintf("User %s updated information of product with id %d", user, updateStruct.ID))
 }
 
 func (s *Server) exportProductsCSV(w io.Writer, products []database.Product) error {
 	cw := csv.NewWriter(w)
 	defer cw.Flush()
 
-	headers := []string{"ProductID", "SupplierID", "Name", "Description", "Price", "Quantity", "Category", "CreatedAt", "UpdatedAt"}
+	headers := []string{"ProductID", "SupplierID", "Name", "Description", "Price", "Quantity", "CategoryID", "Category", "SKU", "Barcode", "Unit", "PackSize", "TrackLots", "TrackSerials", "AllowBackorders", "ReorderPoint", "ReorderQuantity", "SafetyStock", "CreatedAt", "UpdatedAt", "DeletedAt"}
 	if err := cw.Write(headers); err != nil {
 		return err
 	}
@@ -205,9 +315,21 @@ func (s *Server) exportProductsCSV(w io.Writer, products []database.Product) err
 			product.Description,
 			fmt.Sprintf("%.2f", product.Price),
 			fmt.Sprintf("%d", product.Quantity),
+			formatOptionalID(product.CategoryID),
 			product.Category,
+			product.SKU,
+			product.Barcode,
+			product.Unit,
+			fmt.Sprintf("%d", product.PackSize),
+			strconv.FormatBool(product.TrackLots),
+			strconv.FormatBool(product.TrackSerials),
+			strconv.FormatBool(product.AllowBackorders),
+			formatOptionalID(product.ReorderPoint),
+			formatOptionalID(product.ReorderQuantity),
+			fmt.Sprintf("%d", product.SafetyStock),
 			product.CreatedAt.Format(time.RFC3339),
 			product.UpdatedAt.Format(time.RFC3339),
+			formatOptionalTime(product.DeletedAt),
 		}
 		if err := cw.Write(record); err != nil {
 			return err
@@ -222,7 +344,7 @@ func (s *Server) exportProductsExcel(w io.Writer, products []database.Product) e
 	f.NewSheet(sheetName)
 	f.SetActiveSheet(f.NewSheet(sheetName))
 
-	headers := []string{"ProductID", "SupplierID", "Name", "Description", "Price", "Quantity", "Category", "CreatedAt", "UpdatedAt"}
+	headers := []string{"ProductID", "SupplierID", "Name", "Description", "Price", "Quantity", "CategoryID", "Category", "SKU", "Barcode", "Unit", "PackSize", "TrackLots", "TrackSerials", "AllowBackorders", "ReorderPoint", "ReorderQuantity", "SafetyStock", "CreatedAt", "UpdatedAt", "DeletedAt"}
 	for i, h := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, h)
@@ -235,9 +357,21 @@ func (s *Server) exportProductsExcel(w io.Writer, products []database.Product) e
 		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), product.Description)
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), fmt.Sprintf("%.2f", product.Price))
 		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), product.Quantity)
-		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), product.Category)
-		f.SetCellValue(sheetName, fmt.Sprintf("H%d", i+2), product.CreatedAt.Format(time.RFC3339))
-		f.SetCellValue(sheetName, fmt.Sprintf("I%d", i+2), product.UpdatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), formatOptionalID(product.CategoryID))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", i+2), product.Category)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", i+2), product.SKU)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", i+2), product.Barcode)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", i+2), product.Unit)
+		f.SetCellValue(sheetName, fmt.Sprintf("L%d", i+2), product.PackSize)
+		f.SetCellValue(sheetName, fmt.Sprintf("M%d", i+2), product.TrackLots)
+		f.SetCellValue(sheetName, fmt.Sprintf("N%d", i+2), product.TrackSerials)
+		f.SetCellValue(sheetName, fmt.Sprintf("O%d", i+2), product.AllowBackorders)
+		f.SetCellValue(sheetName, fmt.Sprintf("P%d", i+2), formatOptionalID(product.ReorderPoint))
+		f.SetCellValue(sheetName, fmt.Sprintf("Q%d", i+2), formatOptionalID(product.ReorderQuantity))
+		f.SetCellValue(sheetName, fmt.Sprintf("R%d", i+2), product.SafetyStock)
+		f.SetCellValue(sheetName, fmt.Sprintf("S%d", i+2), product.CreatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("T%d", i+2), product.UpdatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("U%d", i+2), formatOptionalTime(product.DeletedAt))
 	}
 
 	if err := f.Write(w); err != nil {
@@ -258,26 +392,36 @@ func (s *Server) showInStockProducts(w http.ResponseWriter, r *http.Request) {
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	products, err := s.DB.ShowNotEmptyQuantityProducts(limit)
+	includeDeleted, err := parseIncludeDeleted(r)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve products")
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
 		return
 	}
 
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	products, next, err := s.db(r).ShowNotEmptyQuantityProducts(page, includeDeleted, warehouseID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(products); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: products, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -298,7 +442,7 @@ func (s *Server) showInStockProducts(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested information on the products in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on the products in %s format", user, format))
 }
 
 func (s *Server) showCategoryProducts(w http.ResponseWriter, r *http.Request) {
@@ -308,37 +452,63 @@ func (s *Server) showCategoryProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var categoryID *int64
+	if c := r.URL.Query().Get("category_id"); c != "" {
+		id, err := strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid category_id value")
+			return
+		}
+		categoryID = &id
+	}
+
 	category := r.URL.Query().Get("category")
-	if category == "" {
-		s.respondWithError(w, http.StatusBadRequest, "Category parameter is required")
+	if categoryID == nil && category == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Category or category_id parameter is required")
 		return
 	}
+	label := category
+	if categoryID != nil {
+		label = fmt.Sprintf("%d", *categoryID)
+	}
+
+	descendants := false
+	if d := r.URL.Query().Get("include_descendants"); d != "" {
+		if descendants, err = strconv.ParseBool(d); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid include_descendants value")
+			return
+		}
+	}
 
 	format := r.URL.Query().Get("format")
 	if format == "" {
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	products, err := s.DB.ShowByCategoryProducts(category, limit)
+	includeDeleted, err := parseIncludeDeleted(r)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve products for category '%s'", category))
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
 		return
 	}
 
+	products, next, err := s.db(r).ShowByCategoryProducts(categoryID, category, descendants, page, includeDeleted)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(products); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: products, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -350,7 +520,7 @@ func (s *Server) showCategoryProducts(w http.ResponseWriter, r *http.Request) {
 		}
 	case "excel":
 		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"category_%s_products-%d.xlsx\"", category, time.Now().Unix()))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"category_%s_products-%d.xlsx\"", label, time.Now().Unix()))
 		if err := s.exportProductsExcel(w, products); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
 			return
@@ -359,7 +529,7 @@ func (s *Server) showCategoryProducts(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested information on category '%s' products in %s format", user, category, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on category '%s' products in %s format", user, label, format))
 }
 
 func (s *Server) showPriceRangeProducts(w http.ResponseWriter, r *http.Request) {
@@ -393,26 +563,30 @@ func (s *Server) showPriceRangeProducts(w http.ResponseWriter, r *http.Request)
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	products, err := s.DB.ShowBetweenPriceProducts(min, max, limit)
+	includeDeleted, err := parseIncludeDeleted(r)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve products between prices %d and %d", min, max))
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
 		return
 	}
 
+	products, next, err := s.db(r).ShowBetweenPriceProducts(min, max, page, includeDeleted)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(products); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: products, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -433,7 +607,7 @@ func (s *Server) showPriceRangeProducts(w http.ResponseWriter, r *http.Request)
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested information on products priced between %d and %d in %s format", user, min, max, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on products priced between %d and %d in %s format", user, min, max, format))
 }
 
 func (s *Server) showProducts(w http.ResponseWriter, r *http.Request) {
@@ -448,6 +622,113 @@ func (s *Server) showProducts(w http.ResponseWriter, r *http.Request) {
 		format = "json"
 	}
 
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	includeDeleted, err := parseIncludeDeleted(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
+		return
+	}
+
+	products, next, err := s.db(r).ShowProducts(page, includeDeleted)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: products, NextCursor: next}); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportProductsCSV(w, products); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=products-%d.xlsx", time.Now().Unix()))
+		if err := s.exportProductsExcel(w, products); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on products in %s format", user, format))
+}
+
+func (s *Server) searchProducts(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		s.respondWithError(w, http.StatusBadRequest, "q parameter is required")
+		return
+	}
+
+	var minPrice, maxPrice *float64
+	if v := r.URL.Query().Get("min_price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid min_price value")
+			return
+		}
+		minPrice = &price
+	}
+	if v := r.URL.Query().Get("max_price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid max_price value")
+			return
+		}
+		maxPrice = &price
+	}
+
+	inStock := false
+	if v := r.URL.Query().Get("in_stock"); v != "" {
+		if inStock, err = strconv.ParseBool(v); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid in_stock value")
+			return
+		}
+	}
+
+	var supplierID *int64
+	if v := r.URL.Query().Get("supplier_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid supplier_id value")
+			return
+		}
+		supplierID = &id
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
 	var limit *int
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if lmt, err := strconv.Atoi(l); err == nil {
@@ -458,16 +739,21 @@ func (s *Server) showProducts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	products, err := s.DB.ShowProducts(limit)
+	results, err := s.db(r).SearchProducts(query, minPrice, maxPrice, inStock, supplierID, warehouseID, limit)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve products")
+		s.respondWithDBError(w, err)
 		return
 	}
 
+	products := make([]database.Product, 0, len(results))
+	for _, result := range results {
+		products = append(products, result.Product)
+	}
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(products); err != nil {
+		if err := json.NewEncoder(w).Encode(results); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -479,7 +765,7 @@ func (s *Server) showProducts(w http.ResponseWriter, r *http.Request) {
 		}
 	case "excel":
 		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=products-%d.xlsx", time.Now().Unix()))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"products_search-%d.xlsx\"", time.Now().Unix()))
 		if err := s.exportProductsExcel(w, products); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
 			return
@@ -488,7 +774,66 @@ func (s *Server) showProducts(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested information on products in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s searched products for '%s' in %s format", user, query, format))
+}
+
+func (s *Server) productBySKU(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sku := strings.TrimSpace(r.URL.Query().Get("sku"))
+	if sku == "" {
+		s.respondWithError(w, http.StatusBadRequest, "sku parameter is required")
+		return
+	}
+
+	product, err := s.db(r).ProductBySKU(sku)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(product); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s looked up product with SKU %s", user, sku))
+}
+
+func (s *Server) productByBarcode(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	barcode := strings.TrimSpace(r.URL.Query().Get("barcode"))
+	if barcode == "" {
+		s.respondWithError(w, http.StatusBadRequest, "barcode parameter is required")
+		return
+	}
+
+	if !validBarcode(barcode) {
+		s.respondWithError(w, http.StatusBadRequest, "Barcode must be a valid EAN-8, UPC-A, EAN-13 or GTIN-14 code")
+		return
+	}
+
+	product, err := s.db(r).ProductByBarcode(barcode)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(product); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s looked up product with barcode %s", user, barcode))
 }
 
 func exportPurchaseRequestsCSV(w io.Writer, requests []database.PurchaseRequest) error {
@@ -546,15 +891,19 @@ func (s *Server) showPurchaseRequests(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	maxQuantity := r.URL.Query().Get("maxQuantity")
-	if maxQuantity == "" {
-		s.respondWithError(w, http.StatusBadRequest, "maxQuantity parameter is required")
-		return
+	var maxQty *int64
+	if v := r.URL.Query().Get("maxQuantity"); v != "" {
+		qty, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid maxQuantity value")
+			return
+		}
+		maxQty = &qty
 	}
 
-	maxQty, err := strconv.ParseInt(maxQuantity, 10, 64)
+	warehouseID, err := parseWarehouseID(r)
 	if err != nil {
-		s.respondWithError(w, http.StatusBadRequest, "Invalid maxQuantity value")
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
 		return
 	}
 
@@ -563,9 +912,9 @@ func (s *Server) showPurchaseRequests(w http.ResponseWriter, r *http.Request) {
 		format = "json"
 	}
 
-	purchaseRequests, err := s.DB.PurchaseRequestProducts(maxQty)
+	purchaseRequests, err := s.db(r).PurchaseRequestProducts(maxQty, warehouseID)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve purchase requests")
+		s.respondWithDBError(w, err)
 		return
 	}
 
@@ -593,5 +942,5 @@ func (s *Server) showPurchaseRequests(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested purchase requests in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested purchase requests in %s format", user, format))
 }
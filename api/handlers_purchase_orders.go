@@ -0,0 +1,512 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PurchaseOrderLine struct {
+	ProductID *int64   `json:"product_id"`
+	Quantity  *int64   `json:"quantity"`
+	UnitCost  *float64 `json:"unit_cost"`
+}
+
+type PurchaseOrder struct {
+	SupplierID  *int64              `json:"supplier_id"`
+	WarehouseID *int64              `json:"warehouse_id"`
+	Notes       string              `json:"notes"`
+	ExpectedAt  *time.Time          `json:"expected_at"`
+	Lines       []PurchaseOrderLine `json:"lines"`
+}
+
+func (s *Server) addPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var po PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&po); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if po.SupplierID == nil || len(po.Lines) == 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	lines := make([]database.PurchaseOrderLine, 0, len(po.Lines))
+	seen := make(map[int64]bool, len(po.Lines))
+	for _, line := range po.Lines {
+		if line.ProductID == nil || line.Quantity == nil {
+			s.respondWithError(w, http.StatusBadRequest, "Every line needs a product ID and a quantity")
+			return
+		}
+		if *line.Quantity <= 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Line quantities must be positive")
+			return
+		}
+		if line.UnitCost != nil && *line.UnitCost < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Unit cost cannot be negative")
+			return
+		}
+		if seen[*line.ProductID] {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Product %d is listed more than once", *line.ProductID))
+			return
+		}
+		seen[*line.ProductID] = true
+
+		if exists, err := s.db(r).CheckProductExists(*line.ProductID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("product with ID %d does not exist", *line.ProductID), "fk_purchase_order_lines_products")
+			return
+		}
+
+		lines = append(lines, database.PurchaseOrderLine{ProductID: *line.ProductID, Quantity: *line.Quantity, UnitCost: line.UnitCost})
+	}
+
+	if exists, err := s.db(r).CheckSupplierExists(*po.SupplierID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("supplier with ID %d does not exist", *po.SupplierID), "fk_purchase_orders_suppliers")
+		return
+	}
+
+	id, err := s.db(r).CreatePurchaseOrder(*po.SupplierID, po.WarehouseID, strings.TrimSpace(po.Notes), po.ExpectedAt, lines)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s created purchase order %d for supplier with id %d", user, id, *po.SupplierID))
+}
+
+func (s *Server) addPurchaseOrdersFromRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input struct {
+		MaxQuantity    *int64 `json:"max_quantity"`
+		TargetQuantity *int64 `json:"target_quantity"`
+		WarehouseID    *int64 `json:"warehouse_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if input.MaxQuantity == nil || *input.MaxQuantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "max_quantity must be positive")
+		return
+	}
+
+	target := *input.MaxQuantity
+	if input.TargetQuantity != nil {
+		target = *input.TargetQuantity
+	}
+	if target < *input.MaxQuantity {
+		s.respondWithError(w, http.StatusBadRequest, "target_quantity cannot be lower than max_quantity")
+		return
+	}
+
+	result, err := s.db(r).CreatePurchaseOrdersFromRequests(*input.MaxQuantity, target, input.WarehouseID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s created %d purchase orders from purchase requests", user, len(result.PurchaseOrderIDs)))
+}
+
+func (s *Server) updatePurchaseOrderStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input struct {
+		ID     *int64 `json:"id"`
+		Status string `json:"status"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if input.ID == nil || input.Status == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Purchase order ID and Status are required")
+		return
+	}
+
+	switch input.Status {
+	case database.PurchaseOrderSent, database.PurchaseOrderCancelled:
+	case database.PurchaseOrderPartiallyReceived, database.PurchaseOrderReceived:
+		s.respondWithError(w, http.StatusBadRequest, "Received statuses are set by goods receiving")
+		return
+	default:
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown purchase order status %s", input.Status))
+		return
+	}
+
+	if err := s.db(r).UpdatePurchaseOrderStatus(*input.ID, input.Status); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithStatus(w, http.StatusOK, fmt.Sprintf("Purchase order status updated successfully for purchase order %d", *input.ID))
+	s.logger(r).Info(fmt.Sprintf("User %s set status of purchase order %d to %s", user, *input.ID, input.Status))
+}
+
+func (s *Server) showPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	orders, next, err := s.db(r).ShowPurchaseOrders(page)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: orders, NextCursor: next}); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportPurchaseOrdersCSV(w, orders); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"purchase_orders-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportPurchaseOrdersExcel(w, orders); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on purchase orders in %s format", user, format))
+}
+
+func (s *Server) showPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idParam := r.URL.Query().Get("id")
+	if idParam == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Purchase order ID is required")
+		return
+	}
+
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid purchase order ID format")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	po, err := s.db(r).GetPurchaseOrder(id)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(po); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportPurchaseOrderLinesCSV(w, po); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"purchase_order-%d.xlsx\"", po.PurchaseOrderID))
+		if err := s.exportPurchaseOrderExcel(w, po); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"purchase_order-%d.pdf\"", po.PurchaseOrderID))
+		if err := s.exportPurchaseOrderPDF(w, po); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate PDF file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested purchase order %d in %s format", user, id, format))
+}
+
+func (s *Server) exportPurchaseOrdersCSV(w io.Writer, orders []database.PurchaseOrder) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"PurchaseOrderID", "SupplierID", "SupplierName", "Status", "TotalCost", "ExpectedAt", "SentAt", "CreatedAt", "UpdatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, po := range orders {
+		record := []string{
+			fmt.Sprintf("%d", po.PurchaseOrderID),
+			fmt.Sprintf("%d", po.SupplierID),
+			po.SupplierName,
+			po.Status,
+			fmt.Sprintf("%.2f", po.TotalCost),
+			formatOptionalTime(po.ExpectedAt),
+			formatOptionalTime(po.SentAt),
+			po.CreatedAt.Format(time.RFC3339),
+			po.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportPurchaseOrdersExcel(w io.Writer, orders []database.PurchaseOrder) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("PurchaseOrders-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"PurchaseOrderID", "SupplierID", "SupplierName", "Status", "TotalCost", "ExpectedAt", "SentAt", "CreatedAt", "UpdatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, po := range orders {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+2), po.PurchaseOrderID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+2), po.SupplierID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), po.SupplierName)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), po.Status)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), po.TotalCost)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), formatOptionalTime(po.ExpectedAt))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), formatOptionalTime(po.SentAt))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", i+2), po.CreatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", i+2), po.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) exportPurchaseOrderLinesCSV(w io.Writer, po database.PurchaseOrder) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"PurchaseOrderID", "LineID", "ProductID", "ProductName", "SKU", "SupplierSKU", "Quantity", "ReceivedQuantity", "UnitCost", "LineTotal"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, line := range po.Lines {
+		record := []string{
+			fmt.Sprintf("%d", po.PurchaseOrderID),
+			fmt.Sprintf("%d", line.PurchaseOrderLineID),
+			fmt.Sprintf("%d", line.ProductID),
+			line.ProductName,
+			line.SKU,
+			line.SupplierSKU,
+			fmt.Sprintf("%d", line.Quantity),
+			fmt.Sprintf("%d", line.ReceivedQuantity),
+			formatOptionalAmount(line.UnitCost),
+			fmt.Sprintf("%.2f", line.LineTotal),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportPurchaseOrderExcel(w io.Writer, po database.PurchaseOrder) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("PO-%d", po.PurchaseOrderID)
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	header := [][2]any{
+		{"Purchase order", po.PurchaseOrderID},
+		{"Supplier", po.SupplierName},
+		{"Contact", fmt.Sprintf("%s <%s>", po.SupplierContactName, po.SupplierEmail)},
+		{"Deliver to", deliveryAddress(po)},
+		{"Status", po.Status},
+		{"Created", po.CreatedAt.Format(time.RFC3339)},
+		{"Expected", formatOptionalTime(po.ExpectedAt)},
+		{"Notes", po.Notes},
+	}
+	for i, h := range header {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+1), h[0])
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+1), h[1])
+	}
+
+	start := len(header) + 2
+	headers := []string{"ProductID", "ProductName", "SKU", "SupplierSKU", "Quantity", "ReceivedQuantity", "UnitCost", "LineTotal"}
+	for i, h := range headers {
+		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", 'A'+i, start), h)
+	}
+
+	for i, line := range po.Lines {
+		row := start + i + 1
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), line.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), line.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), line.SKU)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.SupplierSKU)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.ReceivedQuantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), formatOptionalAmount(line.UnitCost))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), line.LineTotal)
+	}
+
+	total := start + len(po.Lines) + 1
+	f.SetCellValue(sheetName, fmt.Sprintf("G%d", total), "Total")
+	f.SetCellValue(sheetName, fmt.Sprintf("H%d", total), po.TotalCost)
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deliveryAddress is the warehouse the goods of a purchase order go to.
+func deliveryAddress(po database.PurchaseOrder) string {
+	if po.WarehouseAddress == "" {
+		return po.WarehouseName
+	}
+	return po.WarehouseName + ", " + po.WarehouseAddress
+}
+
+// exportPurchaseOrderPDF renders the purchase order as a one-table A4
+// document that can be sent to the supplier as is.
+func (s *Server) exportPurchaseOrderPDF(w io.Writer, po database.PurchaseOrder) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, fmt.Sprintf("Purchase Order #%d", po.PurchaseOrderID))
+	pdf.Ln(12)
+
+	pdf.SetFont("Helvetica", "", 10)
+	header := [][2]string{
+		{"Supplier", po.SupplierName},
+		{"Contact", fmt.Sprintf("%s <%s>", po.SupplierContactName, po.SupplierEmail)},
+		{"Deliver to", deliveryAddress(po)},
+		{"Status", po.Status},
+		{"Date", po.CreatedAt.Format(time.DateOnly)},
+	}
+	if po.ExpectedAt != nil {
+		header = append(header, [2]string{"Expected", po.ExpectedAt.Format(time.DateOnly)})
+	}
+	for _, h := range header {
+		pdf.CellFormat(30, 6, h[0]+":", "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, tr(h[1]), "", 1, "L", false, 0, "")
+	}
+	if po.Notes != "" {
+		pdf.Ln(2)
+		pdf.MultiCell(0, 5, tr(po.Notes), "", "L", false)
+	}
+	pdf.Ln(6)
+
+	widths := []float64{20, 62, 30, 20, 28, 30}
+	columns := []string{"Product", "Name", "Supplier SKU", "Qty", "Unit cost", "Total"}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, c := range columns {
+		pdf.CellFormat(widths[i], 7, c, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range po.Lines {
+		pdf.CellFormat(widths[0], 6, strconv.FormatInt(line.ProductID, 10), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[1], 6, tr(line.ProductName), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, tr(line.SupplierSKU), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 6, strconv.FormatInt(line.Quantity, 10), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, formatOptionalAmount(line.UnitCost), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, fmt.Sprintf("%.2f", line.LineTotal), "1", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3]+widths[4], 7, "Total", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[5], 7, fmt.Sprintf("%.2f", po.TotalCost), "1", 1, "R", false, 0, "")
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultCoverDays is the number of days of sales a purchase suggestion
+// orders for when no cover_days parameter is given.
+const defaultCoverDays = 30
+
+func (s *Server) updateReorderPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var policyStruct struct {
+		ID              int64  `json:"id"`
+		ReorderPoint    *int64 `json:"reorder_point"`
+		ReorderQuantity *int64 `json:"reorder_quantity"`
+		SafetyStock     int64  `json:"safety_stock"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&policyStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if policyStruct.ID <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Product ID is required")
+		return
+	}
+
+	if policyStruct.ReorderPoint != nil && *policyStruct.ReorderPoint < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Reorder point cannot be negative")
+		return
+	}
+
+	if policyStruct.ReorderQuantity != nil && *policyStruct.ReorderQuantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Reorder quantity must be positive")
+		return
+	}
+
+	if policyStruct.SafetyStock < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Safety stock cannot be negative")
+		return
+	}
+
+	if err := s.db(r).SetReorderPolicy(policyStruct.ID, policyStruct.ReorderPoint, policyStruct.ReorderQuantity, policyStruct.SafetyStock); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s updated the reorder policy of product with id %d", user, policyStruct.ID))
+}
+
+func (s *Server) exportPurchaseSuggestionsCSV(w io.Writer, suggestions []database.SupplierSuggestions) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"SupplierID", "SupplierName", "ContactEmail", "ProductID", "ProductName", "Available", "OnOrder", "Backordered", "Position",
+		"AvgDailySold", "LeadTimeDays", "SafetyStock", "ReorderPoint", "Quantity", "UnitCost"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, supplier := range suggestions {
+		for _, line := range supplier.Lines {
+			record := []string{
+				fmt.Sprintf("%d", supplier.SupplierID),
+				supplier.SupplierName,
+				supplier.ContactEmail,
+				fmt.Sprintf("%d", line.ProductID),
+				line.ProductName,
+				fmt.Sprintf("%d", line.Available),
+				fmt.Sprintf("%d", line.OnOrder),
+				fmt.Sprintf("%d", line.Backordered),
+				fmt.Sprintf("%d", line.Position),
+				fmt.Sprintf("%.2f", line.AvgDailySold),
+				fmt.Sprintf("%d", line.LeadTimeDays),
+				fmt.Sprintf("%d", line.SafetyStock),
+				fmt.Sprintf("%d", line.ReorderPoint),
+				fmt.Sprintf("%d", line.Quantity),
+				formatOptionalAmount(line.UnitCost),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportPurchaseSuggestionsExcel(w io.Writer, suggestions []database.SupplierSuggestions) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Suggestions-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"SupplierID", "SupplierName", "ContactEmail", "ProductID", "ProductName", "Available", "OnOrder", "Backordered", "Position",
+		"AvgDailySold", "LeadTimeDays", "SafetyStock", "ReorderPoint", "Quantity", "UnitCost"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	row := 2
+	for _, supplier := range suggestions {
+		for _, line := range supplier.Lines {
+			f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), supplier.SupplierID)
+			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), supplier.SupplierName)
+			f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), supplier.ContactEmail)
+			f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.ProductID)
+			f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.ProductName)
+			f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.Available)
+			f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), line.OnOrder)
+			f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), line.Backordered)
+			f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), line.Position)
+			f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), fmt.Sprintf("%.2f", line.AvgDailySold))
+			f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), line.LeadTimeDays)
+			f.SetCellValue(sheetName, fmt.Sprintf("L%d", row), line.SafetyStock)
+			f.SetCellValue(sheetName, fmt.Sprintf("M%d", row), line.ReorderPoint)
+			f.SetCellValue(sheetName, fmt.Sprintf("N%d", row), line.Quantity)
+			f.SetCellValue(sheetName, fmt.Sprintf("O%d", row), formatOptionalAmount(line.UnitCost))
+			row++
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showPurchaseSuggestions(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	days := int64(defaultSalesWindowDays)
+	if v := r.URL.Query().Get("days"); v != "" {
+		if days, err = strconv.ParseInt(v, 10, 64); err != nil || days <= 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid days value")
+			return
+		}
+	}
+
+	coverDays := int64(defaultCoverDays)
+	if v := r.URL.Query().Get("cover_days"); v != "" {
+		if coverDays, err = strconv.ParseInt(v, 10, 64); err != nil || coverDays < 0 {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid cover_days value")
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	suggestions, err := s.db(r).PurchaseSuggestions(days, coverDays)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportPurchaseSuggestionsCSV(w, suggestions); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"purchase_suggestions-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportPurchaseSuggestionsExcel(w, suggestions); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested purchase suggestions in %s format", user, format))
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxReservationMinutes caps how long a single reservation may hold stock.
+const maxReservationMinutes = 30 * 24 * 60
+
+func (s *Server) addReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input struct {
+		ProductID        *int64   `json:"product_id"`
+		Quantity         *int64   `json:"quantity"`
+		WarehouseID      *int64   `json:"warehouse_id"`
+		CustomerID       *int64   `json:"customer_id"`
+		Price            *float64 `json:"price"`
+		Reference        string   `json:"reference"`
+		ExpiresInMinutes *int64   `json:"expires_in_minutes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if input.ProductID == nil || input.Quantity == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	if *input.Quantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+
+	if input.Price != nil && *input.Price < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Price cannot be negative")
+		return
+	}
+
+	if len(input.Reference) > 255 {
+		s.respondWithError(w, http.StatusBadRequest, "Reference cannot be longer than 255 characters")
+		return
+	}
+
+	var ttl *time.Duration
+	if input.ExpiresInMinutes != nil {
+		if *input.ExpiresInMinutes <= 0 || *input.ExpiresInMinutes > maxReservationMinutes {
+			s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_minutes must be between 1 and %d", maxReservationMinutes))
+			return
+		}
+		d := time.Duration(*input.ExpiresInMinutes) * time.Minute
+		ttl = &d
+	}
+
+	if exists, err := s.db(r).CheckProductExists(*input.ProductID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("product with id %d not exist", *input.ProductID), "fk_products")
+		return
+	}
+
+	if input.CustomerID != nil {
+		if exists, err := s.db(r).CheckCustomerExists(*input.CustomerID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("customer with id %d not exist", *input.CustomerID), "fk_customer")
+			return
+		}
+	}
+
+	reservation, err := s.db(r).AddReservation(*input.ProductID, *input.Quantity, input.WarehouseID, input.CustomerID, input.Price, input.Reference, ttl, user)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(reservation); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s reserved %d units of product %d in warehouse %d until %s with reservation id %d",
+		user, reservation.Quantity, reservation.ProductID, reservation.WarehouseID, reservation.ExpiresAt.Format(time.RFC3339), reservation.ReservationID))
+}
+
+func (s *Server) releaseReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if err = s.db(r).ReleaseReservation(input.ID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s released reservation with id %d", user, input.ID))
+}
+
+func (s *Server) convertReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input struct {
+		ID         int64  `json:"id"`
+		CustomerID *int64 `json:"customer_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if input.CustomerID != nil {
+		if exists, err := s.db(r).CheckCustomerExists(*input.CustomerID); err != nil {
+			s.respondWithDBError(w, err)
+			return
+		} else if !exists {
+			s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("customer with id %d not exist", *input.CustomerID), "fk_customer")
+			return
+		}
+	}
+
+	idOrder, idOrderDetail, err := s.db(r).ConvertReservation(input.ID, input.CustomerID, user)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNewOrder(w, idOrder, idOrderDetail, database.OrderNew)
+	s.logger(r).Info(fmt.Sprintf("User %s converted reservation with id %d into order with order id %d and order detail id %d", user, input.ID, idOrder, idOrderDetail))
+}
+
+func (s *Server) exportReservationsCSV(w io.Writer, reservations []database.Reservation) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"ReservationID", "ProductID", "ProductName", "WarehouseID", "WarehouseName", "CustomerID", "Quantity", "Price", "Reference", "Status", "ExpiresAt", "OrderID", "CreatedBy", "CreatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, res := range reservations {
+		record := []string{
+			fmt.Sprintf("%d", res.ReservationID),
+			fmt.Sprintf("%d", res.ProductID),
+			res.ProductName,
+			fmt.Sprintf("%d", res.WarehouseID),
+			res.WarehouseName,
+			formatOptionalID(res.CustomerID),
+			fmt.Sprintf("%d", res.Quantity),
+			formatOptionalAmount(res.Price),
+			res.Reference,
+			res.Status,
+			res.ExpiresAt.Format(time.RFC3339),
+			formatOptionalID(res.OrderID),
+			res.CreatedBy,
+			res.CreatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportReservationsExcel(w io.Writer, reservations []database.Reservation) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Reservations-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"ReservationID", "ProductID", "ProductName", "WarehouseID", "WarehouseName", "CustomerID", "Quantity", "Price", "Reference", "Status", "ExpiresAt", "OrderID", "CreatedBy", "CreatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, res := range reservations {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), res.ReservationID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), res.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), res.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), res.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), res.WarehouseName)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), formatOptionalID(res.CustomerID))
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), res.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), formatOptionalAmount(res.Price))
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), res.Reference)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), res.Status)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), res.ExpiresAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("L%d", row), formatOptionalID(res.OrderID))
+		f.SetCellValue(sheetName, fmt.Sprintf("M%d", row), res.CreatedBy)
+		f.SetCellValue(sheetName, fmt.Sprintf("N%d", row), res.CreatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showReservations(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	var customerID *int64
+	if v := r.URL.Query().Get("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid customer_id value")
+			return
+		}
+		customerID = &id
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", database.ReservationActive, database.ReservationConverted, database.ReservationReleased, database.ReservationExpired:
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid status value")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	reservations, err := s.db(r).ShowReservations(productID, warehouseID, customerID, status)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reservations); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportReservationsCSV(w, reservations); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"reservations-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportReservationsExcel(w, reservations); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested reservations in %s format", user, format))
+}
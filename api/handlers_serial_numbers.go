@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxSerialNumberLength is the size of the serial_number column.
+const maxSerialNumberLength = 64
+
+// cleanSerials trims the serial numbers of an incoming stock change and
+// checks them; the message is empty if they are valid.
+func cleanSerials(serials []string) ([]string, string) {
+	cleaned := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, "Serial numbers cannot be empty"
+		}
+		if len(serial) > maxSerialNumberLength {
+			return nil, fmt.Sprintf("Serial number cannot be longer than %d characters", maxSerialNumberLength)
+		}
+		cleaned = append(cleaned, serial)
+	}
+	return cleaned, ""
+}
+
+func (s *Server) traceSerial(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	serialNumber := strings.TrimSpace(r.URL.Query().Get("serial_number"))
+	if serialNumber == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Serial number is required")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	units, err := s.db(r).TraceSerial(serialNumber, productID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(units); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s traced serial number %s", user, serialNumber))
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StockAdjustment struct {
+	ProductID      *int64     `json:"product_id"`
+	WarehouseID    *int64     `json:"warehouse_id"`
+	QuantityChange *int64     `json:"quantity_change"`
+	Reason         string     `json:"reason"`
+	LotNumber      string     `json:"lot_number"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	SerialNumbers  []string   `json:"serial_numbers"`
+}
+
+func (s *Server) adjustStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var a StockAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	a.Reason = strings.TrimSpace(a.Reason)
+	if a.ProductID == nil || a.QuantityChange == nil || a.Reason == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Product ID, quantity change and reason are required")
+		return
+	}
+	if *a.QuantityChange == 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Quantity change cannot be zero")
+		return
+	}
+
+	a.LotNumber = strings.TrimSpace(a.LotNumber)
+	if msg := validLot(a.LotNumber, a.ExpiresAt); msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	serials, msg := cleanSerials(a.SerialNumbers)
+	if msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.db(r).AdjustStock(*a.ProductID, a.WarehouseID, *a.QuantityChange, a.Reason, a.LotNumber, a.ExpiresAt, serials, user); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s adjusted stock of product %d by %d: %s", user, *a.ProductID, *a.QuantityChange, a.Reason))
+}
+
+func (s *Server) exportStockMovementsCSV(w io.Writer, movements []database.StockMovement) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"StockMovementID", "ProductID", "WarehouseID", "QuantityChange", "Balance", "MovementType", "Reason", "ReferenceType", "ReferenceID", "CreatedBy", "CreatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, m := range movements {
+		record := []string{
+			fmt.Sprintf("%d", m.StockMovementID),
+			fmt.Sprintf("%d", m.ProductID),
+			fmt.Sprintf("%d", m.WarehouseID),
+			fmt.Sprintf("%d", m.QuantityChange),
+			fmt.Sprintf("%d", m.Balance),
+			m.Type,
+			m.Reason,
+			m.ReferenceType,
+			formatOptionalID(m.ReferenceID),
+			m.CreatedBy,
+			m.CreatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportStockMovementsExcel(w io.Writer, movements []database.StockMovement) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("StockMovements-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"StockMovementID", "ProductID", "WarehouseID", "QuantityChange", "Balance", "MovementType", "Reason", "ReferenceType", "ReferenceID", "CreatedBy", "CreatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, m := range movements {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), m.StockMovementID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), m.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), m.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), m.QuantityChange)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), m.Balance)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), m.Type)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), m.Reason)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), m.ReferenceType)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), formatOptionalID(m.ReferenceID))
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), m.CreatedBy)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), m.CreatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showStockMovements(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var productID *int64
+	if idParam := r.URL.Query().Get("product_id"); idParam != "" {
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product ID format")
+			return
+		}
+		productID = &id
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	movements, next, err := s.db(r).ShowStockMovements(productID, page)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: movements, NextCursor: next}); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportStockMovementsCSV(w, movements); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"stock_movements-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportStockMovementsExcel(w, movements); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested stock movements in %s format", user, format))
+}
+
+func (s *Server) stockReconciliation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	drifts, err := s.db(r).ReconcileStock(false)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(drifts); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s checked stock against the ledger, %d products drifted", user, len(drifts)))
+}
+
+func (s *Server) reconcileStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	drifts, err := s.db(r).ReconcileStock(true)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(drifts); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s reset %d drifted product quantities to the ledger", user, len(drifts)))
+}
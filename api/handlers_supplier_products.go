@@ -0,0 +1,300 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SupplierProduct struct {
+	SupplierID       *int64   `json:"supplier_id"`
+	ProductID        *int64   `json:"product_id"`
+	CostPrice        *float64 `json:"cost_price"`
+	SupplierSKU      string   `json:"supplier_sku"`
+	LeadTimeDays     *int64   `json:"lead_time_days"`
+	MinOrderQuantity *int64   `json:"min_order_quantity"`
+}
+
+func (s *Server) addSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var sp SupplierProduct
+	if err := json.NewDecoder(r.Body).Decode(&sp); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if sp.SupplierID == nil || sp.ProductID == nil || sp.CostPrice == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+
+	if *sp.CostPrice < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Cost price cannot be negative")
+		return
+	}
+
+	leadTimeDays := int64(0)
+	if sp.LeadTimeDays != nil {
+		leadTimeDays = *sp.LeadTimeDays
+	}
+	if leadTimeDays < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Lead time cannot be negative")
+		return
+	}
+
+	minOrderQuantity := int64(1)
+	if sp.MinOrderQuantity != nil {
+		minOrderQuantity = *sp.MinOrderQuantity
+	}
+	if minOrderQuantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Minimum order quantity must be positive")
+		return
+	}
+
+	if exists, err := s.db(r).CheckSupplierExists(*sp.SupplierID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("supplier with ID %d does not exist", *sp.SupplierID), "fk_supplier_products_suppliers")
+		return
+	}
+
+	if exists, err := s.db(r).CheckProductExists(*sp.ProductID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("product with ID %d does not exist", *sp.ProductID), "fk_supplier_products_products")
+		return
+	}
+
+	id, err := s.db(r).AddSupplierProduct(*sp.SupplierID, *sp.ProductID, *sp.CostPrice, strings.TrimSpace(sp.SupplierSKU), leadTimeDays, minOrderQuantity)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s added product with id %d to the catalog of supplier with id %d", user, *sp.ProductID, *sp.SupplierID))
+}
+
+func (s *Server) deleteSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var deleteStruct struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&deleteStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if err = s.db(r).DeleteSupplierProduct(deleteStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s removed supplier product with id %d", user, deleteStruct.ID))
+}
+
+func (s *Server) updateSupplierProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var updateStruct struct {
+		ID               int64    `json:"id"`
+		CostPrice        *float64 `json:"cost_price"`
+		SupplierSKU      *string  `json:"supplier_sku"`
+		LeadTimeDays     *int64   `json:"lead_time_days"`
+		MinOrderQuantity *int64   `json:"min_order_quantity"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&updateStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if updateStruct.CostPrice != nil && *updateStruct.CostPrice < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Cost price cannot be negative")
+		return
+	}
+
+	if updateStruct.LeadTimeDays != nil && *updateStruct.LeadTimeDays < 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Lead time cannot be negative")
+		return
+	}
+
+	if updateStruct.MinOrderQuantity != nil && *updateStruct.MinOrderQuantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Minimum order quantity must be positive")
+		return
+	}
+
+	if updateStruct.SupplierSKU != nil {
+		sku := strings.TrimSpace(*updateStruct.SupplierSKU)
+		updateStruct.SupplierSKU = &sku
+	}
+
+	if err := s.db(r).UpdateSupplierProduct(updateStruct.ID, updateStruct.CostPrice, updateStruct.SupplierSKU, updateStruct.LeadTimeDays, updateStruct.MinOrderQuantity); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s updated information of supplier product with id %d", user, updateStruct.ID))
+}
+
+func (s *Server) exportSupplierProductsCSV(w io.Writer, catalog []database.SupplierProduct) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"SupplierProductID", "SupplierID", "SupplierName", "ProductID", "ProductName", "CostPrice", "SupplierSKU", "LeadTimeDays", "MinOrderQuantity", "IsPrimary", "CreatedAt", "UpdatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, sp := range catalog {
+		record := []string{
+			fmt.Sprintf("%d", sp.SupplierProductID),
+			fmt.Sprintf("%d", sp.SupplierID),
+			sp.SupplierName,
+			fmt.Sprintf("%d", sp.ProductID),
+			sp.ProductName,
+			fmt.Sprintf("%.2f", sp.CostPrice),
+			sp.SupplierSKU,
+			fmt.Sprintf("%d", sp.LeadTimeDays),
+			fmt.Sprintf("%d", sp.MinOrderQuantity),
+			strconv.FormatBool(sp.IsPrimary),
+			sp.CreatedAt.Format(time.RFC3339),
+			sp.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportSupplierProductsExcel(w io.Writer, catalog []database.SupplierProduct) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("SupplierProducts-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"SupplierProductID", "SupplierID", "SupplierName", "ProductID", "ProductName", "CostPrice", "SupplierSKU", "LeadTimeDays", "MinOrderQuantity", "IsPrimary", "CreatedAt", "UpdatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, sp := range catalog {
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", i+2), sp.SupplierProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", i+2), sp.SupplierID)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), sp.SupplierName)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), sp.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), sp.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), sp.CostPrice)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", i+2), sp.SupplierSKU)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", i+2), sp.LeadTimeDays)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", i+2), sp.MinOrderQuantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", i+2), sp.IsPrimary)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", i+2), sp.CreatedAt.Format(time.RFC3339))
+		f.SetCellValue(sheetName, fmt.Sprintf("L%d", i+2), sp.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showSupplierProducts(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var productID, supplierID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product ID format")
+			return
+		}
+		productID = &id
+	}
+	if v := r.URL.Query().Get("supplier_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid supplier ID format")
+			return
+		}
+		supplierID = &id
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	catalog, err := s.db(r).ShowSupplierProducts(productID, supplierID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(catalog); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportSupplierProductsCSV(w, catalog); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"supplier_products-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportSupplierProductsExcel(w, catalog); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested the supplier catalog in %s format", user, format))
+}
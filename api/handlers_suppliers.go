@@ -3,13 +3,11 @@ package api
 import (
 	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
 	"io"
 	"net/http"
-	"strconv"
 	"time"
 )
 
@@ -41,30 +39,30 @@ func (s *Server) addSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if id, err := s.DB.CheckEmailSupplier(sup.ContactEmail); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if id, err := s.db(r).CheckEmailSupplier(sup.ContactEmail); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if id != -1 {
-		s.respondWithError(w, http.StatusBadRequest, "There is a user with this email")
+		s.respondWithErrorCode(w, http.StatusConflict, codeConflict, "There is a user with this email", "")
 		return
 	}
 
-	if id, err := s.DB.CheckPhoneSupplier(sup.ContactPhone); err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	if id, err := s.db(r).CheckPhoneSupplier(sup.ContactPhone); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	} else if id != -1 {
-		s.respondWithError(w, http.StatusBadRequest, "There is a user with this number")
+		s.respondWithErrorCode(w, http.StatusConflict, codeConflict, "There is a user with this number", "")
 		return
 	}
 
-	id, err := s.DB.AddSupplier(sup.Name, sup.ContactName, sup.ContactEmail, sup.ContactPhone)
+	id, err := s.db(r).AddSupplier(sup.Name, sup.ContactName, sup.ContactEmail, sup.ContactPhone)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Problem when adding a new user")
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondWithNew(w, id)
-	s.Log.Info(fmt.Sprintf("User %s created new supplier with id %d", user, id))
+	s.logger(r).Info(fmt.Sprintf("User %s created new supplier with id %d", user, id))
 }
 
 func (s *Server) deleteSupplier(w http.ResponseWriter, r *http.Request) {
@@ -86,16 +84,41 @@ func (s *Server) deleteSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.DB.DeleteSupplier(deleteStruct.ID); errors.Is(err, database.ErrNoSupplierFound) {
-		s.respondWithError(w, http.StatusBadRequest, "No supplier found with the provided ID")
+	if err = s.db(r).DeleteSupplier(deleteStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
 		return
-	} else if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s removed supplier with id %d", user, deleteStruct.ID))
+}
+
+func (s *Server) restoreSupplier(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var restoreStruct struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&restoreStruct); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if err = s.db(r).RestoreSupplier(restoreStruct.ID); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondNoContent(w)
-	s.Log.Info(fmt.Sprintf("User %s removed supplier with id %d", user, deleteStruct.ID))
+	s.logger(r).Info(fmt.Sprintf("User %s restored supplier with id %d", user, restoreStruct.ID))
 }
 
 func (s *Server) updateSupplier(w http.ResponseWriter, r *http.Request) {
@@ -121,24 +144,20 @@ func (s *Server) updateSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.DB.UpdateSupplier(updateStruct.ID, updateStruct.Name, updateStruct.ContactName, updateStruct.ContactEmail, updateStruct.ContactPhone); err != nil {
-		if errors.Is(err, database.ErrNoSupplierFound) {
-			s.respondWithError(w, http.StatusBadRequest, "No supplier found with the provided ID")
-		} else {
-			s.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Problem on the server side, please report the error time %s", time.Now()))
-		}
+	if err := s.db(r).UpdateSupplier(updateStruct.ID, updateStruct.Name, updateStruct.ContactName, updateStruct.ContactEmail, updateStruct.ContactPhone); err != nil {
+		s.respondWithDBError(w, err)
 		return
 	}
 
 	s.respondNoContent(w)
-	s.Log.Info(fmt.Sprintf("User %s updated information supplier with id %d", user, updateStruct.ID))
+	s.logger(r).Info(fmt.Sprintf("User %s updated information supplier with id %d", user, updateStruct.ID))
 }
 
 func (s *Server) exportSuppliersCSV(w io.Writer, suppliers []database.Supplier) error {
 	cw := csv.NewWriter(w)
 	defer cw.Flush()
 
-	headers := []string{"ID", "Name", "Contact Name", "Contact Email", "Contact Phone"}
+	headers := []string{"ID", "Name", "Contact Name", "Contact Email", "Contact Phone", "Deleted At"}
 	if err := cw.Write(headers); err != nil {
 		return err
 	}
@@ -150,6 +169,7 @@ func (s *Server) exportSuppliersCSV(w io.Writer, suppliers []database.Supplier)
 			supplier.ContactName,
 			supplier.ContactEmail,
 			supplier.ContactPhone,
+			formatOptionalTime(supplier.DeletedAt),
 		}
 		if err := cw.Write(record); err != nil {
 			return err
@@ -164,7 +184,7 @@ func (s *Server) exportSuppliersExcel(w io.Writer, suppliers []database.Supplier
 	f.NewSheet(sheetName)
 	f.SetActiveSheet(f.NewSheet(sheetName))
 
-	headers := []string{"ID", "Name", "Contact Name", "Contact Email", "Contact Phone"}
+	headers := []string{"ID", "Name", "Contact Name", "Contact Email", "Contact Phone", "Deleted At"}
 	for i, h := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, h)
@@ -176,6 +196,7 @@ func (s *Server) exportSuppliersExcel(w io.Writer, suppliers []database.Supplier
 		f.SetCellValue(sheetName, fmt.Sprintf("C%d", i+2), supplier.ContactName)
 		f.SetCellValue(sheetName, fmt.Sprintf("D%d", i+2), supplier.ContactEmail)
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", i+2), supplier.ContactPhone)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", i+2), formatOptionalTime(supplier.DeletedAt))
 	}
 
 	if err := f.Write(w); err != nil {
@@ -196,26 +217,30 @@ func (s *Server) showSuppliers(w http.ResponseWriter, r *http.Request) {
 		format = "json"
 	}
 
-	var limit *int
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if lmt, err := strconv.Atoi(l); err == nil {
-			limit = &lmt
-		} else {
-			s.respondWithError(w, http.StatusBadRequest, "Invalid limit value")
-			return
-		}
+	page, err := parsePage(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	suppliers, err := s.DB.ShowSuppliers(limit)
+	includeDeleted, err := parseIncludeDeleted(r)
 	if err != nil {
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve suppliers")
+		s.respondWithError(w, http.StatusBadRequest, "Invalid include_deleted value")
 		return
 	}
 
+	suppliers, next, err := s.db(r).ShowSuppliers(page, includeDeleted)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	setNextLink(w, r, next)
+
 	switch format {
 	case "json":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(suppliers); err != nil {
+		if err := json.NewEncoder(w).Encode(pageResponse{Items: suppliers, NextCursor: next}); err != nil {
 			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
 			return
 		}
@@ -236,5 +261,5 @@ func (s *Server) showSuppliers(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
 		return
 	}
-	s.Log.Info(fmt.Sprintf("User %s requested information on the suppliers in %s format", user, format))
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on the suppliers in %s format", user, format))
 }
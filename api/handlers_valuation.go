@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"time"
+)
+
+var valuationMethods = map[string]bool{
+	database.ValuationFIFO:            true,
+	database.ValuationWeightedAverage: true,
+}
+
+func (s *Server) exportValuationCSV(w io.Writer, valuation database.InventoryValuation) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"ProductID", "ProductName", "CategoryID", "Category", "WarehouseID", "WarehouseName", "Quantity", "UnitCost", "Value", "Uncosted"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, line := range valuation.Lines {
+		record := []string{
+			fmt.Sprintf("%d", line.ProductID),
+			line.ProductName,
+			formatOptionalID(line.CategoryID),
+			line.Category,
+			fmt.Sprintf("%d", line.WarehouseID),
+			line.WarehouseName,
+			fmt.Sprintf("%d", line.Quantity),
+			fmt.Sprintf("%.4f", line.UnitCost),
+			fmt.Sprintf("%.2f", line.Value),
+			fmt.Sprintf("%t", line.Uncosted),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	total := []string{"", "Total", "", "", "", "", fmt.Sprintf("%d", valuation.Quantity), "", fmt.Sprintf("%.2f", valuation.TotalValue), ""}
+	return cw.Write(total)
+}
+
+// writeValuationTotals fills a sheet with the totals of a valuation per
+// category or per warehouse, followed by the grand total.
+func writeValuationTotals(f *excelize.File, sheetName string, totals []database.ValuationTotal, valuation database.InventoryValuation) {
+	headers := []string{"ID", "Name", "Quantity", "Value"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, t := range totals {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), formatOptionalID(t.ID))
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), t.Name)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), t.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), fmt.Sprintf("%.2f", t.Value))
+	}
+
+	row := len(totals) + 2
+	f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), "Total")
+	f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), valuation.Quantity)
+	f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), fmt.Sprintf("%.2f", valuation.TotalValue))
+}
+
+func (s *Server) exportValuationExcel(w io.Writer, valuation database.InventoryValuation) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("Valuation-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"ProductID", "ProductName", "CategoryID", "Category", "WarehouseID", "WarehouseName", "Quantity", "UnitCost", "Value", "Uncosted"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, line := range valuation.Lines {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), line.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), line.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), formatOptionalID(line.CategoryID))
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), line.Category)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), line.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), line.WarehouseName)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), line.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), fmt.Sprintf("%.4f", line.UnitCost))
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), fmt.Sprintf("%.2f", line.Value))
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), line.Uncosted)
+	}
+
+	row := len(valuation.Lines) + 2
+	f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), "Total")
+	f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), valuation.Quantity)
+	f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), fmt.Sprintf("%.2f", valuation.TotalValue))
+
+	f.NewSheet("By category")
+	writeValuationTotals(f, "By category", valuation.Categories, valuation)
+	f.NewSheet("By warehouse")
+	writeValuationTotals(f, "By warehouse", valuation.Warehouses, valuation)
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showInventoryValuation(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	asOf := time.Now()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		if asOf, err = time.Parse(time.RFC3339, v); err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid as_of format, use ISO8601 format")
+			return
+		}
+	}
+
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = database.ValuationFIFO
+	}
+	if !valuationMethods[method] {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid method, use fifo or weighted_average")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	valuation, err := s.db(r).InventoryValuation(asOf, method)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(valuation); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportValuationCSV(w, valuation); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"inventory_valuation-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportValuationExcel(w, valuation); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s accessed the %s inventory valuation as of %s in %s format", user, method, asOf.Format(time.RFC3339), format))
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Warehouse struct {
+	ID        int64    `json:"id"`
+	Name      *string  `json:"name"`
+	Address   *string  `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	IsDefault bool     `json:"is_default"`
+}
+
+type StockTransfer struct {
+	ProductID       *int64   `json:"product_id"`
+	FromWarehouseID *int64   `json:"from_warehouse_id"`
+	ToWarehouseID   *int64   `json:"to_warehouse_id"`
+	Quantity        *int64   `json:"quantity"`
+	SerialNumbers   []string `json:"serial_numbers"`
+	Reason          string   `json:"reason"`
+}
+
+func validCoordinates(latitude, longitude *float64) string {
+	if latitude != nil && (*latitude < -90 || *latitude > 90) {
+		return "Latitude must be between -90 and 90"
+	}
+	if longitude != nil && (*longitude < -180 || *longitude > 180) {
+		return "Longitude must be between -180 and 180"
+	}
+	return ""
+}
+
+func (s *Server) addWarehouse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var wh Warehouse
+	if err := json.NewDecoder(r.Body).Decode(&wh); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if wh.Name == nil || strings.TrimSpace(*wh.Name) == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Name cannot be empty")
+		return
+	}
+	if msg := validCoordinates(wh.Latitude, wh.Longitude); msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	address := ""
+	if wh.Address != nil {
+		address = strings.TrimSpace(*wh.Address)
+	}
+
+	id, err := s.db(r).AddWarehouse(strings.TrimSpace(*wh.Name), address, wh.Latitude, wh.Longitude)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s created new warehouse with name %s and id %d", user, *wh.Name, id))
+}
+
+func (s *Server) updateWarehouse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var wh Warehouse
+	if err := json.NewDecoder(r.Body).Decode(&wh); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if wh.Name != nil && strings.TrimSpace(*wh.Name) == "" {
+		s.respondWithError(w, http.StatusBadRequest, "Name cannot be empty")
+		return
+	}
+	if msg := validCoordinates(wh.Latitude, wh.Longitude); msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.db(r).UpdateWarehouse(wh.ID, wh.Name, wh.Address, wh.Latitude, wh.Longitude, wh.IsDefault); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondNoContent(w)
+	s.logger(r).Info(fmt.Sprintf("User %s updated information of warehouse with id %d", user, wh.ID))
+}
+
+func (s *Server) showWarehouses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouses, err := s.db(r).ShowWarehouses()
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(warehouses); err != nil {
+		s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested information on warehouses", user))
+}
+
+func (s *Server) exportWarehouseStockCSV(w io.Writer, stock []database.WarehouseStock) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	headers := []string{"WarehouseID", "WarehouseName", "ProductID", "ProductName", "Quantity", "UpdatedAt"}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	for _, st := range stock {
+		record := []string{
+			fmt.Sprintf("%d", st.WarehouseID),
+			st.WarehouseName,
+			fmt.Sprintf("%d", st.ProductID),
+			st.ProductName,
+			fmt.Sprintf("%d", st.Quantity),
+			st.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) exportWarehouseStockExcel(w io.Writer, stock []database.WarehouseStock) error {
+	f := excelize.NewFile()
+	sheetName := fmt.Sprintf("WarehouseStock-%d", time.Now().Unix())
+	f.NewSheet(sheetName)
+	f.SetActiveSheet(f.NewSheet(sheetName))
+
+	headers := []string{"WarehouseID", "WarehouseName", "ProductID", "ProductName", "Quantity", "UpdatedAt"}
+	for i, h := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, h)
+	}
+
+	for i, st := range stock {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), st.WarehouseID)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), st.WarehouseName)
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), st.ProductID)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), st.ProductName)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), st.Quantity)
+		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), st.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := f.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) showWarehouseStock(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	warehouseID, err := parseWarehouseID(r)
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid warehouse_id value")
+		return
+	}
+
+	var productID *int64
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondWithError(w, http.StatusBadRequest, "Invalid product_id value")
+			return
+		}
+		productID = &id
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	stock, err := s.db(r).ShowWarehouseStock(warehouseID, productID)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Error encoding response data")
+			return
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := s.exportWarehouseStockCSV(w, stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	case "excel":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"warehouse_stock-%d.xlsx\"", time.Now().Unix()))
+		if err := s.exportWarehouseStockExcel(w, stock); err != nil {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to generate Excel file")
+			return
+		}
+	default:
+		s.respondWithError(w, http.StatusBadRequest, "Invalid format specified")
+		return
+	}
+	s.logger(r).Info(fmt.Sprintf("User %s requested warehouse stock in %s format", user, format))
+}
+
+func (s *Server) transferStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	user, err := s.getUserFromToken(r)
+	if err != nil || user == "" {
+		s.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var t StockTransfer
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Troubles with parsing data")
+		return
+	}
+
+	if t.ProductID == nil || t.FromWarehouseID == nil || t.ToWarehouseID == nil || t.Quantity == nil {
+		s.respondWithError(w, http.StatusBadRequest, "Not enough information to create")
+		return
+	}
+	if *t.Quantity <= 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Transfer quantity must be positive")
+		return
+	}
+	if *t.FromWarehouseID == *t.ToWarehouseID {
+		s.respondWithError(w, http.StatusBadRequest, "Source and destination warehouses must differ")
+		return
+	}
+
+	serials, msg := cleanSerials(t.SerialNumbers)
+	if msg != "" {
+		s.respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if exists, err := s.db(r).CheckProductExists(*t.ProductID); err != nil {
+		s.respondWithDBError(w, err)
+		return
+	} else if !exists {
+		s.respondWithErrorCode(w, http.StatusUnprocessableEntity, codeConstraint, fmt.Sprintf("product with ID %d does not exist", *t.ProductID), "fk_stock_transfers_products")
+		return
+	}
+
+	id, err := s.db(r).TransferStock(*t.ProductID, *t.FromWarehouseID, *t.ToWarehouseID, *t.Quantity, serials, strings.TrimSpace(t.Reason), user)
+	if err != nil {
+		s.respondWithDBError(w, err)
+		return
+	}
+
+	s.respondWithNew(w, id)
+	s.logger(r).Info(fmt.Sprintf("User %s transferred %d units of product %d from warehouse %d to warehouse %d", user, *t.Quantity, *t.ProductID, *t.FromWarehouseID, *t.ToWarehouseID))
+}
@@ -2,15 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (s *Server) respondWithError(w http.ResponseWriter, status int, error string) {
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"status": status,
-		"error":  error,
-	})
+	s.respondWithErrorCode(w, status, statusCode(status), error, "")
 }
 
 func (s *Server) respondWithToken(w http.ResponseWriter, token string) {
@@ -33,12 +32,13 @@ func (s *Server) respondNoContent(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *Server) respondNewOrder(w http.ResponseWriter, idOrder int64, idOrderDetail int64) {
+func (s *Server) respondNewOrder(w http.ResponseWriter, idOrder int64, idOrderDetail int64, orderStatus string) {
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"status":          http.StatusCreated,
 		"order_id":        idOrder,
 		"order_detail_id": idOrderDetail,
+		"order_status":    orderStatus,
 	})
 }
 
@@ -49,3 +49,74 @@ func (s *Server) respondWithStatus(w http.ResponseWriter, status int, message st
 		"message": message,
 	})
 }
+
+func parseIncludeDeleted(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseWarehouseID reads the optional warehouse_id query parameter.
+func parseWarehouseID(r *http.Request) (*int64, error) {
+	value := r.URL.Query().Get("warehouse_id")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// parseReportBound reads the optional time query parameter name, given as
+// RFC3339 or as a date. A date is the start of that day in UTC, or with
+// endOfDay set the start of the next day, so that a date range includes its
+// last day.
+func parseReportBound(r *http.Request, name string, endOfDay bool) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatOptionalDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+func formatOptionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func formatOptionalAmount(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
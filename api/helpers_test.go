@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseReportBound(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		endOfDay bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "missing", value: ""},
+		{name: "date-time", value: "2024-05-01T10:30:00Z", want: "2024-05-01T10:30:00Z"},
+		{name: "date-time at end", value: "2024-05-01T10:30:00+02:00", endOfDay: true, want: "2024-05-01T08:30:00Z"},
+		{name: "date", value: "2024-05-01", want: "2024-05-01T00:00:00Z"},
+		{name: "date at end", value: "2024-05-31", endOfDay: true, want: "2024-06-01T00:00:00Z"},
+		{name: "invalid", value: "05/01/2024", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/sales_report?from="+url.QueryEscape(tt.value), nil)
+			got, err := parseReportBound(r, "from", tt.endOfDay)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseReportBound() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReportBound() error = %v", err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("parseReportBound() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.UTC().Format(time.RFC3339) != tt.want {
+				t.Fatalf("parseReportBound() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,85 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 )
 
+const requestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type loggerKey struct{}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// requestID tags every request with an id, taken from the client's
+// X-Request-ID header when it looks sane or generated otherwise. The id is
+// echoed back in the response header, included in error bodies and attached
+// to the logger returned by s.logger for the rest of the request.
+func (s *Server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		log := s.Log.With(slog.String("request_id", id))
+		r = r.WithContext(context.WithValue(r.Context(), loggerKey{}, log))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		log.Info("request completed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// logger returns the request-scoped logger installed by requestID, falling
+// back to the server logger outside of a request.
+func (s *Server) logger(r *http.Request) *slog.Logger {
+	if log, ok := r.Context().Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return s.Log
+}
+
+// db returns the database with the request-scoped logger, so that errors
+// logged by the database layer carry the request_id as well.
+func (s *Server) db(r *http.Request) *database.Database {
+	return s.DB.WithLogger(s.logger(r))
+}
+
 func (s *Server) isAuthorized(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Authorization") == "" {
@@ -33,6 +105,23 @@ func (s *Server) isAuthorized(next http.Handler) http.Handler {
 	})
 }
 
+func (s *Server) requireDatabase(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/ready" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !s.DB.IsReady() {
+			w.Header().Set("Retry-After", "5")
+			s.respondWithError(w, http.StatusServiceUnavailable, "Database is not available yet, try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) generateJWT(u TrustedUser) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
@@ -45,7 +134,7 @@ func (s *Server) generateJWT(u TrustedUser) (string, error) {
 	tokenString, err := token.SignedString([]byte(s.SecretKey))
 
 	if err != nil {
-		s.Log.Error("error routes side -> generatedJWT()", err)
+		s.Log.Error("error routes side -> generatedJWT()", slog.String("error", err.Error()))
 		return "", err
 	}
 
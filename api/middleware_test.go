@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDOnDatabaseLogLines(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	db := database.InitDatabase(config.DatabaseConfig{
+		Host: "127.0.0.1", Port: "1", User: "postgres", Password: "postgres", Name: "postgres",
+	}, log)
+	defer db.Close()
+	db.SetReady(true)
+
+	s := NewServer(log, db, &config.Config{SecretKey: "secret"})
+	token, err := s.generateJWT(TrustedUser{Login: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/show_categories", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(requestIDHeader, "test-request-1")
+	s.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var databaseLines int
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("log line %q: %v", scanner.Text(), err)
+		}
+		msg, _ := line["msg"].(string)
+		if !strings.HasPrefix(msg, "Database ") {
+			continue
+		}
+		databaseLines++
+		if line["request_id"] != "test-request-1" {
+			t.Errorf("database log line %q has request_id %v, want test-request-1", msg, line["request_id"])
+		}
+	}
+	if databaseLines == 0 {
+		t.Fatalf("no database log lines written, log:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"net/http"
+	"strconv"
+)
+
+// pageResponse is the JSON envelope of every paginated list. NextCursor is
+// omitted on the last page.
+type pageResponse struct {
+	Items      any    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parsePage reads the limit, sort, order, cursor and filter query parameters
+// shared by all list endpoints. Sort and filter fields are validated by the
+// database layer against the whitelist of the listed entity.
+func parsePage(r *http.Request) (database.Page, error) {
+	var page database.Page
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		lmt, err := strconv.Atoi(l)
+		if err != nil || lmt <= 0 {
+			return page, errors.New("Invalid limit value")
+		}
+		page.Limit = &lmt
+	}
+
+	switch order := r.URL.Query().Get("order"); order {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, errors.New("Invalid order value, use asc or desc")
+	}
+
+	filters, err := database.ParseFilters(r.URL.Query().Get("filter"))
+	if err != nil {
+		return page, err
+	}
+
+	page.Sort = r.URL.Query().Get("sort")
+	page.Cursor = r.URL.Query().Get("cursor")
+	page.Filters = filters
+	return page, nil
+}
+
+// setNextLink advertises the next page in a Link header (RFC 8288), keeping
+// all other query parameters of the current request.
+func setNextLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	u := *r.URL
+	q := u.Query()
+	q.Set("cursor", next)
+	u.RawQuery = q.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.RequestURI()))
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	r := httptest.NewRequest("GET", "/show_products?limit=5&sort=price&order=desc&cursor=abc&filter=price%3E%3D10", nil)
+	page, err := parsePage(r)
+	if err != nil {
+		t.Fatalf("parsePage() error = %v", err)
+	}
+	if page.Limit == nil || *page.Limit != 5 || page.Sort != "price" || !page.Desc || page.Cursor != "abc" {
+		t.Errorf("parsePage() = %+v", page)
+	}
+	if len(page.Filters) != 1 || page.Filters[0].Field != "price" || page.Filters[0].Op != ">=" {
+		t.Errorf("parsePage() filters = %+v, want price>=10", page.Filters)
+	}
+}
+
+func TestParsePageRejects(t *testing.T) {
+	for _, query := range []string{
+		"limit=0",
+		"limit=-1",
+		"limit=ten",
+		"order=up",
+		"filter=price",
+	} {
+		t.Run(query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/show_products?"+query, nil)
+			if _, err := parsePage(r); err == nil {
+				t.Fatalf("parsePage(%s) accepted the request", query)
+			}
+		})
+	}
+}
+
+func TestSetNextLink(t *testing.T) {
+	r := httptest.NewRequest("GET", "/show_products?limit=5&sort=name&cursor=old", nil)
+
+	w := httptest.NewRecorder()
+	setNextLink(w, r, "")
+	if link := w.Header().Get("Link"); link != "" {
+		t.Fatalf("Link = %q on the last page, want none", link)
+	}
+
+	w = httptest.NewRecorder()
+	setNextLink(w, r, "next-cursor")
+	want := "</show_products?" + url.Values{"limit": {"5"}, "sort": {"name"}, "cursor": {"next-cursor"}}.Encode() + ">; rel=\"next\""
+	if link := w.Header().Get("Link"); link != want {
+		t.Fatalf("Link = %q, want %q", link, want)
+	}
+}
+
+func TestFilterErrorsAreBadRequest(t *testing.T) {
+	for _, expr := range []string{"Price=1", "price=", "price#1"} {
+		_, err := database.ParseFilters(expr)
+		if status, code := errorStatus(err); status != http.StatusBadRequest || code != codeBadRequest {
+			t.Errorf("filter %q maps to %d %s, want %d %s", expr, status, code, http.StatusBadRequest, codeBadRequest)
+		}
+	}
+}
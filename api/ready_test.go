@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database/dbtest"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// startServer starts the API against cfg the way cmd/app does: the server
+// answers at once, and the database is marked ready once it is reachable.
+func startServer(t *testing.T, cfg config.DatabaseConfig) *httptest.Server {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := database.InitDatabase(cfg, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := db.WaitForConnection(ctx); err == nil {
+			db.SetReady(true)
+		}
+	}()
+
+	server := httptest.NewServer(NewServer(log, db, &config.Config{SecretKey: "secret", DatabaseConfig: cfg}).Router)
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-done
+		db.Close()
+	})
+	return server
+}
+
+func getStatus(t *testing.T, url string) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestReadyWhileDatabaseIsDown(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              "1",
+		User:              "postgres",
+		Password:          "postgres",
+		Name:              "postgres",
+		ConnectRetries:    -1,
+		ConnectBackoff:    10 * time.Millisecond,
+		ConnectMaxBackoff: 100 * time.Millisecond,
+	}
+	server := startServer(t, cfg)
+
+	resp := getStatus(t, server.URL+"/ready")
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("GET /ready = %d with Retry-After %q, want 503 with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := getStatus(t, server.URL+"/show_products"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /show_products = %d, want 503", resp.StatusCode)
+	}
+	if resp := getStatus(t, server.URL+"/health"); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestReadyAfterDatabaseComesUp(t *testing.T) {
+	cfg := dbtest.Config(t)
+	proxy := dbtest.NewProxy(t, cfg)
+	server := startServer(t, proxy.Config(cfg))
+
+	time.Sleep(100 * time.Millisecond)
+	if resp := getStatus(t, server.URL+"/ready"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GET /ready before the database is up = %d, want 503", resp.StatusCode)
+	}
+
+	proxy.Start(t)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := getStatus(t, server.URL+"/ready")
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /ready = %d after the database came up, want 200", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,26 +5,91 @@ import (
 )
 
 func (s *Server) routes() {
+	s.Router.Use(s.requestID)
+	s.Router.Use(s.requireDatabase)
+
+	s.Router.HandleFunc("/health", s.health).Methods("GET")
+	s.Router.HandleFunc("/ready", s.readiness).Methods("GET")
+
 	s.Router.HandleFunc("/login", s.login).Methods("POST")
 
 	s.Router.Handle("/add_supplier", s.isAuthorized(http.HandlerFunc(s.addSupplier))).Methods("POST")
 	s.Router.Handle("/delete_supplier", s.isAuthorized(http.HandlerFunc(s.deleteSupplier))).Methods("POST")
+	s.Router.Handle("/restore_supplier", s.isAuthorized(http.HandlerFunc(s.restoreSupplier))).Methods("POST")
 	s.Router.Handle("/update_supplier", s.isAuthorized(http.HandlerFunc(s.updateSupplier))).Methods("POST")
 	s.Router.Handle("/show_suppliers", s.isAuthorized(http.HandlerFunc(s.showSuppliers))).Methods("GET")
 
+	s.Router.Handle("/add_supplier_product", s.isAuthorized(http.HandlerFunc(s.addSupplierProduct))).Methods("POST")
+	s.Router.Handle("/delete_supplier_product", s.isAuthorized(http.HandlerFunc(s.deleteSupplierProduct))).Methods("POST")
+	s.Router.Handle("/update_supplier_product", s.isAuthorized(http.HandlerFunc(s.updateSupplierProduct))).Methods("POST")
+	s.Router.Handle("/show_supplier_products", s.isAuthorized(http.HandlerFunc(s.showSupplierProducts))).Methods("GET")
+
 	s.Router.Handle("/add_customer", s.isAuthorized(http.HandlerFunc(s.addCustomer))).Methods("POST")
 	s.Router.Handle("/delete_customer", s.isAuthorized(http.HandlerFunc(s.deleteCustomer))).Methods("POST")
+	s.Router.Handle("/restore_customer", s.isAuthorized(http.HandlerFunc(s.restoreCustomer))).Methods("POST")
 	s.Router.Handle("/update_customer", s.isAuthorized(http.HandlerFunc(s.updateCustomer))).Methods("POST")
 	s.Router.Handle("/show_customers", s.isAuthorized(http.HandlerFunc(s.showCustomers))).Methods("GET")
 
+	s.Router.Handle("/add_category", s.isAuthorized(http.HandlerFunc(s.addCategory))).Methods("POST")
+	s.Router.Handle("/delete_category", s.isAuthorized(http.HandlerFunc(s.deleteCategory))).Methods("POST")
+	s.Router.Handle("/update_category", s.isAuthorized(http.HandlerFunc(s.updateCategory))).Methods("POST")
+	s.Router.Handle("/show_categories", s.isAuthorized(http.HandlerFunc(s.showCategories))).Methods("GET")
+
 	s.Router.Handle("/add_product", s.isAuthorized(http.HandlerFunc(s.addProduct))).Methods("POST")
 	s.Router.Handle("/delete_product", s.isAuthorized(http.HandlerFunc(s.deleteProduct))).Methods("POST")
+	s.Router.Handle("/restore_product", s.isAuthorized(http.HandlerFunc(s.restoreProduct))).Methods("POST")
 	s.Router.Handle("/update_product", s.isAuthorized(http.HandlerFunc(s.updateProduct))).Methods("POST")
 	s.Router.Handle("/show_products", s.isAuthorized(http.HandlerFunc(s.showProducts))).Methods("GET")
 	s.Router.Handle("/show_in_stock_products", s.isAuthorized(http.HandlerFunc(s.showInStockProducts))).Methods("GET")
 	s.Router.Handle("/show_category_products", s.isAuthorized(http.HandlerFunc(s.showCategoryProducts))).Methods("GET")
 	s.Router.Handle("/show_price_products", s.isAuthorized(http.HandlerFunc(s.showPriceRangeProducts))).Methods("GET")
+	s.Router.Handle("/search_products", s.isAuthorized(http.HandlerFunc(s.searchProducts))).Methods("GET")
+	s.Router.Handle("/product_by_sku", s.isAuthorized(http.HandlerFunc(s.productBySKU))).Methods("GET")
+	s.Router.Handle("/product_by_barcode", s.isAuthorized(http.HandlerFunc(s.productByBarcode))).Methods("GET")
+	s.Router.Handle("/schedule_price_change", s.isAuthorized(http.HandlerFunc(s.schedulePriceChange))).Methods("POST")
+	s.Router.Handle("/cancel_price_change", s.isAuthorized(http.HandlerFunc(s.cancelPriceChange))).Methods("POST")
+	s.Router.Handle("/product_price_history", s.isAuthorized(http.HandlerFunc(s.productPriceHistory))).Methods("GET")
+	s.Router.Handle("/update_reorder_policy", s.isAuthorized(http.HandlerFunc(s.updateReorderPolicy))).Methods("POST")
 	s.Router.Handle("/show_purchase_request", s.isAuthorized(http.HandlerFunc(s.showPurchaseRequests))).Methods("GET")
+	s.Router.Handle("/purchase_suggestions", s.isAuthorized(http.HandlerFunc(s.showPurchaseSuggestions))).Methods("GET")
+
+	s.Router.Handle("/add_purchase_order", s.isAuthorized(http.HandlerFunc(s.addPurchaseOrder))).Methods("POST")
+	s.Router.Handle("/add_purchase_orders_from_requests", s.isAuthorized(http.HandlerFunc(s.addPurchaseOrdersFromRequests))).Methods("POST")
+	s.Router.Handle("/update_purchase_order", s.isAuthorized(http.HandlerFunc(s.updatePurchaseOrderStatus))).Methods("POST")
+	s.Router.Handle("/show_purchase_orders", s.isAuthorized(http.HandlerFunc(s.showPurchaseOrders))).Methods("GET")
+	s.Router.Handle("/show_purchase_order", s.isAuthorized(http.HandlerFunc(s.showPurchaseOrder))).Methods("GET")
+	s.Router.Handle("/receive_goods", s.isAuthorized(http.HandlerFunc(s.receiveGoods))).Methods("POST")
+	s.Router.Handle("/show_goods_receipts", s.isAuthorized(http.HandlerFunc(s.showGoodsReceipts))).Methods("GET")
+
+	s.Router.Handle("/add_warehouse", s.isAuthorized(http.HandlerFunc(s.addWarehouse))).Methods("POST")
+	s.Router.Handle("/update_warehouse", s.isAuthorized(http.HandlerFunc(s.updateWarehouse))).Methods("POST")
+	s.Router.Handle("/show_warehouses", s.isAuthorized(http.HandlerFunc(s.showWarehouses))).Methods("GET")
+	s.Router.Handle("/show_warehouse_stock", s.isAuthorized(http.HandlerFunc(s.showWarehouseStock))).Methods("GET")
+	s.Router.Handle("/transfer_stock", s.isAuthorized(http.HandlerFunc(s.transferStock))).Methods("POST")
+
+	s.Router.Handle("/add_bin_location", s.isAuthorized(http.HandlerFunc(s.addBinLocation))).Methods("POST")
+	s.Router.Handle("/update_bin_location", s.isAuthorized(http.HandlerFunc(s.updateBinLocation))).Methods("POST")
+	s.Router.Handle("/show_bin_locations", s.isAuthorized(http.HandlerFunc(s.showBinLocations))).Methods("GET")
+	s.Router.Handle("/show_bin_stock", s.isAuthorized(http.HandlerFunc(s.showBinStock))).Methods("GET")
+	s.Router.Handle("/put_away", s.isAuthorized(http.HandlerFunc(s.putAway))).Methods("POST")
+	s.Router.Handle("/move_bin_stock", s.isAuthorized(http.HandlerFunc(s.moveBinStock))).Methods("POST")
+	s.Router.Handle("/pick_list", s.isAuthorized(http.HandlerFunc(s.pickList))).Methods("GET")
+	s.Router.Handle("/confirm_pick_list", s.isAuthorized(http.HandlerFunc(s.confirmPickList))).Methods("POST")
+
+	s.Router.Handle("/show_lots", s.isAuthorized(http.HandlerFunc(s.showLots))).Methods("GET")
+	s.Router.Handle("/expiring_lots", s.isAuthorized(http.HandlerFunc(s.expiringLots))).Methods("GET")
+
+	s.Router.Handle("/trace_serial", s.isAuthorized(http.HandlerFunc(s.traceSerial))).Methods("GET")
+
+	s.Router.Handle("/add_reservation", s.isAuthorized(http.HandlerFunc(s.addReservation))).Methods("POST")
+	s.Router.Handle("/release_reservation", s.isAuthorized(http.HandlerFunc(s.releaseReservation))).Methods("POST")
+	s.Router.Handle("/convert_reservation", s.isAuthorized(http.HandlerFunc(s.convertReservation))).Methods("POST")
+	s.Router.Handle("/show_reservations", s.isAuthorized(http.HandlerFunc(s.showReservations))).Methods("GET")
+
+	s.Router.Handle("/stock_movements", s.isAuthorized(http.HandlerFunc(s.showStockMovements))).Methods("GET")
+	s.Router.Handle("/adjust_stock", s.isAuthorized(http.HandlerFunc(s.adjustStock))).Methods("POST")
+	s.Router.Handle("/stock_reconciliation", s.isAuthorized(http.HandlerFunc(s.stockReconciliation))).Methods("GET")
+	s.Router.Handle("/reconcile_stock", s.isAuthorized(http.HandlerFunc(s.reconcileStock))).Methods("POST")
 
 	s.Router.Handle("/add_order", s.isAuthorized(http.HandlerFunc(s.addOrder))).Methods("POST")
 	s.Router.Handle("/refund_order", s.isAuthorized(http.HandlerFunc(s.refundOrder))).Methods("POST")
@@ -38,4 +103,7 @@ func (s *Server) routes() {
 
 	s.Router.Handle("/sales_report", s.isAuthorized(http.HandlerFunc(s.showSalesReport))).Methods("GET")
 	s.Router.Handle("/requirements_report", s.isAuthorized(http.HandlerFunc(s.showRequirementsReport))).Methods("GET")
+	s.Router.Handle("/demand_forecast", s.isAuthorized(http.HandlerFunc(s.showDemandForecast))).Methods("GET")
+	s.Router.Handle("/backorder_report", s.isAuthorized(http.HandlerFunc(s.showBackorderReport))).Methods("GET")
+	s.Router.Handle("/inventory_valuation", s.isAuthorized(http.HandlerFunc(s.showInventoryValuation))).Methods("GET")
 }
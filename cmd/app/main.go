@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/api"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
 	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database"
@@ -24,12 +25,27 @@ func main() {
 	log.Debug("logger debug mode enabled")
 
 	db := database.InitDatabase(cfg.DatabaseConfig, log)
-	db.InitTables()
-	db.InitTrustedUsers()
+
+	// The server starts before the database answers so that /ready can
+	// report the waiting state instead of the container crash-looping.
+	go func() {
+		ctx := context.Background()
+		if err := db.WaitForConnection(ctx); err != nil {
+			log.Error("Failed to connect to the database", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		db.InitTables()
+		db.InitTrustedUsers()
+		db.SetReady(true)
+		go db.RunPurge(ctx)
+		go db.RunPriceScheduler(ctx)
+		go db.RunReservationSweeper(ctx)
+		db.MonitorConnection(ctx)
+	}()
 
 	server := api.NewServer(log, db, cfg)
 	if err := server.Start(cfg.HTTPServer.Address); err != nil {
-		log.Error("Failed to start server", err)
+		log.Error("Failed to start server", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 	log.Info("successfully start server")
@@ -22,6 +22,22 @@ type DatabaseConfig struct {
 	Password string `yaml:"password" env-required:"true"`
 	Port     string `yaml:"port"     env-required:"true"`
 	Host     string `yaml:"host"     env-required:"true"`
+
+	// ConnectRetries is the number of connection attempts at startup; a
+	// negative value retries forever. Zero is read as unset and takes the
+	// default, as with every other setting.
+	ConnectRetries      int           `yaml:"connect_retries"       env-default:"20"`
+	ConnectBackoff      time.Duration `yaml:"connect_backoff"       env-default:"1s"`
+	ConnectMaxBackoff   time.Duration `yaml:"connect_max_backoff"   env-default:"30s"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env-default:"10s"`
+
+	DeletedRetention time.Duration `yaml:"deleted_retention" env-default:"720h"`
+	PurgeInterval    time.Duration `yaml:"purge_interval"    env-default:"24h"`
+
+	PriceScheduleInterval time.Duration `yaml:"price_schedule_interval" env-default:"1m"`
+
+	ReservationTTL           time.Duration `yaml:"reservation_ttl"            env-default:"15m"`
+	ReservationSweepInterval time.Duration `yaml:"reservation_sweep_interval" env-default:"1m"`
 }
 
 type HTTPServer struct {
@@ -30,6 +46,47 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 }
 
+// Load reads the configuration from a YAML file, filling in defaults and
+// environment overrides, and checks it.
+func Load(configPath string) (*Config, error) {
+	var cfg Config
+	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate rejects settings the application cannot run with. Intervals
+// drive tickers, which panic unless they are positive, and a retention that
+// is not positive would purge deleted rows right away.
+func (cfg *Config) validate() error {
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connect_backoff", cfg.ConnectBackoff},
+		{"connect_max_backoff", cfg.ConnectMaxBackoff},
+		{"health_check_interval", cfg.HealthCheckInterval},
+		{"deleted_retention", cfg.DeletedRetention},
+		{"purge_interval", cfg.PurgeInterval},
+		{"price_schedule_interval", cfg.PriceScheduleInterval},
+		{"reservation_ttl", cfg.ReservationTTL},
+		{"reservation_sweep_interval", cfg.ReservationSweepInterval},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return fmt.Errorf("database.%s must be positive, got %s", d.name, d.value)
+		}
+	}
+	if cfg.ConnectMaxBackoff < cfg.ConnectBackoff {
+		return fmt.Errorf("database.connect_max_backoff must be at least connect_backoff (%s), got %s", cfg.ConnectBackoff, cfg.ConnectMaxBackoff)
+	}
+	return nil
+}
+
 func MustLoad() *Config {
 	exePath, err := os.Executable()
 	if err != nil {
@@ -44,12 +101,10 @@ func MustLoad() *Config {
 		log.Fatalf("error opening config file: %s", err)
 	}
 
-	var cfg Config
-
-	err = cleanenv.ReadConfig(configPath, &cfg)
+	cfg, err := Load(configPath)
 	if err != nil {
 		log.Fatalf("error reading config file: %s", err)
 	}
 
-	return &cfg
+	return cfg
 }
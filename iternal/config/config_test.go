@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+secret_key: "secret"
+database:
+  name: db_name
+  user: db_admin
+  password: db_password
+  host: localhost
+  port: 5432
+`
+
+func writeConfig(t *testing.T, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(baseConfig+extra), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ConnectRetries != 20 {
+		t.Errorf("ConnectRetries = %d, want 20", cfg.ConnectRetries)
+	}
+	if cfg.HealthCheckInterval != 10*time.Second {
+		t.Errorf("HealthCheckInterval = %s, want 10s", cfg.HealthCheckInterval)
+	}
+}
+
+func TestLoadRetryForever(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "  connect_retries: -1\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ConnectRetries != -1 {
+		t.Errorf("ConnectRetries = %d, want -1", cfg.ConnectRetries)
+	}
+}
+
+func TestLoadRejectsNonPositiveIntervals(t *testing.T) {
+	for _, name := range []string{
+		"connect_backoff",
+		"connect_max_backoff",
+		"health_check_interval",
+		"deleted_retention",
+		"purge_interval",
+		"price_schedule_interval",
+		"reservation_ttl",
+		"reservation_sweep_interval",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, "  "+name+": -1s\n"))
+			if err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("Load() error = %v, want an error naming %s", err, name)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsMaxBackoffBelowBackoff(t *testing.T) {
+	_, err := Load(writeConfig(t, "  connect_backoff: 10s\n  connect_max_backoff: 5s\n"))
+	if err == nil || !strings.Contains(err.Error(), "connect_max_backoff") {
+		t.Fatalf("Load() error = %v, want an error naming connect_max_backoff", err)
+	}
+
+	cfg, err := Load(writeConfig(t, "  connect_backoff: 5s\n  connect_max_backoff: 5s\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v with equal backoffs", err)
+	}
+	if cfg.ConnectMaxBackoff != 5*time.Second {
+		t.Errorf("ConnectMaxBackoff = %s, want 5s", cfg.ConnectMaxBackoff)
+	}
+}
@@ -1,28 +1,93 @@
 package database
 
-import "os"
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+	"time"
+)
 
+const (
+	SalesByDay      = "day"
+	SalesByWeek     = "week"
+	SalesByMonth    = "month"
+	SalesByProduct  = "product"
+	SalesByCategory = "category"
+	SalesByCustomer = "customer"
+	SalesBySupplier = "supplier"
+)
+
+// salesReportFiles are the queries of the groupings that are not periods.
+var salesReportFiles = map[string]string{
+	SalesByProduct:  "sales_report.sql",
+	SalesByCategory: "sales_by_category_report.sql",
+	SalesByCustomer: "sales_by_customer_report.sql",
+	SalesBySupplier: "sales_by_supplier_report.sql",
+}
+
+// SalesReport sums the sales of one group: a product, category, customer or
+// supplier, named by Name, or a day, week or month starting at Period. Only
+// the ID of the grouping is set; sales of products without a category are
+// reported without a CategoryID. Costs come from the cost price recorded on
+// each order line, or from the current supplier catalog for lines sold
+// before costs were tracked. Lines without any known cost are left out of
+// TotalCost and Margin, which are nil when no line has a cost.
 type SalesReport struct {
-	ProductID  int64   `json:"product_id"`
-	Name       string  `json:"name"`
-	TotalSales float64 `json:"total_sales"`
+	Period        *time.Time `json:"period,omitempty"`
+	ProductID     *int64     `json:"product_id,omitempty"`
+	CategoryID    *int64     `json:"category_id,omitempty"`
+	CustomerID    *int64     `json:"customer_id,omitempty"`
+	SupplierID    *int64     `json:"supplier_id,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Units         int64      `json:"units"`
+	TotalSales    float64    `json:"total_sales"`
+	TotalCost     *float64   `json:"total_cost"`
+	Margin        *float64   `json:"margin"`
+	MarginPercent *float64   `json:"margin_percent"`
 }
 
+// ProductSalesAverage is the average quantity of one product per order line,
+// and the average quantity sold per day over the window of the report.
+// Refunded and cancelled orders do not count as sold per day.
 type ProductSalesAverage struct {
-	ProductID int64   `json:"product_id"`
-	AvgSold   float64 `json:"avg_sold"`
+	ProductID    int64   `json:"product_id"`
+	AvgSold      float64 `json:"avg_sold"`
+	AvgDailySold float64 `json:"avg_daily_sold"`
 }
 
-func (db *Database) FetchSalesReport() ([]SalesReport, error) {
-	query, err := os.ReadFile(analyticsPath + "sales_report.sql")
+// FetchSalesReport sums the sales of orders placed from from up to to, both
+// optional, grouped by groupBy, one of the SalesBy groupings. Refunded,
+// cancelled and backordered orders are not sales. Grouped by product, every
+// product is listed, also without sales; other groups are listed only if
+// they have sales. Sales of soft-deleted products, and of soft-deleted
+// customers and suppliers in their groupings, are left out unless
+// includeDeleted is set.
+func (db *Database) FetchSalesReport(from, to *time.Time, groupBy string, includeDeleted bool) ([]SalesReport, error) {
+	fromNull := sql.NullTime{Valid: from != nil}
+	if from != nil {
+		fromNull.Time = *from
+	}
+	toNull := sql.NullTime{Valid: to != nil}
+	if to != nil {
+		toNull.Time = *to
+	}
+
+	file, ok := salesReportFiles[groupBy]
+	args := []any{fromNull, toNull, includeDeleted}
+	if !ok {
+		file = "sales_by_period_report.sql"
+		args = append(args, groupBy)
+	}
+
+	query, err := os.ReadFile(analyticsPath + file)
 	if err != nil {
-		db.Log.Error("Database FetchSalesReport() -> Read SQL file", err)
+		db.Log.Error("Database FetchSalesReport() -> Read SQL file", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	rows, err := db.Query(string(query))
+	rows, err := db.Query(string(query), args...)
 	if err != nil {
-		db.Log.Error("Database FetchSalesReport() -> tx.Query()", err)
+		db.Log.Error("Database FetchSalesReport() -> tx.Query()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	defer rows.Close()
@@ -30,30 +95,63 @@ func (db *Database) FetchSalesReport() ([]SalesReport, error) {
 	var reports []SalesReport
 	for rows.Next() {
 		var report SalesReport
-		if err := rows.Scan(&report.ProductID, &report.Name, &report.TotalSales); err != nil {
-			db.Log.Error("Database FetchSalesReport() -> parsing rows", err)
+		var id sql.NullInt64
+		var period sql.NullTime
+		var totalCost, margin, marginPercent sql.NullFloat64
+		dest := []any{&id, &report.Name}
+		if !ok {
+			dest = []any{&period}
+		}
+		if err := rows.Scan(append(dest, &report.Units, &report.TotalSales, &totalCost, &margin, &marginPercent)...); err != nil {
+			db.Log.Error("Database FetchSalesReport() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
+		if period.Valid {
+			report.Period = &period.Time
+		}
+		if id.Valid {
+			switch groupBy {
+			case SalesByProduct:
+				report.ProductID = &id.Int64
+			case SalesByCategory:
+				report.CategoryID = &id.Int64
+			case SalesByCustomer:
+				report.CustomerID = &id.Int64
+			case SalesBySupplier:
+				report.SupplierID = &id.Int64
+			}
+		}
+		if totalCost.Valid {
+			report.TotalCost = &totalCost.Float64
+		}
+		if margin.Valid {
+			report.Margin = &margin.Float64
+		}
+		if marginPercent.Valid {
+			report.MarginPercent = &marginPercent.Float64
+		}
 		reports = append(reports, report)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database FetchSalesReport() -> rows.Err()", err)
+		db.Log.Error("Database FetchSalesReport() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return reports, nil
 }
 
-func (db *Database) FetchRequirementsReport() ([]ProductSalesAverage, error) {
+// FetchRequirementsReport averages the sales of every product sold, per day
+// over the last days days.
+func (db *Database) FetchRequirementsReport(days int64) ([]ProductSalesAverage, error) {
 	query, err := os.ReadFile(analyticsPath + "requirements_report.sql")
 	if err != nil {
-		db.Log.Error("Database FetchRequirementsReport() -> Read SQL file", err)
+		db.Log.Error("Database FetchRequirementsReport() -> Read SQL file", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	rows, err := db.Query(string(query))
+	rows, err := db.Query(string(query), days)
 	if err != nil {
-		db.Log.Error("Database FetchRequirementsReport() -> tx.Query()", err)
+		db.Log.Error("Database FetchRequirementsReport() -> tx.Query()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	defer rows.Close()
@@ -61,15 +159,15 @@ func (db *Database) FetchRequirementsReport() ([]ProductSalesAverage, error) {
 	var reports []ProductSalesAverage
 	for rows.Next() {
 		var report ProductSalesAverage
-		if err := rows.Scan(&report.ProductID, &report.AvgSold); err != nil {
-			db.Log.Error("Database FetchRequirementsReport() -> parsing rows", err)
+		if err := rows.Scan(&report.ProductID, &report.AvgSold, &report.AvgDailySold); err != nil {
+			db.Log.Error("Database FetchRequirementsReport() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
 		reports = append(reports, report)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database FetchRequirementsReport() -> rows.Err()", err)
+		db.Log.Error("Database FetchRequirementsReport() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return reports, nil
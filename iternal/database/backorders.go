@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Backorder is an order line waiting for stock. WarehouseID is the
+// warehouse the order asked for, or nil if any warehouse may fill it.
+// Ahead is the number of units of the product backordered before this
+// line, which are allocated first. Available is the stock of the product
+// not held by reservations, and OnOrder the units still expected on sent
+// purchase orders.
+type Backorder struct {
+	OrderID       int64     `json:"order_id"`
+	OrderDetailID int64     `json:"order_detail_id"`
+	CustomerID    int64     `json:"customer_id"`
+	CustomerName  string    `json:"customer_name"`
+	ProductID     int64     `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	WarehouseID   *int64    `json:"warehouse_id"`
+	Quantity      int64     `json:"quantity"`
+	Price         float64   `json:"price"`
+	OrderedAt     time.Time `json:"ordered_at"`
+	Ahead         int64     `json:"ahead"`
+	Available     int64     `json:"available"`
+	OnOrder       int64     `json:"on_order"`
+}
+
+// allowsBackorders tells whether a product may be ordered beyond its stock.
+func (db *Database) allowsBackorders(tx *sql.Tx, productID int64) (bool, error) {
+	query, err := os.ReadFile(productsPath + "backorders_products.sql")
+	if err != nil {
+		db.Log.Error("Database allowsBackorders() -> Read SQL file", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	var allowed bool
+	err = tx.QueryRow(string(query), productID).Scan(&allowed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrNoProductFound
+	}
+	return allowed, err
+}
+
+// AllowsBackorders tells whether a product may be ordered beyond its stock.
+func (db *Database) AllowsBackorders(productID int64) (bool, error) {
+	var allowed bool
+	err := db.WithTx(func(tx *sql.Tx) error {
+		var err error
+		allowed, err = db.allowsBackorders(tx, productID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		db.Log.Error("Database AllowsBackorders()", slog.String("error", err.Error()))
+	}
+	return allowed, err
+}
+
+// allocateBackorders ships the backorders of a product that can be filled
+// from a warehouse that just received stock and returns their order IDs.
+// Backorders are served first come, first served: the oldest one is filled
+// first, and allocation stops at the first one the warehouse cannot fill
+// whole, so that small later orders do not keep taking the stock a large
+// earlier one waits for. Backorders that asked for another warehouse are
+// left alone. An allocated order gets the warehouse and the status new.
+func (db *Database) allocateBackorders(tx *sql.Tx, productID, warehouseID int64, user string) ([]int64, error) {
+	lockQuery, err := os.ReadFile(backordersPath + "lock_backorders.sql")
+	if err != nil {
+		db.Log.Error("Database allocateBackorders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	allocateQuery, err := os.ReadFile(backordersPath + "allocate_backorders.sql")
+	if err != nil {
+		db.Log.Error("Database allocateBackorders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	type backorder struct {
+		orderID, orderDetailID, quantity int64
+	}
+
+	rows, err := tx.Query(string(lockQuery), productID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	var queue []backorder
+	for rows.Next() {
+		var b backorder
+		if err := rows.Scan(&b.orderID, &b.orderDetailID, &b.quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		queue = append(queue, b)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var allocated []int64
+	for _, b := range queue {
+		if _, err := db.allocateWarehouse(tx, productID, b.quantity, &warehouseID, nil, nil); errors.Is(err, ErrInsufficientStock) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := db.shipOrderLine(tx, b.orderID, b.orderDetailID, productID, warehouseID, b.quantity, "backorder allocated", user); errors.Is(err, ErrInsufficientStock) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec(string(allocateQuery), b.orderID, b.orderDetailID, warehouseID); err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, b.orderID)
+	}
+	return allocated, nil
+}
+
+// ShowBackorders lists the order lines waiting for stock, optionally of a
+// single product or customer, by product and then in the order they will be
+// allocated.
+func (db *Database) ShowBackorders(productID, customerID *int64) ([]Backorder, error) {
+	query, err := os.ReadFile(backordersPath + "report_backorders.sql")
+	if err != nil {
+		db.Log.Error("Database ShowBackorders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+	customerNull := sql.NullInt64{Valid: customerID != nil}
+	if customerID != nil {
+		customerNull.Int64 = *customerID
+	}
+
+	rows, err := db.Query(string(query), productNull, customerNull)
+	if err != nil {
+		db.Log.Error("Database ShowBackorders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var backorders []Backorder
+	for rows.Next() {
+		var b Backorder
+		var warehouseID sql.NullInt64
+		if err := rows.Scan(&b.OrderID, &b.OrderDetailID, &b.CustomerID, &b.CustomerName, &b.ProductID, &b.ProductName, &warehouseID,
+			&b.Quantity, &b.Price, &b.OrderedAt, &b.Ahead, &b.Available, &b.OnOrder); err != nil {
+			db.Log.Error("Database ShowBackorders() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if warehouseID.Valid {
+			b.WarehouseID = &warehouseID.Int64
+		}
+		backorders = append(backorders, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowBackorders() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return backorders, nil
+}
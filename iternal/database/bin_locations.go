@@ -0,0 +1,659 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// BinLocation is a storage place inside a warehouse, addressed by zone,
+// aisle, shelf and bin. Code joins the non-empty parts with dashes, for
+// example "A-03-2-B". PickSequence orders the bins along the walking route
+// through the warehouse; bins with equal sequence are walked in code order.
+type BinLocation struct {
+	BinLocationID int64     `json:"bin_location_id"`
+	WarehouseID   int64     `json:"warehouse_id"`
+	WarehouseName string    `json:"warehouse_name"`
+	Code          string    `json:"code"`
+	Zone          string    `json:"zone"`
+	Aisle         string    `json:"aisle"`
+	Shelf         string    `json:"shelf"`
+	Bin           string    `json:"bin"`
+	PickSequence  int       `json:"pick_sequence"`
+	TotalQuantity int64     `json:"total_quantity"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// BinStock is the stock of one product in one bin.
+type BinStock struct {
+	BinLocationID int64     `json:"bin_location_id"`
+	WarehouseID   int64     `json:"warehouse_id"`
+	Code          string    `json:"code"`
+	ProductID     int64     `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Quantity      int64     `json:"quantity"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PickLine tells the picker to take Quantity units of a product from a bin.
+// Stock of the order's warehouse that is not put away in any bin is listed
+// at the end with a nil BinLocationID and an empty Code.
+type PickLine struct {
+	Step          int    `json:"step"`
+	OrderDetailID int64  `json:"order_detail_id"`
+	WarehouseID   int64  `json:"warehouse_id"`
+	BinLocationID *int64 `json:"bin_location_id"`
+	Code          string `json:"code"`
+	ProductID     int64  `json:"product_id"`
+	ProductName   string `json:"product_name"`
+	Quantity      int64  `json:"quantity"`
+}
+
+// PickList is the order of an order's lines along the walking route.
+type PickList struct {
+	OrderID  int64      `json:"order_id"`
+	PickedAt *time.Time `json:"picked_at"`
+	Lines    []PickLine `json:"lines"`
+}
+
+var ErrNoBinLocationFound error = &Error{Kind: ErrNotFound, Message: "no bin location found with the provided ID"}
+var ErrOrderAlreadyPicked error = &Error{Kind: ErrConflict, Message: "the order has already been picked"}
+var errPickBackorderedOrder error = &Error{Kind: ErrConflict, Message: "a backordered order cannot be picked before stock is allocated to it"}
+var errBinsInDifferentWarehouses error = &Error{Kind: ErrInvalidArgument, Message: "both bins must be in the same warehouse"}
+
+func errNotEnoughInBin(binLocationID int64) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough product in bin %d", binLocationID)}
+}
+
+func errPickClosedOrder(status string) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("a %s order cannot be picked", status)}
+}
+
+func errPickLineWithoutWarehouse(orderDetailID int64) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("order line %d was sold before warehouses were tracked and has no warehouse to pick from", orderDetailID)}
+}
+
+func errNotEnoughUnbinned(warehouseID int64) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough product in warehouse %d left to put away", warehouseID)}
+}
+
+// binStop is a bin on the walking route together with the units of a
+// product it holds.
+type binStop struct {
+	id       int64
+	code     string
+	route    [4]string
+	sequence int
+	quantity int64
+}
+
+// before reports whether the bin is walked past before other.
+func (b binStop) before(other binStop) bool {
+	if b.sequence != other.sequence {
+		return b.sequence < other.sequence
+	}
+	for i := range b.route {
+		if b.route[i] != other.route[i] {
+			return b.route[i] < other.route[i]
+		}
+	}
+	return b.id < other.id
+}
+
+func (db *Database) AddBinLocation(warehouseID int64, zone, aisle, shelf, bin string, pickSequence int) (int64, error) {
+	query, err := os.ReadFile(binLocationsPath + "add_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database AddBinLocation() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var binLocationID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		if _, err := db.resolveWarehouse(tx, &warehouseID); err != nil {
+			return err
+		}
+		return tx.QueryRow(string(query), warehouseID, zone, aisle, shelf, bin, pickSequence).Scan(&binLocationID)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database AddBinLocation()", slog.String("error", err.Error()))
+	}
+	return binLocationID, err
+}
+
+// UpdateBinLocation changes the given fields of a bin location. A bin cannot
+// be moved to another warehouse, because its stock would move with it.
+func (db *Database) UpdateBinLocation(binLocationID int64, zone, aisle, shelf, bin *string, pickSequence *int) error {
+	query, err := os.ReadFile(binLocationsPath + "set_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateBinLocation() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	parts := make([]sql.NullString, 0, 4)
+	for _, part := range []*string{zone, aisle, shelf, bin} {
+		partNull := sql.NullString{Valid: part != nil}
+		if part != nil {
+			partNull.String = *part
+		}
+		parts = append(parts, partNull)
+	}
+	sequenceNull := sql.NullInt64{Valid: pickSequence != nil}
+	if pickSequence != nil {
+		sequenceNull.Int64 = int64(*pickSequence)
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), binLocationID, parts[0], parts[1], parts[2], parts[3], sequenceNull)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoBinLocationFound)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database UpdateBinLocation()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ShowBinLocations lists the bins in walking route order, optionally of a
+// single warehouse.
+func (db *Database) ShowBinLocations(warehouseID *int64) ([]BinLocation, error) {
+	query, err := os.ReadFile(binLocationsPath + "show_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database ShowBinLocations() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
+	}
+
+	rows, err := db.Query(string(query), warehouseNull)
+	if err != nil {
+		db.Log.Error("Database ShowBinLocations() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var bins []BinLocation
+	for rows.Next() {
+		var b BinLocation
+		if err := rows.Scan(&b.BinLocationID, &b.WarehouseID, &b.WarehouseName, &b.Code, &b.Zone, &b.Aisle, &b.Shelf, &b.Bin,
+			&b.PickSequence, &b.TotalQuantity, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowBinLocations() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		bins = append(bins, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowBinLocations() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return bins, nil
+}
+
+// ShowBinStock lists the stock per bin and product, optionally of a single
+// warehouse, product or bin. Empty bins are left out.
+func (db *Database) ShowBinStock(warehouseID, productID, binLocationID *int64) ([]BinStock, error) {
+	query, err := os.ReadFile(binLocationsPath + "stock_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database ShowBinStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	args := make([]any, 0, 3)
+	for _, id := range []*int64{warehouseID, productID, binLocationID} {
+		idNull := sql.NullInt64{Valid: id != nil}
+		if id != nil {
+			idNull.Int64 = *id
+		}
+		args = append(args, idNull)
+	}
+
+	rows, err := db.Query(string(query), args...)
+	if err != nil {
+		db.Log.Error("Database ShowBinStock() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var stock []BinStock
+	for rows.Next() {
+		var s BinStock
+		if err := rows.Scan(&s.BinLocationID, &s.WarehouseID, &s.Code, &s.ProductID, &s.ProductName, &s.Quantity, &s.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowBinStock() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowBinStock() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return stock, nil
+}
+
+// binWarehouse returns the warehouse a bin belongs to.
+func (db *Database) binWarehouse(tx *sql.Tx, binLocationID int64) (int64, error) {
+	query, err := os.ReadFile(binLocationsPath + "warehouse_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database binWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var warehouseID int64
+	err = tx.QueryRow(string(query), binLocationID).Scan(&warehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoBinLocationFound
+	}
+	return warehouseID, err
+}
+
+// PutAway places quantity units of a product that are in stock in the bin's
+// warehouse, but not yet in any of its bins, into the bin. The warehouse
+// stock does not change, so nothing is recorded in the ledger.
+func (db *Database) PutAway(binLocationID, productID, quantity int64) error {
+	unbinnedQuery, err := os.ReadFile(binLocationsPath + "unbinned_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database PutAway() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	addQuery, err := os.ReadFile(binLocationsPath + "stock_add_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database PutAway() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		warehouseID, err := db.binWarehouse(tx, binLocationID)
+		if err != nil {
+			return err
+		}
+
+		var unbinned int64
+		err = tx.QueryRow(string(unbinnedQuery), warehouseID, productID).Scan(&unbinned)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if unbinned < quantity {
+			return errNotEnoughUnbinned(warehouseID)
+		}
+
+		_, err = tx.Exec(string(addQuery), binLocationID, productID, quantity)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInsufficientStock) {
+		db.Log.Error("Database PutAway()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// MoveBinStock moves quantity units of a product from one bin to another bin
+// of the same warehouse.
+func (db *Database) MoveBinStock(productID, fromBinLocationID, toBinLocationID, quantity int64) error {
+	removeQuery, err := os.ReadFile(binLocationsPath + "stock_remove_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database MoveBinStock() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	addQuery, err := os.ReadFile(binLocationsPath + "stock_add_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database MoveBinStock() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		from, err := db.binWarehouse(tx, fromBinLocationID)
+		if err != nil {
+			return err
+		}
+		to, err := db.binWarehouse(tx, toBinLocationID)
+		if err != nil {
+			return err
+		}
+		if from != to {
+			return errBinsInDifferentWarehouses
+		}
+
+		result, err := tx.Exec(string(removeQuery), fromBinLocationID, productID, quantity)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, errNotEnoughInBin(fromBinLocationID)); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(string(addQuery), toBinLocationID, productID, quantity)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrInvalidArgument) {
+		db.Log.Error("Database MoveBinStock()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// binStops returns the bins of a warehouse that hold a product, in walking
+// route order, and locks their stock rows.
+func (db *Database) binStops(tx *sql.Tx, warehouseID, productID int64) ([]binStop, error) {
+	query, err := os.ReadFile(binLocationsPath + "pick_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database binStops() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	rows, err := tx.Query(string(query), warehouseID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stops []binStop
+	for rows.Next() {
+		var b binStop
+		if err := rows.Scan(&b.id, &b.code, &b.route[0], &b.route[1], &b.route[2], &b.route[3], &b.sequence, &b.quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stops = append(stops, b)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return stops, rows.Err()
+}
+
+// takeBins takes quantity units of a product that leave a warehouse other
+// than with an order, such as write-offs and transfers, out of its bins.
+// Like takeLots, it takes the units outside the bins first and then empties
+// the bins along the walking route. It must run before the warehouse stock
+// is decreased; a shortfall is left to that decrease to report.
+func (db *Database) takeBins(tx *sql.Tx, productID, warehouseID, quantity int64) error {
+	unbinnedQuery, err := os.ReadFile(binLocationsPath + "unbinned_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database takeBins() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	removeQuery, err := os.ReadFile(binLocationsPath + "stock_remove_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database takeBins() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	// Bins may still hold units that were sold but not picked yet, which
+	// makes the unbinned quantity negative. Every unit then comes out of the
+	// bins, and the sold units are left in them for the pick.
+	var unbinned int64
+	err = tx.QueryRow(string(unbinnedQuery), warehouseID, productID).Scan(&unbinned)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	remaining := quantity - min(quantity, max(unbinned, 0))
+	if remaining == 0 {
+		return nil
+	}
+
+	stops, err := db.binStops(tx, warehouseID, productID)
+	if err != nil {
+		return err
+	}
+	for _, b := range stops {
+		if remaining == 0 {
+			break
+		}
+		take := min(remaining, b.quantity)
+		result, err := tx.Exec(string(removeQuery), b.id, productID, take)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, errNotEnoughInBin(b.id)); err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	return nil
+}
+
+// pickList builds the pick list of an order inside tx. Every order line is
+// taken from the bins of its warehouse in route order, so that a line is
+// split over as few bins as possible early on the route, and bins shared by
+// several lines of the same product are not counted twice. The lines are
+// then sorted by route; what no bin holds is picked last.
+func (db *Database) pickList(tx *sql.Tx, orderID int64) (PickList, error) {
+	lockQuery, err := os.ReadFile(binLocationsPath + "lock_orders_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database pickList() -> Read SQL file", slog.String("error", err.Error()))
+		return PickList{}, err
+	}
+
+	detailsQuery, err := os.ReadFile(ordersPath + "show_order_details.sql")
+	if err != nil {
+		db.Log.Error("Database pickList() -> Read SQL file", slog.String("error", err.Error()))
+		return PickList{}, err
+	}
+
+	list := PickList{OrderID: orderID, Lines: []PickLine{}}
+
+	var status string
+	var pickedAt sql.NullTime
+	err = tx.QueryRow(string(lockQuery), orderID).Scan(&status, &pickedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return list, ErrNoOrderFound
+	}
+	if err != nil {
+		return list, err
+	}
+	switch status {
+	case OrderRefunded, OrderCancelled:
+		return list, errPickClosedOrder(status)
+	case OrderBackordered:
+		return list, errPickBackorderedOrder
+	}
+	if pickedAt.Valid {
+		list.PickedAt = &pickedAt.Time
+	}
+
+	rows, err := tx.Query(string(detailsQuery), orderID, sql.NullInt64{})
+	if err != nil {
+		return list, err
+	}
+	details, err := db.readRowsOrderDetail(rows)
+	if err != nil {
+		return list, err
+	}
+
+	type stop struct {
+		line PickLine
+		bin  *binStop
+	}
+	var stops []stop
+	bins := make(map[[2]int64][]binStop)
+
+	for _, detail := range details {
+		if detail.Backordered {
+			return list, errPickBackorderedOrder
+		}
+		if detail.WarehouseID == nil {
+			return list, errPickLineWithoutWarehouse(detail.OrderDetailID)
+		}
+		warehouseID := *detail.WarehouseID
+		key := [2]int64{warehouseID, detail.ProductID}
+		available, ok := bins[key]
+		if !ok {
+			if available, err = db.binStops(tx, warehouseID, detail.ProductID); err != nil {
+				return list, err
+			}
+		}
+
+		line := PickLine{
+			OrderDetailID: detail.OrderDetailID,
+			WarehouseID:   warehouseID,
+			ProductID:     detail.ProductID,
+			ProductName:   detail.Name,
+		}
+
+		remaining := detail.Quantity
+		for i := range available {
+			if remaining == 0 {
+				break
+			}
+			if available[i].quantity == 0 {
+				continue
+			}
+			take := min(remaining, available[i].quantity)
+			available[i].quantity -= take
+			remaining -= take
+
+			picked := available[i]
+			picked.quantity = take
+
+			l := line
+			l.BinLocationID = &picked.id
+			l.Code = picked.code
+			l.Quantity = take
+			stops = append(stops, stop{line: l, bin: &picked})
+		}
+		bins[key] = available
+
+		if remaining > 0 {
+			l := line
+			l.Quantity = remaining
+			stops = append(stops, stop{line: l})
+		}
+	}
+
+	sort.SliceStable(stops, func(i, j int) bool {
+		a, b := stops[i], stops[j]
+		if a.line.WarehouseID != b.line.WarehouseID {
+			return a.line.WarehouseID < b.line.WarehouseID
+		}
+		if a.bin == nil || b.bin == nil {
+			return a.bin != nil && b.bin == nil
+		}
+		return a.bin.before(*b.bin)
+	})
+
+	for i, s := range stops {
+		s.line.Step = i + 1
+		list.Lines = append(list.Lines, s.line)
+	}
+	return list, nil
+}
+
+// PickList returns the pick list of an order: where to take each of its
+// lines from, in walking route order.
+func (db *Database) PickList(orderID int64) (PickList, error) {
+	var list PickList
+	err := db.WithTx(func(tx *sql.Tx) error {
+		var err error
+		list, err = db.pickList(tx, orderID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database PickList()", slog.String("error", err.Error()))
+	}
+	return list, err
+}
+
+// ConfirmPickList marks an order as picked and takes the picked units out of
+// their bins. The warehouse stock already left with the order, so only the
+// bins change. An order can be picked once. The order lines of products
+// tracked by serial number are fulfilled with the serials given for them,
+// keyed by order detail ID, which are marked as sold.
+func (db *Database) ConfirmPickList(orderID int64, serials map[int64][]string, user string) (PickList, error) {
+	removeQuery, err := os.ReadFile(binLocationsPath + "stock_remove_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database ConfirmPickList() -> Read SQL file", slog.String("error", err.Error()))
+		return PickList{}, err
+	}
+
+	pickedQuery, err := os.ReadFile(binLocationsPath + "picked_orders_bin_locations.sql")
+	if err != nil {
+		db.Log.Error("Database ConfirmPickList() -> Read SQL file", slog.String("error", err.Error()))
+		return PickList{}, err
+	}
+
+	var list PickList
+	err = db.WithTx(func(tx *sql.Tx) error {
+		var err error
+		list, err = db.pickList(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if list.PickedAt != nil {
+			return ErrOrderAlreadyPicked
+		}
+
+		var details []PickLine
+		index := make(map[int64]int)
+		for _, line := range list.Lines {
+			if i, ok := index[line.OrderDetailID]; ok {
+				details[i].Quantity += line.Quantity
+				continue
+			}
+			index[line.OrderDetailID] = len(details)
+			details = append(details, line)
+		}
+		for detailID := range serials {
+			if _, ok := index[detailID]; !ok {
+				return errNoOrderDetail(detailID, orderID)
+			}
+		}
+		for _, detail := range details {
+			if err := db.moveSerials(tx, detail.ProductID, detail.WarehouseID, detail.Quantity, serials[detail.OrderDetailID], &detail.OrderDetailID, SerialEvent{
+				Type:          MovementSale,
+				Status:        SerialSold,
+				WarehouseID:   &detail.WarehouseID,
+				ReferenceType: "order",
+				ReferenceID:   &orderID,
+				CreatedBy:     user,
+			}); err != nil {
+				return err
+			}
+		}
+
+		for _, line := range list.Lines {
+			if line.BinLocationID == nil {
+				continue
+			}
+			result, err := tx.Exec(string(removeQuery), *line.BinLocationID, line.ProductID, line.Quantity)
+			if err != nil {
+				return err
+			}
+			if err := requireAffected(result, errNotEnoughInBin(*line.BinLocationID)); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.Exec(string(pickedQuery), orderID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrOrderAlreadyPicked)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrInvalidArgument) {
+		db.Log.Error("Database ConfirmPickList()", slog.String("error", err.Error()))
+	}
+	if err == nil {
+		now := time.Now()
+		list.PickedAt = &now
+	}
+	return list, err
+}
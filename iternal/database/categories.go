@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+type Category struct {
+	CategoryID int64     `json:"category_id"`
+	ParentID   *int64    `json:"parent_id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Depth      int       `json:"depth"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+var ErrNoCategoryFound error = &Error{Kind: ErrNotFound, Message: "no category found with the provided ID"}
+var ErrCategoryCycle error = &Error{Kind: ErrConstraint, Constraint: "fk_parent_category", Message: "a category cannot be moved under itself or one of its descendants"}
+
+func (db *Database) AddCategory(parentID *int64, name string) (int64, error) {
+	query, err := os.ReadFile(categoriesPath + "add_categories.sql")
+	if err != nil {
+		db.Log.Error("Database AddCategory() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	parentNull := sql.NullInt64{Valid: parentID != nil && *parentID > 0}
+	if parentNull.Valid {
+		parentNull.Int64 = *parentID
+	}
+
+	var categoryID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(string(query), parentNull, name).Scan(&categoryID)
+	})
+	if err != nil {
+		db.Log.Error("Database AddCategory() -> tx.QueryRow().Scan()", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return categoryID, nil
+}
+
+// UpdateCategory renames a category and/or moves it under another parent.
+// A nil parentID keeps the current parent and 0 moves the category to the
+// root. Moving a category into its own subtree returns ErrCategoryCycle; the
+// check and the update run in one serializable transaction so two concurrent
+// moves cannot build a cycle together.
+func (db *Database) UpdateCategory(categoryID int64, name *string, parentID *int64) error {
+	query, err := os.ReadFile(categoriesPath + "set_categories.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateCategory() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	cycleQuery, err := os.ReadFile(categoriesPath + "check_category_cycle.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateCategory() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	nameNull := sql.NullString{Valid: name != nil && *name != ""}
+	if nameNull.Valid {
+		nameNull.String = *name
+	}
+	parentNull := sql.NullInt64{Valid: parentID != nil && *parentID >= 0}
+	if parentNull.Valid {
+		parentNull.Int64 = *parentID
+	}
+
+	err = db.WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		if parentNull.Valid && parentNull.Int64 > 0 {
+			var cycle bool
+			if err := tx.QueryRow(string(cycleQuery), categoryID, parentNull.Int64).Scan(&cycle); err != nil {
+				return err
+			}
+			if cycle {
+				return ErrCategoryCycle
+			}
+		}
+
+		result, err := tx.Exec(string(query), categoryID, nameNull, parentNull)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoCategoryFound)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConstraint) {
+		db.Log.Error("Database UpdateCategory() -> tx.Exec()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// DeleteCategory removes an empty category. Categories that still have
+// subcategories or products are rejected by their foreign keys.
+func (db *Database) DeleteCategory(categoryID int64) error {
+	query, err := os.ReadFile(categoriesPath + "delete_categories.sql")
+	if err != nil {
+		db.Log.Error("Database DeleteCategory() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), categoryID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoCategoryFound)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConstraint) {
+		db.Log.Error("Database DeleteCategory()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+func (db *Database) CheckCategoryExists(categoryID int64) (bool, error) {
+	query, err := os.ReadFile(categoriesPath + "check_category_exists.sql")
+	if err != nil {
+		db.Log.Error("Database CheckCategoryExists() -> Read SQL file", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	var exists bool
+	err = db.QueryRow(string(query), categoryID).Scan(&exists)
+	if err != nil {
+		db.Log.Error("Database CheckCategoryExists() -> Error checking if category exists", slog.String("error", err.Error()))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// ShowCategories returns the category tree flattened in path order, so every
+// parent comes right before its children.
+func (db *Database) ShowCategories(limit *int) ([]Category, error) {
+	query, err := os.ReadFile(categoriesPath + "show_categories.sql")
+	if err != nil {
+		db.Log.Error("Database ShowCategories() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	limitValue := sql.NullInt64{Valid: limit != nil}
+	if limit != nil {
+		limitValue.Int64 = int64(*limit)
+	}
+
+	rows, err := db.Query(string(query), limitValue)
+	if err != nil {
+		db.Log.Error("Database ShowCategories() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.CategoryID, &parentID, &c.Name, &c.Path, &c.Depth, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowCategories() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if parentID.Valid {
+			c.ParentID = &parentID.Int64
+		}
+		categories = append(categories, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowCategories() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return categories, nil
+}
@@ -4,52 +4,56 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"time"
 )
 
 type Customer struct {
-	ID      int64  `json:"customer_id"`
-	Name    string `json:"customer_name"`
-	Email   string `json:"customer_email"`
-	Phone   string `json:"customer_phone"`
-	Address string `json:"customer_address"`
+	ID        int64      `json:"customer_id"`
+	Name      string     `json:"customer_name"`
+	Email     string     `json:"customer_email"`
+	Phone     string     `json:"customer_phone"`
+	Address   string     `json:"customer_address"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
-var ErrNoCustomerFound = errors.New("no customer found with the provided ID")
+var customerList = listSpec[Customer]{
+	id:      "customer_id",
+	idValue: func(c Customer) int64 { return c.ID },
+	def:     "id",
+	keys: map[string]sortKey[Customer]{
+		"id":    {column: "customer_id", cast: "BIGINT", value: func(c Customer) string { return intValue(c.ID) }},
+		"name":  {column: "name", cast: "TEXT", value: func(c Customer) string { return c.Name }},
+		"email": {column: "email", cast: "TEXT", value: func(c Customer) string { return c.Email }},
+	},
+	filters: map[string]filterField{
+		"id":      {column: "customer_id", kind: numberField},
+		"name":    {column: "name", kind: textField},
+		"email":   {column: "email", kind: textField},
+		"phone":   {column: "phone", kind: textField},
+		"address": {column: "address", kind: textField},
+	},
+}
+
+var ErrNoCustomerFound error = &Error{Kind: ErrNotFound, Message: "no customer found with the provided ID"}
+var ErrNoCustomerDeleted error = &Error{Kind: ErrNotFound, Message: "no deleted customer found with the provided ID"}
 
 func (db *Database) AddCustomer(name, email, phone, address string) (int64, error) {
 	query, err := os.ReadFile(customersPath + "add_customers.sql")
 	if err != nil {
-		db.Log.Error("Database AddCustomer() -> Read SQL file", err)
+		db.Log.Error("Database AddCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return 0, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database AddCustomer() -> db.Begin()", err)
-		return 0, err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	var customerID int64
-	err = tx.QueryRow(string(query), name, email, phone, address).Scan(&customerID)
+	err = db.WithTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(string(query), name, email, phone, address).Scan(&customerID)
+	})
 	if err != nil {
-		db.Log.Error("Database AddCustomer() -> tx.QueryRow().Scan()", err)
-		return 0, err
-	}
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database AddCustomer() -> tx.Commit()", err)
+		db.Log.Error("Database AddCustomer() -> tx.QueryRow().Scan()", slog.String("error", err.Error()))
 		return 0, err
 	}
-	committed = true
 
 	return customerID, nil
 }
@@ -57,36 +61,16 @@ func (db *Database) AddCustomer(name, email, phone, address string) (int64, erro
 func (db *Database) CheckCustomer(email, phone string) (int, error) {
 	query, err := os.ReadFile(customersPath + "check_by_email_customers.sql")
 	if err != nil {
-		db.Log.Error("Database CheckCustomer() -> Read SQL file", err)
+		db.Log.Error("Database CheckCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return 0, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database CheckCustomer() -> db.Begin()", err)
-		return 0, err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	var customerID int
-	err = tx.QueryRow(string(query), email, phone).Scan(&customerID)
+	err = db.QueryRow(string(query), email, phone).Scan(&customerID)
 	if err != nil {
-		db.Log.Error("Database CheckCustomer()", err)
-		return 0, err
-	}
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database CheckCustomer() -> tx.Commit()", err)
+		db.Log.Error("Database CheckCustomer()", slog.String("error", err.Error()))
 		return 0, err
 	}
-	committed = true
 
 	return customerID, nil
 }
@@ -94,24 +78,10 @@ func (db *Database) CheckCustomer(email, phone string) (int, error) {
 func (db *Database) UpdateCustomer(customerID int64, name, email, phone, address *string) error {
 	query, err := os.ReadFile(customersPath + "set_customers.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateCustomer() -> Read SQL file", err)
+		db.Log.Error("Database UpdateCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database UpdateCustomer() -> db.Begin()", err)
-		return err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	nameNull := sql.NullString{Valid: name != nil && *name != ""}
 	if nameNull.Valid {
 		nameNull.String = *name
@@ -129,111 +99,75 @@ func (db *Database) UpdateCustomer(customerID int64, name, email, phone, address
 		addressNull.String = *address
 	}
 
-	result, err := tx.Exec(string(query), customerID, nameNull, emailNull, phoneNull, addressNull)
-	if err != nil {
-		db.Log.Error("Database UpdateCustomer() -> tx.Exec()", err)
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		db.Log.Error("Database UpdateCustomer() -> Checking rows affected", err)
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNoCustomerFound
-	}
-
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database UpdateCustomer() -> tx.Commit()", err)
-		return err
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), customerID, nameNull, emailNull, phoneNull, addressNull)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoCustomerFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoCustomerFound) {
+		db.Log.Error("Database UpdateCustomer() -> tx.Exec()", slog.String("error", err.Error()))
 	}
-	committed = true
-
-	return nil
+	return err
 }
 
 func (db *Database) DeleteCustomer(customerID int64) error {
 	query, err := os.ReadFile(customersPath + "delete_customers.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteCustomer() -> Read SQL file", err)
+		db.Log.Error("Database DeleteCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database DeleteCustomer() -> db.Begin()", err)
-		return err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), customerID)
+		if err != nil {
+			return err
 		}
-	}()
-
-	result, err := tx.Exec(string(query), customerID)
-	if err != nil {
-		db.Log.Error("Database DeleteCustomer()", err)
-		return err
+		return requireAffected(result, ErrNoCustomerFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoCustomerFound) {
+		db.Log.Error("Database DeleteCustomer()", slog.String("error", err.Error()))
 	}
+	return err
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (db *Database) RestoreCustomer(customerID int64) error {
+	query, err := os.ReadFile(customersPath + "restore_customers.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteCustomer() -> Checking rows affected", err)
+		db.Log.Error("Database RestoreCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNoCustomerFound
-	}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database DeleteCustomer() -> tx.Commit()", err)
-		return err
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), customerID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoCustomerDeleted)
+	})
+	if err != nil && !errors.Is(err, ErrNoCustomerDeleted) {
+		db.Log.Error("Database RestoreCustomer()", slog.String("error", err.Error()))
 	}
-	committed = true
-
-	return nil
+	return err
 }
 
 func (db *Database) CheckEmailCustomer(contactEmail string) (int64, error) {
 	query, err := os.ReadFile(customersPath + "check_by_email_customers.sql")
 	if err != nil {
-		db.Log.Error("Database CheckEmailCustomer() -> Read SQL file", err)
+		db.Log.Error("Database CheckEmailCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return -1, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database CheckEmailCustomer() -> db.Begin()", err)
-		return -1, err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	var customerID int64
-	err = tx.QueryRow(string(query), contactEmail).Scan(&customerID)
+	err = db.QueryRow(string(query), contactEmail).Scan(&customerID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return -1, nil
 		}
-		db.Log.Error("Database CheckEmailCustomer()", err)
-		return -1, err
-	}
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database CheckEmailCustomer() -> tx.Commit()", err)
+		db.Log.Error("Database CheckEmailCustomer()", slog.String("error", err.Error()))
 		return -1, err
 	}
-	committed = true
 
 	return customerID, nil
 }
@@ -241,89 +175,82 @@ func (db *Database) CheckEmailCustomer(contactEmail string) (int64, error) {
 func (db *Database) CheckPhoneCustomer(contactPhone string) (int64, error) {
 	query, err := os.ReadFile(customersPath + "check_by_phone_customers.sql")
 	if err != nil {
-		db.Log.Error("Database CheckPhoneCustomer() -> Read SQL file", err)
+		db.Log.Error("Database CheckPhoneCustomer() -> Read SQL file", slog.String("error", err.Error()))
 		return -1, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database CheckPhoneCustomer() -> db.Begin()", err)
-		return -1, err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	var customerID int64
-	err = tx.QueryRow(string(query), contactPhone).Scan(&customerID)
+	err = db.QueryRow(string(query), contactPhone).Scan(&customerID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return -1, nil
 		}
-		db.Log.Error("Database CheckPhoneSupplier()", err)
+		db.Log.Error("Database CheckPhoneCustomer()", slog.String("error", err.Error()))
 		return -1, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database CheckPhoneSupplier() -> tx.Commit()", err)
-		return -1, err
-	}
-	committed = true
-
 	return customerID, nil
 }
 
 func (db *Database) readRowsCustomer(rows *sql.Rows) ([]Customer, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
 	var customers []Customer
 	for rows.Next() {
 		var c Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address); err != nil {
-			db.Log.Error("Database readRowsSupplier() -> rows.Scan()", err)
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &deletedAt); err != nil {
+			db.Log.Error("Database readRowsCustomer() -> rows.Scan()", slog.String("error", err.Error()))
 			return nil, err
 		}
+		if deletedAt.Valid {
+			c.DeletedAt = &deletedAt.Time
+		}
 		customers = append(customers, c)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsSupplier() -> rows.Err()", err)
+		db.Log.Error("Database readRowsCustomer() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return customers, nil
 }
 
-func (db *Database) ShowCustomers(limit *int) ([]Customer, error) {
+func (db *Database) ShowCustomers(page Page, includeDeleted bool) ([]Customer, string, error) {
 	query, err := os.ReadFile(customersPath + "show_customers.sql")
 	if err != nil {
-		db.Log.Error("Database ShowCustomers() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowCustomers() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	var limitValue sql.NullInt64
-	if limit != nil {
-		limitValue = sql.NullInt64{Int64: int64(*limit), Valid: true}
-	} else {
-		limitValue = sql.NullInt64{Int64: 1000, Valid: true}
+	paged, args, err := customerList.paginate(string(query), []any{includeDeleted}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowCustomers() -> db.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowCustomers() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsCustomer(rows)
+	if err != nil {
+		return nil, "", err
 	}
-	defer rows.Close()
 
-	return db.readRowsCustomer(rows)
+	items, next := customerList.nextPage(items, page)
+	return items, next, nil
 }
 
 func (db *Database) CheckCustomerExists(customerID int64) (bool, error) {
 	query, err := os.ReadFile(customersPath + "check_customer_exists.sql")
 	if err != nil {
-		db.Log.Error("Database CheckCustomerExists() -> Read SQL file", err)
+		db.Log.Error("Database CheckCustomerExists() -> Read SQL file", slog.String("error", err.Error()))
 		return false, fmt.Errorf("error reading SQL file: %w", err)
 	}
 
@@ -333,7 +260,7 @@ func (db *Database) CheckCustomerExists(customerID int64) (bool, error) {
 		if err == sql.ErrNoRows {
 			return false, nil
 		}
-		db.Log.Error("Database CheckCustomerExists() -> Error checking if customer exists", err)
+		db.Log.Error("Database CheckCustomerExists() -> Error checking if customer exists", slog.String("error", err.Error()))
 		return false, fmt.Errorf("error checking if customer exists: %w", err)
 	}
 
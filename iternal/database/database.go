@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	_ "github.com/lib/pq"
@@ -9,19 +10,34 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const mainPath string = "sql/"
 const customersPath string = mainPath + "customers/"
 const suppliersPath = mainPath + "suppliers/"
 const productsPath = mainPath + "products/"
+const categoriesPath = mainPath + "categories/"
 const ordersPath = mainPath + "orders/"
 const analyticsPath = mainPath + "analytics/"
+const supplierProductsPath = mainPath + "supplier_products/"
+const purchaseOrdersPath = mainPath + "purchase_orders/"
+const stockMovementsPath = mainPath + "stock_movements/"
+const warehousesPath = mainPath + "warehouses/"
+const binLocationsPath = mainPath + "bin_locations/"
+const lotsPath = mainPath + "lots/"
+const serialNumbersPath = mainPath + "serial_numbers/"
+const reservationsPath = mainPath + "reservations/"
+const backordersPath = mainPath + "backorders/"
 const trustedUsersPath = mainPath + "trusted_users/"
 
 type Database struct {
 	*sql.DB
 	Log *slog.Logger
+
+	cfg   config.DatabaseConfig
+	ready *atomic.Bool
 }
 
 func InitDatabase(cfg config.DatabaseConfig, slog *slog.Logger) *Database {
@@ -36,12 +52,84 @@ func InitDatabase(cfg config.DatabaseConfig, slog *slog.Logger) *Database {
 		log.Fatalf("error connection to database: %s", err.Error())
 	}
 
-	if err = db.Ping(); err != nil {
-		log.Fatalf("error during connection check: %s", err.Error())
+	return &Database{DB: db, Log: slog, cfg: cfg, ready: new(atomic.Bool)}
+}
+
+// WithLogger returns a Database that shares db's connection pool and state
+// but logs to log, so that the lines written while serving a request carry
+// the request's attributes.
+func (db *Database) WithLogger(log *slog.Logger) *Database {
+	scoped := *db
+	scoped.Log = log
+	return &scoped
+}
+
+// WaitForConnection pings the database until it answers, doubling the pause
+// between attempts up to ConnectMaxBackoff. A negative ConnectRetries keeps
+// retrying until the context is cancelled.
+func (db *Database) WaitForConnection(ctx context.Context) error {
+	backoff := db.cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			db.Log.Info("successfully connect to the database", slog.Int("attempt", attempt))
+			return nil
+		}
+
+		if db.cfg.ConnectRetries > 0 && attempt >= db.cfg.ConnectRetries {
+			return fmt.Errorf("database is unreachable after %d attempts: %w", attempt, err)
+		}
+
+		db.Log.Warn("database is not ready yet, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > db.cfg.ConnectMaxBackoff {
+			backoff = db.cfg.ConnectMaxBackoff
+		}
 	}
+}
+
+// MonitorConnection pings the database every HealthCheckInterval and flips
+// the readiness state when the connection is lost or restored. The pool
+// reconnects by itself, so the monitor only has to report what it sees.
+func (db *Database) MonitorConnection(ctx context.Context) {
+	ticker := time.NewTicker(db.cfg.HealthCheckInterval)
+	defer ticker.Stop()
 
-	slog.Info("successfully connect to the database")
-	return &Database{db, slog}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := db.PingContext(ctx); err != nil {
+			if db.ready.Swap(false) {
+				db.Log.Error("lost connection to the database", slog.String("error", err.Error()))
+			}
+			continue
+		}
+		if !db.ready.Swap(true) {
+			db.Log.Info("connection to the database restored")
+		}
+	}
+}
+
+func (db *Database) SetReady(ready bool) {
+	db.ready.Store(ready)
+}
+
+func (db *Database) IsReady() bool {
+	return db.ready.Load()
 }
 
 func (db *Database) InitTables() {
@@ -51,9 +139,7 @@ func (db *Database) InitTables() {
 		log.Fatalf("error reading the database loading script: %s", err.Error())
 	}
 
-	requests := strings.Split(string(file), ";")
-
-	for _, request := range requests {
+	for _, request := range splitStatements(string(file)) {
 		_, err := db.Exec(request)
 		if err != nil {
 			log.Fatalf("error database during table initialization: %s", err.Error())
@@ -61,3 +147,22 @@ func (db *Database) InitTables() {
 	}
 	db.Log.Info("successful check/initialization of tables")
 }
+
+// splitStatements splits an SQL script on semicolons, leaving those inside
+// $$ quoted bodies of DO blocks and functions alone.
+func splitStatements(script string) []string {
+	var statements []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch {
+		case strings.HasPrefix(script[i:], "$$"):
+			quoted = !quoted
+			i++
+		case script[i] == ';' && !quoted:
+			statements = append(statements, script[start:i])
+			start = i + 1
+		}
+	}
+	return append(statements, script[start:])
+}
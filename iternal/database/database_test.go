@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fastRetries = config.DatabaseConfig{
+	ConnectBackoff:      time.Millisecond,
+	ConnectMaxBackoff:   4 * time.Millisecond,
+	HealthCheckInterval: time.Millisecond,
+}
+
+func TestWaitForConnectionWaitsForDatabase(t *testing.T) {
+	cfg := fastRetries
+	cfg.ConnectRetries = -1
+	db, server := newFakeDatabase(t, cfg)
+	server.upAfter = 5
+
+	if err := db.WaitForConnection(context.Background()); err != nil {
+		t.Fatalf("WaitForConnection() error = %v", err)
+	}
+	if server.connects != 5 {
+		t.Errorf("connection attempts = %d, want 5", server.connects)
+	}
+}
+
+func TestWaitForConnectionGivesUp(t *testing.T) {
+	cfg := fastRetries
+	cfg.ConnectRetries = 3
+	db, server := newFakeDatabase(t, cfg)
+	server.setDown(true)
+
+	err := db.WaitForConnection(context.Background())
+	if !errors.Is(err, errConnectionRefused) {
+		t.Fatalf("WaitForConnection() error = %v, want %v", err, errConnectionRefused)
+	}
+	if server.connects != 3 {
+		t.Errorf("connection attempts = %d, want 3", server.connects)
+	}
+}
+
+func TestWaitForConnectionStopsOnCancel(t *testing.T) {
+	cfg := fastRetries
+	cfg.ConnectRetries = -1
+	db, server := newFakeDatabase(t, cfg)
+	server.setDown(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := db.WaitForConnection(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForConnection() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMonitorConnection(t *testing.T) {
+	db, server := newFakeDatabase(t, fastRetries)
+	if err := db.WaitForConnection(context.Background()); err != nil {
+		t.Fatalf("WaitForConnection() error = %v", err)
+	}
+	db.SetReady(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		db.MonitorConnection(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	server.setDown(true)
+	eventually(t, "the database is reported down", func() bool { return !db.IsReady() })
+
+	server.setDown(false)
+	eventually(t, "the database is reported ready again", db.IsReady)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "CREATE TABLE a (id INT);\nDO $$\nBEGIN\n    PERFORM 1;\n    PERFORM 2;\nEND\n$$;\nDROP TABLE b;\n"
+	want := []string{
+		"CREATE TABLE a (id INT)",
+		"\nDO $$\nBEGIN\n    PERFORM 1;\n    PERFORM 2;\nEND\n$$",
+		"\nDROP TABLE b",
+		"\n",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitStatementsCreateTables(t *testing.T) {
+	script, err := os.ReadFile("../../" + mainPath + "create_tables.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range splitStatements(string(script)) {
+		if strings.Count(statement, "$$")%2 != 0 {
+			t.Errorf("statement split inside a $$ body:\n%s", statement)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package dbtest helps tests that need a real PostgreSQL. The server is
+// taken from the TEST_DB_HOST, TEST_DB_PORT, TEST_DB_USER, TEST_DB_PASSWORD
+// and TEST_DB_NAME variables; tests using it are skipped when TEST_DB_HOST
+// is not set.
+package dbtest
+
+import (
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Config returns the settings of the test database, with short retry and
+// health check intervals, or skips the test if there is none.
+func Config(t testing.TB) config.DatabaseConfig {
+	t.Helper()
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		t.Skip("TEST_DB_HOST is not set, skipping test against PostgreSQL")
+	}
+
+	return config.DatabaseConfig{
+		Host:                host,
+		Port:                getenv("TEST_DB_PORT", "5432"),
+		User:                getenv("TEST_DB_USER", "postgres"),
+		Password:            getenv("TEST_DB_PASSWORD", "postgres"),
+		Name:                getenv("TEST_DB_NAME", "postgres"),
+		ConnectRetries:      -1,
+		ConnectBackoff:      10 * time.Millisecond,
+		ConnectMaxBackoff:   100 * time.Millisecond,
+		HealthCheckInterval: 10 * time.Millisecond,
+	}
+}
+
+// Proxy forwards TCP connections to the test database. Its address is fixed
+// when it is created, but it refuses connections until Start, so the
+// application can be started against it before the database comes up.
+// Stop drops every connection, as if the database went away.
+type Proxy struct {
+	Host, Port string
+
+	target   string
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// NewProxy reserves a local address for a proxy to the database in cfg.
+// The proxy is stopped when the test ends.
+func NewProxy(t testing.TB, cfg config.DatabaseConfig) *Proxy {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve proxy address: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	p := &Proxy{Host: host, Port: port, target: net.JoinHostPort(cfg.Host, cfg.Port)}
+	t.Cleanup(p.Stop)
+	return p
+}
+
+// Config returns cfg pointing at the proxy instead of the database.
+func (p *Proxy) Config(cfg config.DatabaseConfig) config.DatabaseConfig {
+	cfg.Host, cfg.Port = p.Host, p.Port
+	return cfg
+}
+
+// Start makes the proxy accept connections.
+func (p *Proxy) Start(t testing.TB) {
+	t.Helper()
+	l, err := net.Listen("tcp", net.JoinHostPort(p.Host, p.Port))
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+
+	p.mu.Lock()
+	p.listener = l
+	p.conns = make(map[net.Conn]struct{})
+	p.mu.Unlock()
+
+	go func() {
+		for {
+			client, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.forward(client)
+		}
+	}()
+}
+
+func (p *Proxy) forward(client net.Conn) {
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		client.Close()
+		return
+	}
+	if !p.track(client, server) {
+		client.Close()
+		server.Close()
+		return
+	}
+
+	go func() {
+		io.Copy(server, client)
+		server.Close()
+	}()
+	io.Copy(client, server)
+	client.Close()
+}
+
+// track registers the connections of a running proxy so Stop can drop them.
+func (p *Proxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns == nil {
+		return false
+	}
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// Stop closes the proxy and every connection through it.
+func (p *Proxy) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener != nil {
+		p.listener.Close()
+		p.listener = nil
+	}
+	for c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
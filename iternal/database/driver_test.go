@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/config"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errConnectionRefused = errors.New("dial tcp: connection refused")
+
+// fakeServer stands in for PostgreSQL in unit tests. It refuses connections
+// while down, or until upAfter connection attempts were made, fails
+// statements with the errors queued in execErrors, and keeps the statements
+// of committed transactions in committed.
+type fakeServer struct {
+	mu         sync.Mutex
+	down       bool
+	upAfter    int
+	connects   int
+	begins     int
+	commits    int
+	rollbacks  int
+	execErrors []error
+	committed  []string
+}
+
+func (s *fakeServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *fakeServer) isDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.down
+}
+
+func (s *fakeServer) Connect(context.Context) (driver.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connects++
+	if s.down || s.connects < s.upAfter {
+		return nil, errConnectionRefused
+	}
+	return &fakeConn{server: s}, nil
+}
+
+func (s *fakeServer) Driver() driver.Driver {
+	return fakeDriver{s}
+}
+
+type fakeDriver struct {
+	server *fakeServer
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return d.server.Connect(context.Background())
+}
+
+type fakeConn struct {
+	server  *fakeServer
+	pending []string
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver does not prepare statements")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if c.server.isDown() {
+		return nil, driver.ErrBadConn
+	}
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.begins++
+	c.pending = nil
+	return c, nil
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	if c.server.isDown() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if len(c.server.execErrors) > 0 {
+		err := c.server.execErrors[0]
+		c.server.execErrors = c.server.execErrors[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.pending = append(c.pending, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.commits++
+	c.server.committed = append(c.server.committed, c.pending...)
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.rollbacks++
+	c.pending = nil
+	return nil
+}
+
+// newFakeDatabase returns a Database backed by a fakeServer.
+func newFakeDatabase(t *testing.T, cfg config.DatabaseConfig) (*Database, *fakeServer) {
+	t.Helper()
+	server := &fakeServer{}
+	sqlDB := sql.OpenDB(server)
+	t.Cleanup(func() { sqlDB.Close() })
+	return &Database{DB: sqlDB, Log: slog.New(slog.NewTextHandler(io.Discard, nil)), cfg: cfg, ready: new(atomic.Bool)}, server
+}
+
+// eventually fails the test unless cond becomes true within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
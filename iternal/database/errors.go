@@ -0,0 +1,89 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrConstraint        = errors.New("constraint violation")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidArgument   = errors.New("invalid argument")
+)
+
+// Error is a database failure translated into a domain error. Kind is one of
+// the sentinels above, so callers can use errors.Is without knowing
+// PostgreSQL error codes; Constraint names the violated constraint, if any.
+type Error struct {
+	Kind       error
+	Constraint string
+	Message    string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// stockConstraints are the checks that keep stock levels from going negative.
+var stockConstraints = map[string]bool{
+	"products_quantity_check":        true,
+	"warehouse_stock_quantity_check": true,
+	"bin_stock_quantity_check":       true,
+	"lots_quantity_check":            true,
+}
+
+// translateError maps sql.ErrNoRows and pq integrity violations onto *Error.
+// Anything else, including errors that are already translated, is returned
+// unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var dbErr *Error
+	if errors.As(err, &dbErr) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Message: "record not found", Err: err}
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	e := &Error{Constraint: pqErr.Constraint, Message: pqErr.Message, Err: err}
+	if pqErr.Detail != "" {
+		e.Message = pqErr.Detail
+	}
+
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		e.Kind = ErrConflict
+	case "23514": // check_violation
+		e.Kind = ErrConstraint
+		if stockConstraints[pqErr.Constraint] {
+			e.Kind = ErrInsufficientStock
+			e.Message = "not enough product in stock"
+		}
+	case "23503", "23502": // foreign_key_violation, not_null_violation
+		e.Kind = ErrConstraint
+	default:
+		return err
+	}
+	return e
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFilters = 20
+
+// Filter is one condition of a list filter such as price>=10. Values holds
+// more than one entry only for = and != written as a|b|c, which match any
+// (or none) of the alternatives.
+type Filter struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+type fieldKind int
+
+const (
+	numberField fieldKind = iota
+	textField
+	timeField
+)
+
+type filterField struct {
+	column string
+	kind   fieldKind
+}
+
+// operators is ordered so that two-character operators are matched before
+// their one-character prefixes.
+var operators = []string{">=", "<=", "!=", "=", ">", "<", "~"}
+
+func invalidFilter(format string, a ...any) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf(format, a...)}
+}
+
+// ParseFilters parses a comma separated list of conditions, for example
+// "price>=10,category=tools,quantity<5" or "status=new|accepted". A comma,
+// pipe or backslash inside a value is escaped with a backslash. Fields are
+// only checked later against the whitelist of the listed entity.
+func ParseFilters(expr string) ([]Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	parts := splitEscaped(expr, ',')
+	if len(parts) > maxFilters {
+		return nil, invalidFilter("too many filter conditions, at most %d are allowed", maxFilters)
+	}
+
+	filters := make([]Filter, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		end := 0
+		for end < len(part) && (part[end] == '_' || part[end] >= 'a' && part[end] <= 'z') {
+			end++
+		}
+		if end == 0 {
+			return nil, invalidFilter("invalid filter condition %q", part)
+		}
+
+		f := Filter{Field: part[:end]}
+		rest := part[end:]
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				f.Op = op
+				rest = rest[len(op):]
+				break
+			}
+		}
+		if f.Op == "" {
+			return nil, invalidFilter("invalid operator in filter condition %q", part)
+		}
+
+		if f.Op == "=" || f.Op == "!=" {
+			f.Values = splitEscaped(rest, '|')
+		} else {
+			f.Values = []string{rest}
+		}
+		for i, v := range f.Values {
+			f.Values[i] = unescape(v)
+			if f.Values[i] == "" {
+				return nil, invalidFilter("missing value in filter condition %q", part)
+			}
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// splitEscaped splits s on sep, ignoring separators preceded by a backslash.
+// Escape sequences are kept so that a later split still sees them.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// applyFilters appends one parameterized condition per filter to query, which
+// must end inside a WHERE clause. Only whitelisted fields are accepted and
+// values are validated for their column type before they reach PostgreSQL.
+func applyFilters(query string, args []any, filters []Filter, fields map[string]filterField) (string, []any, error) {
+	for _, f := range filters {
+		field, ok := fields[f.Field]
+		if !ok {
+			return "", nil, invalidFilter("unknown filter field %s", f.Field)
+		}
+
+		placeholders := make([]string, 0, len(f.Values))
+		for _, raw := range f.Values {
+			value, err := filterValue(field.kind, f.Op, raw)
+			if err != nil {
+				return "", nil, invalidFilter("invalid value %q for filter field %s", raw, f.Field)
+			}
+			args = append(args, value)
+			placeholder := fmt.Sprintf("$%d", len(args))
+			switch {
+			case field.kind == numberField:
+				placeholder += "::NUMERIC"
+			case field.kind == textField && f.Op != "~":
+				placeholder = "LOWER(" + placeholder + ")"
+			}
+			placeholders = append(placeholders, placeholder)
+		}
+
+		column := field.column
+		if field.kind == textField && f.Op != "~" {
+			column = "LOWER(" + column + ")"
+		}
+
+		var condition string
+		switch {
+		case f.Op == "~" && field.kind == textField:
+			condition = fmt.Sprintf("%s ILIKE %s", column, placeholders[0])
+		case f.Op == "~":
+			return "", nil, invalidFilter("operator ~ is only supported for text fields, not %s", f.Field)
+		case f.Op == "=":
+			condition = fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", "))
+		case f.Op == "!=":
+			condition = fmt.Sprintf("%s NOT IN (%s)", column, strings.Join(placeholders, ", "))
+		case field.kind == textField:
+			return "", nil, invalidFilter("operator %s is not supported for text field %s", f.Op, f.Field)
+		default:
+			condition = fmt.Sprintf("%s %s %s", column, f.Op, placeholders[0])
+		}
+		query += "\n    AND " + condition
+	}
+	return query, args, nil
+}
+
+func filterValue(kind fieldKind, op, raw string) (any, error) {
+	switch kind {
+	case numberField:
+		return strconv.ParseFloat(raw, 64)
+	case timeField:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, raw)
+	default:
+		if op == "~" {
+			return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(raw) + "%", nil
+		}
+		return raw, nil
+	}
+}
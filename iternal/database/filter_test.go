@@ -0,0 +1,173 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want []Filter
+	}{
+		{name: "empty", expr: "  ", want: nil},
+		{name: "several", expr: "price>=10, category=tools,quantity<5", want: []Filter{
+			{Field: "price", Op: ">=", Values: []string{"10"}},
+			{Field: "category", Op: "=", Values: []string{"tools"}},
+			{Field: "quantity", Op: "<", Values: []string{"5"}},
+		}},
+		{name: "every operator", expr: "a>=1,b<=2,c!=3,d=4,e>5,f<6,g~x", want: []Filter{
+			{Field: "a", Op: ">=", Values: []string{"1"}},
+			{Field: "b", Op: "<=", Values: []string{"2"}},
+			{Field: "c", Op: "!=", Values: []string{"3"}},
+			{Field: "d", Op: "=", Values: []string{"4"}},
+			{Field: "e", Op: ">", Values: []string{"5"}},
+			{Field: "f", Op: "<", Values: []string{"6"}},
+			{Field: "g", Op: "~", Values: []string{"x"}},
+		}},
+		{name: "alternatives", expr: "status=new|accepted", want: []Filter{
+			{Field: "status", Op: "=", Values: []string{"new", "accepted"}},
+		}},
+		{name: "excluded alternatives", expr: "status!=new|accepted", want: []Filter{
+			{Field: "status", Op: "!=", Values: []string{"new", "accepted"}},
+		}},
+		{name: "escaped comma", expr: `name=nuts\, bolts,price<3`, want: []Filter{
+			{Field: "name", Op: "=", Values: []string{"nuts, bolts"}},
+			{Field: "price", Op: "<", Values: []string{"3"}},
+		}},
+		{name: "escaped pipe", expr: `name=a\|b|c`, want: []Filter{
+			{Field: "name", Op: "=", Values: []string{"a|b", "c"}},
+		}},
+		{name: "pipe outside equality", expr: `name~a|b`, want: []Filter{
+			{Field: "name", Op: "~", Values: []string{"a|b"}},
+		}},
+		{name: "escaped backslash", expr: `name=a\\,price>1`, want: []Filter{
+			{Field: "name", Op: "=", Values: []string{`a\`}},
+			{Field: "price", Op: ">", Values: []string{"1"}},
+		}},
+		{name: "trailing backslash", expr: `name=a\`, want: []Filter{
+			{Field: "name", Op: "=", Values: []string{`a\`}},
+		}},
+		{name: "field with underscore", expr: "created_at>=2024-05-01", want: []Filter{
+			{Field: "created_at", Op: ">=", Values: []string{"2024-05-01"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilters(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilters(%q) error = %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseFilters(%q) = %+v, want %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFiltersRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "no operator", expr: "price"},
+		{name: "unknown operator", expr: "price#10"},
+		{name: "no field", expr: "=10"},
+		{name: "upper case field", expr: "Price=10"},
+		{name: "empty condition", expr: "price>1,,quantity<5"},
+		{name: "empty value", expr: "name="},
+		{name: "empty alternative", expr: "status=new|"},
+		{name: "empty range value", expr: "price>="},
+		{name: "too many conditions", expr: strings.Repeat("price>1,", maxFilters) + "price>1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilters(tt.expr)
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("ParseFilters(%q) = %+v, %v, want %v", tt.expr, got, err, ErrInvalidArgument)
+			}
+		})
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", []string{""}},
+		{"a,b", []string{"a", "b"}},
+		{`a\,b,c`, []string{`a\,b`, "c"}},
+		{`a\\,b`, []string{`a\\`, "b"}},
+		{"a,", []string{"a", ""}},
+		{`a\`, []string{`a\`}},
+	}
+	for _, tt := range tests {
+		if got := splitEscaped(tt.s, ','); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitEscaped(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+var filterTestFields = map[string]filterField{
+	"name":    {column: "p.name", kind: textField},
+	"price":   {column: "p.price", kind: numberField},
+	"created": {column: "p.created_at", kind: timeField},
+}
+
+func TestApplyFilters(t *testing.T) {
+	filters, err := ParseFilters(`price>=10,name=Bolt|Nut,name~50%_off,created<2024-05-01,price!=3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := applyFilters("SELECT * FROM products p WHERE TRUE", []any{"first"}, filters, filterTestFields)
+	if err != nil {
+		t.Fatalf("applyFilters() error = %v", err)
+	}
+
+	want := "SELECT * FROM products p WHERE TRUE" +
+		"\n    AND p.price >= $2::NUMERIC" +
+		"\n    AND LOWER(p.name) IN (LOWER($3), LOWER($4))" +
+		"\n    AND p.name ILIKE $5" +
+		"\n    AND p.created_at < $6" +
+		"\n    AND p.price NOT IN ($7::NUMERIC)"
+	if query != want {
+		t.Errorf("applyFilters() query =\n%s\nwant\n%s", query, want)
+	}
+	wantArgs := []any{"first", 10.0, "Bolt", "Nut", `%50\%\_off%`, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 3.0}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("applyFilters() args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestApplyFiltersRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "unknown field", expr: "password=secret"},
+		{name: "number that is not a number", expr: "price>=cheap"},
+		{name: "alternative that is not a number", expr: "price=1|two"},
+		{name: "time that is not a time", expr: "created<yesterday"},
+		{name: "operator glued to the value", expr: "price<>3"},
+		{name: "like on a number", expr: "price~1"},
+		{name: "like on a time", expr: "created~2024"},
+		{name: "range on text", expr: "name>m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := ParseFilters(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilters(%q) error = %v", tt.expr, err)
+			}
+			_, _, err = applyFilters("SELECT 1 WHERE TRUE", nil, filters, filterTestFields)
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("applyFilters(%q) error = %v, want %v", tt.expr, err, ErrInvalidArgument)
+			}
+		})
+	}
+}
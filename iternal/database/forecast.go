@@ -0,0 +1,382 @@
+package database
+
+import (
+	"database/sql"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	ForecastMovingAverage = "moving_average"
+	ForecastHoltWinters   = "holt_winters"
+)
+
+// forecastSeason is the season length of Holt-Winters forecasts: sales
+// follow the days of the week.
+const forecastSeason = 7
+
+// forecastGrid are the smoothing parameters tried when a Holt-Winters model
+// is fitted to a series.
+var forecastGrid = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+// DailySales is the quantity of a product ordered on one day.
+type DailySales struct {
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+}
+
+// ForecastPoint is the expected quantity of a product on one day, with the
+// bounds of its confidence interval.
+type ForecastPoint struct {
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+	Lower    float64   `json:"lower"`
+	Upper    float64   `json:"upper"`
+}
+
+// ForecastParameters are the parameters a forecast was made with: the window
+// of a moving average, or the smoothing parameters of a Holt-Winters model.
+// SeasonLength is 0 when the history was too short for a seasonal model and a
+// trend-only model was used instead.
+type ForecastParameters struct {
+	Window       int      `json:"window,omitempty"`
+	Alpha        *float64 `json:"alpha,omitempty"`
+	Beta         *float64 `json:"beta,omitempty"`
+	Gamma        *float64 `json:"gamma,omitempty"`
+	SeasonLength int      `json:"season_length,omitempty"`
+}
+
+// ForecastBacktest measures how well the model would have forecast the last
+// Days days of the history had it been fitted without them. MAPE leaves out
+// the days without sales and is nil if there were none.
+type ForecastBacktest struct {
+	Days int      `json:"days"`
+	MAE  float64  `json:"mae"`
+	RMSE float64  `json:"rmse"`
+	MAPE *float64 `json:"mape"`
+}
+
+// ProductForecast is the demand forecast of one product. History is the
+// daily sales series the forecast is based on, and is only filled in on
+// request.
+type ProductForecast struct {
+	ProductID     int64              `json:"product_id"`
+	ProductName   string             `json:"product_name"`
+	Model         string             `json:"model"`
+	Parameters    ForecastParameters `json:"parameters"`
+	HistoryDays   int                `json:"history_days"`
+	AvgDailySold  float64            `json:"avg_daily_sold"`
+	ForecastTotal float64            `json:"forecast_total"`
+	Forecast      []ForecastPoint    `json:"forecast"`
+	Backtest      *ForecastBacktest  `json:"backtest"`
+	History       []DailySales       `json:"history,omitempty"`
+}
+
+// DailySalesSeries returns the daily sales of every product sold in the last
+// days days, or of a single product, one entry per day up to yesterday with
+// zeros on days without sales. Refunded and cancelled orders are left out.
+func (db *Database) DailySalesSeries(productID *int64, days int) ([]ProductForecast, error) {
+	query, err := os.ReadFile(analyticsPath + "daily_sales_report.sql")
+	if err != nil {
+		db.Log.Error("Database DailySalesSeries() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+
+	rows, err := db.Query(string(query), productNull, days)
+	if err != nil {
+		db.Log.Error("Database DailySalesSeries() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var series []ProductForecast
+	for rows.Next() {
+		var id int64
+		var name string
+		var day DailySales
+		if err := rows.Scan(&id, &name, &day.Date, &day.Quantity); err != nil {
+			db.Log.Error("Database DailySalesSeries() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if n := len(series); n == 0 || series[n-1].ProductID != id {
+			series = append(series, ProductForecast{ProductID: id, ProductName: name})
+		}
+		last := &series[len(series)-1]
+		last.History = append(last.History, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database DailySalesSeries() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if productID != nil && len(series) == 0 {
+		return nil, ErrNoProductFound
+	}
+	return series, nil
+}
+
+// ForecastDemand forecasts the daily sales of every product sold in the last
+// history days, or of a single product, for the next horizon days starting
+// today. history must be at least 1. model is ForecastMovingAverage, which
+// averages the last window days, or ForecastHoltWinters, which fits the
+// smoothing parameters of an additive weekly Holt-Winters model to the
+// history. Intervals are given at the confidence level, between 0 and 1.
+// Each forecast is backtested on the last horizon days of its history, but
+// at most a third of it.
+func (db *Database) ForecastDemand(productID *int64, model string, history, horizon, window int, confidence float64, includeHistory bool) ([]ProductForecast, error) {
+	forecasts, err := db.DailySalesSeries(productID, history)
+	if err != nil {
+		return nil, err
+	}
+
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	for i := range forecasts {
+		f := &forecasts[i]
+		// The history ends yesterday, so the forecast starts today.
+		start := f.History[len(f.History)-1].Date.AddDate(0, 0, 1)
+		series := make([]float64, len(f.History))
+		for j, day := range f.History {
+			series[j] = day.Quantity
+			f.AvgDailySold += day.Quantity
+		}
+		f.Model = model
+		f.HistoryDays = len(series)
+		if len(series) > 0 {
+			f.AvgDailySold /= float64(len(series))
+		}
+
+		points, sigmas, params := fitForecast(model, series, horizon, window)
+		f.Parameters = params
+		f.Forecast = make([]ForecastPoint, horizon)
+		for h := range points {
+			p := ForecastPoint{
+				Date:     start.AddDate(0, 0, h),
+				Quantity: math.Max(points[h], 0),
+				Lower:    math.Max(points[h]-z*sigmas[h], 0),
+				Upper:    math.Max(points[h]+z*sigmas[h], 0),
+			}
+			f.Forecast[h] = p
+			f.ForecastTotal += p.Quantity
+		}
+		f.Backtest = backtestForecast(model, series, horizon, window)
+
+		if !includeHistory {
+			f.History = nil
+		}
+	}
+	return forecasts, nil
+}
+
+// fitForecast fits a model to a series and forecasts the next horizon values
+// with the standard deviation of each.
+func fitForecast(model string, series []float64, horizon, window int) ([]float64, []float64, ForecastParameters) {
+	if model == ForecastMovingAverage {
+		points, sigmas := movingAverageForecast(series, horizon, window)
+		return points, sigmas, ForecastParameters{Window: window}
+	}
+	return holtWintersForecast(series, horizon)
+}
+
+// backtestForecast fits a model to the series without its last values and
+// compares the forecast of those values with what was actually sold.
+func backtestForecast(model string, series []float64, horizon, window int) *ForecastBacktest {
+	days := min(horizon, len(series)/3)
+	if days < 1 {
+		return nil
+	}
+
+	train, actual := series[:len(series)-days], series[len(series)-days:]
+	points, _, _ := fitForecast(model, train, days, window)
+
+	b := ForecastBacktest{Days: days}
+	var squared, percent float64
+	var sold int
+	for i, a := range actual {
+		e := a - math.Max(points[i], 0)
+		b.MAE += math.Abs(e)
+		squared += e * e
+		if a != 0 {
+			percent += math.Abs(e / a)
+			sold++
+		}
+	}
+	b.MAE /= float64(days)
+	b.RMSE = math.Sqrt(squared / float64(days))
+	if sold > 0 {
+		mape := 100 * percent / float64(sold)
+		b.MAPE = &mape
+	}
+	return &b
+}
+
+// movingAverageForecast forecasts the mean of the last window values for
+// every day ahead. The spread is that of the one-step-ahead errors over the
+// series, widening with the square root of the horizon.
+func movingAverageForecast(series []float64, horizon, window int) ([]float64, []float64) {
+	mean := func(values []float64) float64 {
+		if len(values) == 0 {
+			return 0
+		}
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	var squared float64
+	var errorsCount int
+	for t := window; t < len(series); t++ {
+		e := series[t] - mean(series[t-window:t])
+		squared += e * e
+		errorsCount++
+	}
+	var sigma float64
+	if errorsCount > 0 {
+		sigma = math.Sqrt(squared / float64(errorsCount))
+	}
+
+	level := mean(series[max(len(series)-window, 0):])
+	points := make([]float64, horizon)
+	sigmas := make([]float64, horizon)
+	for h := range points {
+		points[h] = level
+		sigmas[h] = sigma * math.Sqrt(float64(h+1))
+	}
+	return points, sigmas
+}
+
+// holtWinters is the state of an additive Holt-Winters model after
+// smoothing a series.
+type holtWinters struct {
+	alpha, beta, gamma float64
+	level, trend       float64
+	season             []float64
+	sse                float64
+	errors             int
+}
+
+// smoothHoltWinters runs an additive Holt-Winters model over a series. With
+// season set to 0 it is Holt's linear trend model without seasonality.
+func smoothHoltWinters(series []float64, season int, alpha, beta, gamma float64) holtWinters {
+	m := holtWinters{alpha: alpha, beta: beta, gamma: gamma}
+	start := 1
+	if season > 0 {
+		var first, second float64
+		for i := 0; i < season; i++ {
+			first += series[i]
+			second += series[season+i]
+		}
+		first /= float64(season)
+		second /= float64(season)
+		m.level = first
+		m.trend = (second - first) / float64(season)
+		m.season = make([]float64, season)
+		for i := 0; i < season; i++ {
+			m.season[i] = series[i] - first
+		}
+		start = season
+	} else {
+		m.level = series[0]
+		m.trend = series[1] - series[0]
+	}
+
+	for t := start; t < len(series); t++ {
+		var s float64
+		if season > 0 {
+			s = m.season[t%season]
+		}
+		e := series[t] - (m.level + m.trend + s)
+		m.sse += e * e
+		m.errors++
+
+		level := alpha*(series[t]-s) + (1-alpha)*(m.level+m.trend)
+		m.trend = beta*(level-m.level) + (1-beta)*m.trend
+		m.level = level
+		if season > 0 {
+			m.season[t%season] = gamma*(series[t]-level) + (1-gamma)*s
+		}
+	}
+	return m
+}
+
+// holtWintersForecast fits an additive Holt-Winters model with a weekly
+// season to a series, choosing the smoothing parameters from forecastGrid
+// that give the smallest one-step-ahead squared error. Series shorter than
+// two seasons get Holt's linear trend model, and series shorter than two
+// days a flat forecast of their last value. The standard deviations follow
+// the approximation for additive Holt-Winters models.
+func holtWintersForecast(series []float64, horizon int) ([]float64, []float64, ForecastParameters) {
+	points := make([]float64, horizon)
+	sigmas := make([]float64, horizon)
+	if len(series) < 2 {
+		for h := range points {
+			if len(series) == 1 {
+				points[h] = series[0]
+			}
+		}
+		return points, sigmas, ForecastParameters{}
+	}
+
+	season := 0
+	gammas := []float64{0}
+	if len(series) >= 2*forecastSeason {
+		season = forecastSeason
+		gammas = forecastGrid
+	}
+
+	var best holtWinters
+	fitted := false
+	for _, alpha := range forecastGrid {
+		for _, beta := range forecastGrid {
+			for _, gamma := range gammas {
+				m := smoothHoltWinters(series, season, alpha, beta, gamma)
+				if !fitted || m.sse < best.sse {
+					best, fitted = m, true
+				}
+			}
+		}
+	}
+
+	var sigma float64
+	if best.errors > 0 {
+		sigma = math.Sqrt(best.sse / float64(best.errors))
+	}
+	n := len(series)
+	var variance float64
+	for h := range points {
+		points[h] = best.level + float64(h+1)*best.trend
+		if season > 0 {
+			points[h] += best.season[(n+h)%season]
+		}
+
+		// The variance of the forecast h+1 days ahead adds the effect of
+		// every error since the last observation.
+		if h > 0 {
+			c := best.alpha * (1 + float64(h)*best.beta)
+			if season > 0 && h%season == 0 {
+				c += best.gamma
+			}
+			variance += c * c
+		}
+		sigmas[h] = sigma * math.Sqrt(1+variance)
+	}
+
+	params := ForecastParameters{Alpha: &best.alpha, Beta: &best.beta}
+	if season > 0 {
+		params.Gamma = &best.gamma
+		params.SeasonLength = season
+	}
+	return points, sigmas, params
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"math"
+	"testing"
+)
+
+const forecastTolerance = 1e-9
+
+func repeatSeries(pattern []float64, n int) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = pattern[i%len(pattern)]
+	}
+	return series
+}
+
+func linearSeries(n int, intercept, slope float64) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = intercept + slope*float64(i)
+	}
+	return series
+}
+
+func assertClose(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s has %d values, want %d", name, len(got), len(want))
+	}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > forecastTolerance {
+			t.Fatalf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestHoltWintersForecast(t *testing.T) {
+	weekly := []float64{10, 12, 14, 16, 18, 30, 40}
+	tests := []struct {
+		name       string
+		series     []float64
+		horizon    int
+		want       []float64
+		wantSeason int
+	}{
+		{name: "constant", series: repeatSeries([]float64{5}, 28), horizon: 10,
+			want: repeatSeries([]float64{5}, 10), wantSeason: forecastSeason},
+		{name: "constant without season", series: repeatSeries([]float64{5}, 10), horizon: 3,
+			want: []float64{5, 5, 5}},
+		{name: "linear trend", series: linearSeries(13, 1, 2), horizon: 4,
+			want: []float64{27, 29, 31, 33}},
+		{name: "weekly season", series: repeatSeries(weekly, 28), horizon: 9,
+			want: repeatSeries(weekly, 9), wantSeason: forecastSeason},
+		{name: "weekly season from an offset", series: repeatSeries(weekly, 30), horizon: 3,
+			want: []float64{14, 16, 18}, wantSeason: forecastSeason},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, sigmas, params := holtWintersForecast(tt.series, tt.horizon)
+			assertClose(t, "points", points, tt.want)
+			assertClose(t, "sigmas", sigmas, make([]float64, tt.horizon))
+			if params.SeasonLength != tt.wantSeason {
+				t.Errorf("SeasonLength = %d, want %d", params.SeasonLength, tt.wantSeason)
+			}
+			if params.Alpha == nil || params.Beta == nil {
+				t.Errorf("params = %+v, want alpha and beta", params)
+			}
+			if (params.Gamma != nil) != (tt.wantSeason > 0) {
+				t.Errorf("Gamma = %v, want it set only with a season", params.Gamma)
+			}
+		})
+	}
+}
+
+func TestHoltWintersForecastShortSeries(t *testing.T) {
+	for n := 0; n < 2*forecastSeason+1; n++ {
+		series := linearSeries(n, 3, 1)
+		points, sigmas, params := holtWintersForecast(series, 5)
+		if len(points) != 5 || len(sigmas) != 5 {
+			t.Fatalf("length %d: got %d points and %d sigmas, want 5", n, len(points), len(sigmas))
+		}
+
+		switch {
+		case n < 2:
+			want := make([]float64, 5)
+			if n == 1 {
+				want = repeatSeries(series, 5)
+			}
+			assertClose(t, "points", points, want)
+			assertClose(t, "sigmas", sigmas, make([]float64, 5))
+			if params != (ForecastParameters{}) {
+				t.Errorf("length %d: params = %+v, want none", n, params)
+			}
+		case n < 2*forecastSeason:
+			if params.Alpha == nil || params.Beta == nil || params.Gamma != nil || params.SeasonLength != 0 {
+				t.Errorf("length %d: params = %+v, want a trend model", n, params)
+			}
+			assertClose(t, "points", points, linearSeries(5, float64(n+3), 1))
+		default:
+			if params.SeasonLength != forecastSeason || params.Gamma == nil {
+				t.Errorf("length %d: params = %+v, want a seasonal model", n, params)
+			}
+		}
+	}
+}
+
+func TestHoltWintersForecastSigmaWidens(t *testing.T) {
+	series := []float64{3, 7, 2, 9, 4, 8, 1, 6, 5, 9, 2, 7, 3, 8, 4, 6, 2, 9, 5, 7, 3}
+	_, sigmas, _ := holtWintersForecast(series, 10)
+	if sigmas[0] <= 0 {
+		t.Fatalf("sigmas[0] = %v, want a positive spread for a noisy series", sigmas[0])
+	}
+	for h := 1; h < len(sigmas); h++ {
+		if sigmas[h] < sigmas[h-1] {
+			t.Fatalf("sigmas[%d] = %v is below sigmas[%d] = %v", h, sigmas[h], h-1, sigmas[h-1])
+		}
+	}
+}
+
+func TestSmoothHoltWinters(t *testing.T) {
+	tests := []struct {
+		name       string
+		series     []float64
+		season     int
+		wantLevel  float64
+		wantTrend  float64
+		wantErrors int
+	}{
+		{name: "constant", series: repeatSeries([]float64{4}, 21), season: forecastSeason,
+			wantLevel: 4, wantTrend: 0, wantErrors: 14},
+		{name: "linear without season", series: linearSeries(10, 1, 2), season: 0,
+			wantLevel: 19, wantTrend: 2, wantErrors: 9},
+		{name: "two values", series: []float64{6, 4}, season: 0,
+			wantLevel: 4, wantTrend: -2, wantErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := smoothHoltWinters(tt.series, tt.season, 0.5, 0.3, 0.1)
+			if math.Abs(m.level-tt.wantLevel) > forecastTolerance || math.Abs(m.trend-tt.wantTrend) > forecastTolerance {
+				t.Errorf("level, trend = %v, %v, want %v, %v", m.level, m.trend, tt.wantLevel, tt.wantTrend)
+			}
+			if m.errors != tt.wantErrors {
+				t.Errorf("errors = %d, want %d", m.errors, tt.wantErrors)
+			}
+			if m.sse > forecastTolerance {
+				t.Errorf("sse = %v, want 0 for a series the model fits exactly", m.sse)
+			}
+			if len(m.season) != tt.season {
+				t.Errorf("season has %d values, want %d", len(m.season), tt.season)
+			}
+		})
+	}
+}
+
+func TestSmoothHoltWintersSeason(t *testing.T) {
+	weekly := []float64{10, 12, 14, 16, 18, 30, 40}
+	m := smoothHoltWinters(repeatSeries(weekly, 21), forecastSeason, 0.3, 0.1, 0.5)
+	want := make([]float64, forecastSeason)
+	for i, v := range weekly {
+		want[i] = v - 20
+	}
+	assertClose(t, "season", m.season, want)
+	if math.Abs(m.level-20) > forecastTolerance || math.Abs(m.trend) > forecastTolerance {
+		t.Errorf("level, trend = %v, %v, want 20, 0", m.level, m.trend)
+	}
+}
+
+func TestMovingAverageForecast(t *testing.T) {
+	tests := []struct {
+		name       string
+		series     []float64
+		window     int
+		horizon    int
+		wantPoints []float64
+		wantSigmas []float64
+	}{
+		{name: "constant", series: repeatSeries([]float64{6}, 10), window: 3, horizon: 3,
+			wantPoints: []float64{6, 6, 6}, wantSigmas: []float64{0, 0, 0}},
+		{name: "linear", series: linearSeries(6, 1, 1), window: 3, horizon: 4,
+			wantPoints: []float64{5, 5, 5, 5}, wantSigmas: []float64{2, 2 * math.Sqrt2, 2 * math.Sqrt(3), 4}},
+		{name: "window longer than the series", series: []float64{2, 4}, window: 7, horizon: 2,
+			wantPoints: []float64{3, 3}, wantSigmas: []float64{0, 0}},
+		{name: "empty", series: nil, window: 7, horizon: 2,
+			wantPoints: []float64{0, 0}, wantSigmas: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, sigmas := movingAverageForecast(tt.series, tt.horizon, tt.window)
+			assertClose(t, "points", points, tt.wantPoints)
+			assertClose(t, "sigmas", sigmas, tt.wantSigmas)
+		})
+	}
+}
+
+func TestBacktestForecast(t *testing.T) {
+	mape := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		model   string
+		series  []float64
+		horizon int
+		want    *ForecastBacktest
+	}{
+		{name: "too short", model: ForecastHoltWinters, series: []float64{1, 2}, horizon: 7, want: nil},
+		{name: "no sales", model: ForecastHoltWinters, series: make([]float64, 30), horizon: 7,
+			want: &ForecastBacktest{Days: 7}},
+		{name: "constant", model: ForecastHoltWinters, series: repeatSeries([]float64{5}, 30), horizon: 7,
+			want: &ForecastBacktest{Days: 7, MAPE: mape(0)}},
+		{name: "a third of the history", model: ForecastMovingAverage, series: repeatSeries([]float64{5}, 9), horizon: 7,
+			want: &ForecastBacktest{Days: 3, MAPE: mape(0)}},
+		{name: "moving average misses a step", model: ForecastMovingAverage, series: []float64{2, 2, 2, 2, 2, 2, 4, 4, 4}, horizon: 3,
+			want: &ForecastBacktest{Days: 3, MAE: 2, RMSE: 2, MAPE: mape(50)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := backtestForecast(tt.model, tt.series, tt.horizon, 3)
+			if tt.want == nil || got == nil {
+				if got != tt.want {
+					t.Fatalf("backtestForecast() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if got.Days != tt.want.Days {
+				t.Errorf("Days = %d, want %d", got.Days, tt.want.Days)
+			}
+			assertClose(t, "MAE, RMSE", []float64{got.MAE, got.RMSE}, []float64{tt.want.MAE, tt.want.RMSE})
+			if (got.MAPE == nil) != (tt.want.MAPE == nil) {
+				t.Fatalf("MAPE = %v, want %v", got.MAPE, tt.want.MAPE)
+			}
+			if got.MAPE != nil {
+				assertClose(t, "MAPE", []float64{*got.MAPE}, []float64{*tt.want.MAPE})
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// GoodsReceipt is one delivery booked against a purchase order. Status is
+// the status of the purchase order after the delivery. AllocatedBackorders
+// are the backordered orders the delivery filled.
+type GoodsReceipt struct {
+	GoodsReceiptID      int64              `json:"goods_receipt_id"`
+	PurchaseOrderID     int64              `json:"purchase_order_id"`
+	Status              string             `json:"status,omitempty"`
+	Notes               string             `json:"notes"`
+	ReceivedAt          time.Time          `json:"received_at"`
+	Lines               []GoodsReceiptLine `json:"lines"`
+	AllocatedBackorders []int64            `json:"allocated_backorders,omitempty"`
+}
+
+// GoodsReceiptLine is the delivery of one purchase order line. Quantity is
+// the number of units delivered, including the damaged ones; only the
+// accepted units are added to stock and count as received. Outstanding is
+// what is still expected on the line after this delivery and is negative
+// after an over-delivery. The accepted units of a line go to the lot
+// LotNumber, if given, which is created with ExpiresAt if it is new.
+// SerialNumbers are the serials of the accepted units of a product tracked
+// by serial number.
+type GoodsReceiptLine struct {
+	GoodsReceiptLineID  int64      `json:"goods_receipt_line_id"`
+	PurchaseOrderLineID int64      `json:"purchase_order_line_id"`
+	ProductID           int64      `json:"product_id"`
+	ProductName         string     `json:"product_name,omitempty"`
+	Quantity            int64      `json:"quantity"`
+	DamagedQuantity     int64      `json:"damaged_quantity"`
+	AcceptedQuantity    int64      `json:"accepted_quantity"`
+	Outstanding         *int64     `json:"outstanding,omitempty"`
+	LotID               *int64     `json:"lot_id,omitempty"`
+	LotNumber           string     `json:"lot_number,omitempty"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"`
+	SerialNumbers       []string   `json:"serial_numbers,omitempty"`
+}
+
+var ErrPurchaseOrderNotReceivable error = &Error{Kind: ErrConflict, Message: "goods can only be received for sent or partially received purchase orders"}
+
+func errNoPurchaseOrderLine(lineID int64) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("purchase order line %d does not belong to this purchase order", lineID)}
+}
+
+func errOverDelivery(lineID, ordered, received int64) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("purchase order line %d would be over-delivered: %d ordered, %d received; set allow_over_delivery to accept the surplus", lineID, ordered, received)}
+}
+
+// ReceiveGoods books a delivery against a purchase order in one serializable
+// transaction: it records the receipt, adds the accepted units to the stock
+// of the purchase order's warehouse and to the stock ledger and moves the
+// purchase order to partially_received or received. Deliveries above the
+// ordered quantity are rejected unless allowOverDelivery is set. With
+// closeOrder the purchase order is marked received even if some lines were
+// delivered short. The received stock then goes to the backorders of its
+// products, see allocateBackorders.
+func (db *Database) ReceiveGoods(purchaseOrderID int64, lines []GoodsReceiptLine, notes string, allowOverDelivery, closeOrder bool, user string) (GoodsReceipt, error) {
+	files := []string{
+		"lock_purchase_orders.sql",
+		"add_goods_receipts.sql",
+		"add_goods_receipt_lines.sql",
+		"receive_purchase_order_lines.sql",
+		"receive_status_purchase_orders.sql",
+	}
+	queries := make([]string, len(files))
+	for i, file := range files {
+		query, err := os.ReadFile(purchaseOrdersPath + file)
+		if err != nil {
+			db.Log.Error("Database ReceiveGoods() -> Read SQL file", slog.String("error", err.Error()))
+			return GoodsReceipt{}, err
+		}
+		queries[i] = string(query)
+	}
+	lockQuery, receiptQuery, receiptLineQuery, receiveQuery, statusQuery := queries[0], queries[1], queries[2], queries[3], queries[4]
+
+	var receipt GoodsReceipt
+	err := db.WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		receipt = GoodsReceipt{PurchaseOrderID: purchaseOrderID, Notes: notes}
+
+		var status string
+		var warehouseID int64
+		if err := tx.QueryRow(lockQuery, purchaseOrderID).Scan(&status, &warehouseID); errors.Is(err, sql.ErrNoRows) {
+			return ErrNoPurchaseOrderFound
+		} else if err != nil {
+			return err
+		}
+		if status != PurchaseOrderSent && status != PurchaseOrderPartiallyReceived {
+			return ErrPurchaseOrderNotReceivable
+		}
+
+		if err := tx.QueryRow(receiptQuery, purchaseOrderID, notes).Scan(&receipt.GoodsReceiptID, &receipt.ReceivedAt); err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			line.AcceptedQuantity = line.Quantity - line.DamagedQuantity
+
+			var ordered, received int64
+			err := tx.QueryRow(receiveQuery, purchaseOrderID, line.PurchaseOrderLineID, line.AcceptedQuantity).Scan(&line.ProductID, &ordered, &received)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errNoPurchaseOrderLine(line.PurchaseOrderLineID)
+			} else if err != nil {
+				return err
+			}
+			if received > ordered && !allowOverDelivery {
+				return errOverDelivery(line.PurchaseOrderLineID, ordered, received)
+			}
+
+			if line.AcceptedQuantity > 0 {
+				if line.LotID, err = db.receiveLot(tx, line.ProductID, warehouseID, line.LotNumber, line.ExpiresAt, line.AcceptedQuantity); err != nil {
+					return err
+				}
+			}
+
+			lotNull := sql.NullInt64{Valid: line.LotID != nil}
+			if line.LotID != nil {
+				lotNull.Int64 = *line.LotID
+			}
+			if err := tx.QueryRow(receiptLineQuery, receipt.GoodsReceiptID, line.PurchaseOrderLineID, line.Quantity, line.DamagedQuantity, lotNull).Scan(&line.GoodsReceiptLineID); err != nil {
+				return err
+			}
+			if err := db.receiveSerials(tx, line.ProductID, line.AcceptedQuantity, line.SerialNumbers, SerialEvent{
+				Type:          MovementReceipt,
+				WarehouseID:   &warehouseID,
+				ReferenceType: "goods_receipt",
+				ReferenceID:   &receipt.GoodsReceiptID,
+				CreatedBy:     user,
+			}); err != nil {
+				return err
+			}
+			if line.AcceptedQuantity > 0 {
+				if err := db.moveStock(tx, StockMovement{
+					ProductID:      line.ProductID,
+					WarehouseID:    warehouseID,
+					QuantityChange: line.AcceptedQuantity,
+					Type:           MovementReceipt,
+					Reason:         fmt.Sprintf("purchase order %d", purchaseOrderID),
+					ReferenceType:  "goods_receipt",
+					ReferenceID:    &receipt.GoodsReceiptID,
+					CreatedBy:      user,
+				}); err != nil {
+					return err
+				}
+			}
+
+			outstanding := ordered - received
+			line.Outstanding = &outstanding
+			receipt.Lines = append(receipt.Lines, line)
+		}
+
+		received := make(map[int64]bool)
+		for _, line := range receipt.Lines {
+			if line.AcceptedQuantity <= 0 || received[line.ProductID] {
+				continue
+			}
+			received[line.ProductID] = true
+			allocated, err := db.allocateBackorders(tx, line.ProductID, warehouseID, user)
+			if err != nil {
+				return err
+			}
+			receipt.AllocatedBackorders = append(receipt.AllocatedBackorders, allocated...)
+		}
+
+		return tx.QueryRow(statusQuery, purchaseOrderID, closeOrder).Scan(&receipt.Status)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrInvalidArgument) {
+		db.Log.Error("Database ReceiveGoods()", slog.String("error", err.Error()))
+	}
+	return receipt, err
+}
+
+// ShowGoodsReceipts returns the deliveries booked against a purchase order,
+// oldest first.
+func (db *Database) ShowGoodsReceipts(purchaseOrderID int64) ([]GoodsReceipt, error) {
+	query, err := os.ReadFile(purchaseOrdersPath + "show_goods_receipts.sql")
+	if err != nil {
+		db.Log.Error("Database ShowGoodsReceipts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	rows, err := db.Query(string(query), purchaseOrderID)
+	if err != nil {
+		db.Log.Error("Database ShowGoodsReceipts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var receipts []GoodsReceipt
+	for rows.Next() {
+		var r GoodsReceipt
+		var line GoodsReceiptLine
+		var lotID sql.NullInt64
+		var lotNumber sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&r.GoodsReceiptID, &r.PurchaseOrderID, &r.Notes, &r.ReceivedAt,
+			&line.GoodsReceiptLineID, &line.PurchaseOrderLineID, &line.ProductID, &line.ProductName,
+			&line.Quantity, &line.DamagedQuantity, &lotID, &lotNumber, &expiresAt); err != nil {
+			db.Log.Error("Database ShowGoodsReceipts() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		line.AcceptedQuantity = line.Quantity - line.DamagedQuantity
+		if lotID.Valid {
+			line.LotID = &lotID.Int64
+		}
+		line.LotNumber = lotNumber.String
+		if expiresAt.Valid {
+			line.ExpiresAt = &expiresAt.Time
+		}
+
+		if n := len(receipts); n == 0 || receipts[n-1].GoodsReceiptID != r.GoodsReceiptID {
+			receipts = append(receipts, r)
+		}
+		last := &receipts[len(receipts)-1]
+		last.Lines = append(last.Lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowGoodsReceipts() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return receipts, nil
+}
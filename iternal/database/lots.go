@@ -0,0 +1,270 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Lot is a batch of a product in one warehouse, identified by the lot
+// number printed on the goods. ExpiresAt is the last day the lot may be
+// sold; a lot without an expiry date never expires.
+type Lot struct {
+	LotID         int64      `json:"lot_id"`
+	ProductID     int64      `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	WarehouseID   int64      `json:"warehouse_id"`
+	WarehouseName string     `json:"warehouse_name"`
+	LotNumber     string     `json:"lot_number"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Quantity      int64      `json:"quantity"`
+	Expired       bool       `json:"expired"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// lotTake is a quantity taken out of a lot.
+type lotTake struct {
+	lotID     int64
+	lotNumber string
+	expiresAt sql.NullTime
+	quantity  int64
+}
+
+func errLotRequired(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d is tracked by lot, a lot number is required", productID)}
+}
+
+func errNotEnoughInLot(lotNumber string) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough product in lot %s", lotNumber)}
+}
+
+func errExpiredStock(warehouseID, expired int64) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough unexpired product in stock in warehouse %d, %d units have expired", warehouseID, expired)}
+}
+
+// receiveLot adds quantity units of a product to a lot in a warehouse,
+// creating the lot if it is new, and returns its ID. Without a lot number
+// the units stay outside any lot, which is only allowed for products that
+// are not tracked by lot; the returned ID is then nil.
+func (db *Database) receiveLot(tx *sql.Tx, productID, warehouseID int64, lotNumber string, expiresAt *time.Time, quantity int64) (*int64, error) {
+	if lotNumber == "" {
+		query, err := os.ReadFile(lotsPath + "track_lots.sql")
+		if err != nil {
+			db.Log.Error("Database receiveLot() -> Read SQL file", slog.String("error", err.Error()))
+			return nil, err
+		}
+
+		var tracked bool
+		err = tx.QueryRow(string(query), productID).Scan(&tracked)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoProductFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tracked {
+			return nil, errLotRequired(productID)
+		}
+		return nil, nil
+	}
+
+	query, err := os.ReadFile(lotsPath + "add_lots.sql")
+	if err != nil {
+		db.Log.Error("Database receiveLot() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	expiresNull := sql.NullTime{Valid: expiresAt != nil}
+	if expiresAt != nil {
+		expiresNull.Time = *expiresAt
+	}
+
+	var lotID int64
+	if err := tx.QueryRow(string(query), productID, warehouseID, lotNumber, expiresNull, quantity).Scan(&lotID); err != nil {
+		return nil, err
+	}
+	return &lotID, nil
+}
+
+// removeFromLot takes quantity units out of the named lot of a product in a
+// warehouse.
+func (db *Database) removeFromLot(tx *sql.Tx, productID, warehouseID int64, lotNumber string, quantity int64) (int64, error) {
+	query, err := os.ReadFile(lotsPath + "remove_number_lots.sql")
+	if err != nil {
+		db.Log.Error("Database removeFromLot() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var lotID int64
+	err = tx.QueryRow(string(query), productID, warehouseID, lotNumber, quantity).Scan(&lotID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errNotEnoughInLot(lotNumber)
+	}
+	return lotID, err
+}
+
+// takeLots decides which lots quantity units of a product leaving a
+// warehouse come from and takes them out of those lots. Stock of the
+// warehouse that is in no lot counts as one more lot without expiry.
+//
+// A sale is first-expired-first-out: lots are used in order of expiry, lots
+// without expiry and stock outside lots last, and expired lots are never
+// sold. Other outgoing stock, such as write-offs and transfers, is taken
+// from outside the lots first and then from the lots in order of expiry,
+// expired ones included.
+//
+// Products without lots need no work and return no takes.
+func (db *Database) takeLots(tx *sql.Tx, productID, warehouseID, quantity int64, sale bool) ([]lotTake, error) {
+	lockQuery, err := os.ReadFile(lotsPath + "lock_lots.sql")
+	if err != nil {
+		db.Log.Error("Database takeLots() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	stockQuery, err := os.ReadFile(lotsPath + "warehouse_quantity_lots.sql")
+	if err != nil {
+		db.Log.Error("Database takeLots() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	removeQuery, err := os.ReadFile(lotsPath + "stock_remove_lots.sql")
+	if err != nil {
+		db.Log.Error("Database takeLots() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	rows, err := tx.Query(string(lockQuery), productID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lots []lotTake
+	var lotted, expired int64
+	for rows.Next() {
+		var l lotTake
+		var isExpired bool
+		if err := rows.Scan(&l.lotID, &l.lotNumber, &l.expiresAt, &l.quantity, &isExpired); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lotted += l.quantity
+		if isExpired {
+			expired += l.quantity
+			if sale {
+				continue
+			}
+		}
+		lots = append(lots, l)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if lotted == 0 {
+		return nil, nil
+	}
+
+	var stock int64
+	if err := tx.QueryRow(string(stockQuery), warehouseID, productID).Scan(&stock); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	unlotted := max(stock-lotted, 0)
+
+	var takes []lotTake
+	remaining := quantity
+	if !sale {
+		remaining -= min(remaining, unlotted)
+	}
+	for _, l := range lots {
+		if remaining == 0 {
+			break
+		}
+		take := l
+		take.quantity = min(remaining, l.quantity)
+		remaining -= take.quantity
+		takes = append(takes, take)
+	}
+	if sale {
+		remaining -= min(remaining, unlotted)
+	}
+	if remaining > 0 {
+		if sale && expired > 0 {
+			return nil, errExpiredStock(warehouseID, expired)
+		}
+		return nil, errNotEnoughInWarehouse(warehouseID)
+	}
+
+	for _, take := range takes {
+		result, err := tx.Exec(string(removeQuery), take.lotID, take.quantity)
+		if err != nil {
+			return nil, err
+		}
+		if err := requireAffected(result, errNotEnoughInLot(take.lotNumber)); err != nil {
+			return nil, err
+		}
+	}
+	return takes, nil
+}
+
+// ShowLots lists the lots, optionally of a single product or warehouse, in
+// order of expiry. Empty lots are left out unless includeEmpty is set. With
+// expiringWithin only lots that expire within that many days from today,
+// or have already expired, are returned.
+func (db *Database) ShowLots(productID, warehouseID *int64, includeEmpty bool, expiringWithin *int) ([]Lot, error) {
+	query, err := os.ReadFile(lotsPath + "show_lots.sql")
+	if err != nil {
+		db.Log.Error("Database ShowLots() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
+	}
+	daysNull := sql.NullInt64{Valid: expiringWithin != nil}
+	if expiringWithin != nil {
+		daysNull.Int64 = int64(*expiringWithin)
+	}
+
+	rows, err := db.Query(string(query), productNull, warehouseNull, includeEmpty, daysNull)
+	if err != nil {
+		db.Log.Error("Database ShowLots() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var lots []Lot
+	for rows.Next() {
+		var l Lot
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&l.LotID, &l.ProductID, &l.ProductName, &l.WarehouseID, &l.WarehouseName, &l.LotNumber, &expiresAt,
+			&l.Quantity, &l.Expired, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowLots() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if expiresAt.Valid {
+			l.ExpiresAt = &expiresAt.Time
+		}
+		lots = append(lots, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowLots() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return lots, nil
+}
@@ -2,10 +2,77 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"log/slog"
 	"os"
 	"time"
 )
 
+const (
+	OrderNew         = "new"
+	OrderAccepted    = "accepted"
+	OrderShipped     = "shipped"
+	OrderDelivered   = "delivered"
+	OrderBackordered = "backordered"
+	OrderRefunded    = "refunded"
+	OrderCancelled   = "cancelled"
+)
+
+// orderTransitions lists the statuses an order may be moved to by hand and
+// the statuses it may come from. Orders become new or backordered when they
+// are placed or their backorder is allocated, and refunded by RefundOrder;
+// cancelled and refunded orders never change again.
+var orderTransitions = map[string][]string{
+	OrderAccepted:  {OrderNew},
+	OrderShipped:   {OrderNew, OrderAccepted},
+	OrderDelivered: {OrderShipped},
+	OrderCancelled: {OrderNew, OrderAccepted, OrderBackordered},
+}
+
+// orderRefundable lists the statuses of the orders RefundOrder accepts.
+var orderRefundable = []string{OrderNew, OrderAccepted, OrderShipped, OrderDelivered}
+
+var ErrNoOrderFound error = &Error{Kind: ErrNotFound, Message: "no order found with the provided ID"}
+var ErrOrderNotRefundable error = &Error{Kind: ErrConflict, Message: "the order is already refunded or cancelled, or has not shipped"}
+var ErrOrderStatus error = &Error{Kind: ErrConflict, Message: "the order cannot be moved to this status from its current one"}
+
+// orderFilters applies to every order list: all of them select from the
+// orders table, even when they return only a few of its columns.
+var orderFilters = map[string]filterField{
+	"id":          {column: "order_id", kind: numberField},
+	"customer_id": {column: "customer_id", kind: numberField},
+	"status":      {column: "status", kind: textField},
+	"created_at":  {column: "created_at", kind: timeField},
+	"updated_at":  {column: "updated_at", kind: timeField},
+}
+
+var orderInfoList = listSpec[OrderInfo]{
+	id:      "order_id",
+	idValue: func(o OrderInfo) int64 { return o.OrderID },
+	def:     "id",
+	keys: map[string]sortKey[OrderInfo]{
+		"id":         {column: "order_id", cast: "BIGINT", value: func(o OrderInfo) string { return intValue(o.OrderID) }},
+		"status":     {column: "status", cast: "TEXT", value: func(o OrderInfo) string { return o.Status }},
+		"created_at": {column: "created_at", cast: "TIMESTAMP", value: func(o OrderInfo) string { return timeValue(o.CreatedAt) }},
+	},
+	filters: orderFilters,
+}
+
+var orderList = listSpec[Order]{
+	id:      "order_id",
+	idValue: func(o Order) int64 { return o.OrderID },
+	def:     "id",
+	keys: map[string]sortKey[Order]{
+		"id":          {column: "order_id", cast: "BIGINT", value: func(o Order) string { return intValue(o.OrderID) }},
+		"customer_id": {column: "customer_id", cast: "BIGINT", value: func(o Order) string { return intValue(o.CustomerID) }},
+		"status":      {column: "status", cast: "TEXT", value: func(o Order) string { return o.Status }},
+		"created_at":  {column: "created_at", cast: "TIMESTAMP", value: func(o Order) string { return timeValue(o.CreatedAt) }},
+		"updated_at":  {column: "updated_at", cast: "TIMESTAMP", value: func(o Order) string { return timeValue(o.UpdatedAt) }},
+	},
+	filters: orderFilters,
+}
+
 type OrderInfo struct {
 	OrderID   int64     `json:"order_id"`
 	Status    string    `json:"status"`
@@ -20,112 +87,142 @@ type Order struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// OrderDetail is one line of an order. Backordered is set while the line
+// waits for stock, and stays set if its order is cancelled before stock is
+// allocated to it. WarehouseID is nil while the line is backordered without
+// a warehouse of its own.
 type OrderDetail struct {
 	OrderDetailID int64   `json:"order_detail_id"`
+	ProductID     int64   `json:"product_id"`
+	WarehouseID   *int64  `json:"warehouse_id"`
+	Backordered   bool    `json:"backordered"`
 	Quantity      int64   `json:"quantity"`
 	Price         float64 `json:"price"`
 	Name          string  `json:"name"`
 }
 
-func (db *Database) removeQuantity(productID, quantity int64) error {
-	query, err := os.ReadFile(ordersPath + "quantity_remove_orders.sql")
-	if err != nil {
-		db.Log.Error("Database removeQuantity() -> Read SQL file", err)
+// AddOrder creates an order of one product and ships it from a single
+// warehouse: warehouseID if given, otherwise the one allocateWarehouse picks
+// for the delivery location. The units are taken from the warehouse's lots
+// first-expired-first-out, and the lots used are kept with the order line so
+// that a refund returns them.
+//
+// If no warehouse has the units and the product allows backorders, the order
+// is created as backordered instead and takes no stock until goods arrive;
+// see allocateBackorders. The status of the new order is returned.
+func (db *Database) AddOrder(customerID, productID, quantity int64, price float64, warehouseID *int64, latitude, longitude *float64, user string) (int64, int64, string, error) {
+	var orderID, orderDetailID int64
+	var status string
+	err := db.WithTx(func(tx *sql.Tx) error {
+		var err error
+		orderID, orderDetailID, status, err = db.addOrder(tx, customerID, productID, quantity, price, warehouseID, latitude, longitude, true, user)
 		return err
-	}
-
-	tx, err := db.Begin()
-	committed := false
+	})
 	if err != nil {
-		db.Log.Error("Database removeQuantity() -> db.Begin()", err)
-		return err
+		db.Log.Error("Database AddOrder() -> db.WithTx()", slog.String("error", err.Error()))
+		return 0, 0, "", err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
-	if _, err = tx.Exec(string(query), productID, quantity); err != nil {
-		db.Log.Error("Database removeQuantity() -> tx.Exec()", err)
-		return err
-	}
-
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database ShowProducts() -> tx.Commit()", err)
-		return err
-	}
-	committed = true
 
-	return nil
+	return orderID, orderDetailID, status, nil
 }
 
-func (db *Database) AddOrder(customerID, productID, quantity int64, price float64) (int64, int64, error) {
+// addOrder creates an order as described for AddOrder inside tx and returns
+// the IDs of the order and its order line and the order status. Without
+// backorder the order fails if the stock is short, whatever the product
+// allows.
+func (db *Database) addOrder(tx *sql.Tx, customerID, productID, quantity int64, price float64, warehouseID *int64, latitude, longitude *float64, backorder bool, user string) (int64, int64, string, error) {
 	queryOrder, err := os.ReadFile(ordersPath + "add_orders.sql")
 	if err != nil {
-		db.Log.Error("Database ShowProducts() -> Read SQL file", err)
-		return 0, 0, err
+		db.Log.Error("Database AddOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, 0, "", err
 	}
 	queryOrderDetails, err := os.ReadFile(ordersPath + "add_order_details.sql")
 	if err != nil {
-		db.Log.Error("Database ShowProducts() -> Read SQL file", err)
-		return 0, 0, err
+		db.Log.Error("Database AddOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, 0, "", err
 	}
 
-	tx, err := db.Begin()
-	committed := false
+	status := OrderNew
+	warehouse, err := db.allocateWarehouse(tx, productID, quantity, warehouseID, latitude, longitude)
+	if errors.Is(err, ErrInsufficientStock) && backorder {
+		allowed, allowErr := db.allowsBackorders(tx, productID)
+		if allowErr != nil {
+			return 0, 0, "", allowErr
+		}
+		if allowed {
+			status, err = OrderBackordered, nil
+		}
+	}
 	if err != nil {
-		db.Log.Error("Database AddCustomer() -> db.Begin()", err)
-		return 0, 0, err
+		return 0, 0, "", err
+	}
+
+	// A backordered line keeps the warehouse asked for, if any, and gets
+	// one when it is allocated. It stays marked as backordered if the order
+	// is cancelled first, so that it is never taken for a shipped line.
+	warehouseNull := sql.NullInt64{Int64: warehouse, Valid: status == OrderNew}
+	if status == OrderBackordered && warehouseID != nil {
+		warehouseNull = sql.NullInt64{Int64: *warehouseID, Valid: true}
 	}
 
 	var orderID, orderDetailID int64
-	err = tx.QueryRow(string(queryOrder), customerID).Scan(&orderID)
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-	if err != nil {
-		db.Log.Error("Database AddOrder() -> QueryRow() order", err)
-		return 0, 0, err
+	if err := tx.QueryRow(string(queryOrder), customerID, status).Scan(&orderID); err != nil {
+		db.Log.Error("Database AddOrder() -> QueryRow() order", slog.String("error", err.Error()))
+		return 0, 0, "", err
 	}
 
-	err = tx.QueryRow(string(queryOrderDetails), orderID, productID, quantity, price).Scan(&orderDetailID)
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-	if err != nil {
-		db.Log.Error("Database AddOrder() -> QueryRow() orderDetail", err)
-		return 0, 0, err
+	if err := tx.QueryRow(string(queryOrderDetails), orderID, productID, quantity, price, warehouseNull, status == OrderBackordered).Scan(&orderDetailID); err != nil {
+		db.Log.Error("Database AddOrder() -> QueryRow() orderDetail", slog.String("error", err.Error()))
+		return 0, 0, "", err
+	}
+
+	if status == OrderBackordered {
+		return orderID, orderDetailID, status, nil
 	}
 
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database AddOrder() -> tx.Commit()", err)
-		return 0, 0, err
+	err = db.shipOrderLine(tx, orderID, orderDetailID, productID, warehouse, quantity, "order placed", user)
+	return orderID, orderDetailID, status, err
+}
+
+// shipOrderLine takes the units of an order line out of its warehouse: out
+// of the warehouse's lots first-expired-first-out, keeping the lots used with
+// the line so that a refund returns them, and out of the stock as a sale in
+// the ledger. The warehouse must have the units, as allocateWarehouse makes
+// sure; if they are short only because lots have expired, it fails before
+// anything is changed.
+func (db *Database) shipOrderLine(tx *sql.Tx, orderID, orderDetailID, productID, warehouseID, quantity int64, reason, user string) error {
+	queryLots, err := os.ReadFile(lotsPath + "add_order_detail_lots.sql")
+	if err != nil {
+		db.Log.Error("Database shipOrderLine() -> Read SQL file", slog.String("error", err.Error()))
+		return err
 	}
-	committed = true
 
-	if err = db.removeQuantity(productID, quantity); err != nil {
-		db.Log.Error("Database AddOrder() -> db.removeQuantity()", err)
-		return 0, 0, err
+	takes, err := db.takeLots(tx, productID, warehouseID, quantity, true)
+	if err != nil {
+		return err
+	}
+	for _, take := range takes {
+		if _, err := tx.Exec(string(queryLots), orderDetailID, take.lotID, take.quantity); err != nil {
+			return err
+		}
 	}
 
-	return orderID, orderDetailID, nil
+	return db.moveStock(tx, StockMovement{
+		ProductID:      productID,
+		WarehouseID:    warehouseID,
+		QuantityChange: -quantity,
+		Type:           MovementSale,
+		Reason:         reason,
+		ReferenceType:  "order",
+		ReferenceID:    &orderID,
+		CreatedBy:      user,
+	})
 }
 
 func (db *Database) readRowsOrderInfo(rows *sql.Rows) ([]OrderInfo, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -134,116 +231,137 @@ func (db *Database) readRowsOrderInfo(rows *sql.Rows) ([]OrderInfo, error) {
 	for rows.Next() {
 		var o OrderInfo
 		if err := rows.Scan(&o.OrderID, &o.Status, &o.CreatedAt); err != nil {
-			db.Log.Error("Database readRowsOrderInfo() -> parsing rows", err)
+			db.Log.Error("Database readRowsOrderInfo() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
 		products = append(products, o)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsOrderInfo() -> rows.Err()", err)
+		db.Log.Error("Database readRowsOrderInfo() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return products, nil
 }
 
-func (db *Database) ShowByCustomerOrders(customerID int64, limit *int) ([]OrderInfo, error) {
+func (db *Database) ShowByCustomerOrders(customerID int64, page Page) ([]OrderInfo, string, error) {
 	query, err := os.ReadFile(ordersPath + "id_by_customer_orders.sql")
 	if err != nil {
-		db.Log.Error("Database ShowByCustomerOrders() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowByCustomerOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := orderInfoList.paginate(string(query), []any{customerID}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), customerID, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowByCustomerOrders() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowByCustomerOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsOrderInfo(rows)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return db.readRowsOrderInfo(rows)
+	items, next := orderInfoList.nextPage(items, page)
+	return items, next, nil
 }
 
-func (db *Database) ShowByDateOrders(startDateRange, endDateRange time.Time, limit *int) ([]OrderInfo, error) {
+func (db *Database) ShowByDateOrders(startDateRange, endDateRange time.Time, page Page) ([]OrderInfo, string, error) {
 	query, err := os.ReadFile(ordersPath + "id_by_date_orders.sql")
 	if err != nil {
-		db.Log.Error("Database ShowByDateOrders() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowByDateOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := orderInfoList.paginate(string(query), []any{startDateRange, endDateRange}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), startDateRange, endDateRange, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowByDateOrders() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowByDateOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsOrderInfo(rows)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return db.readRowsOrderInfo(rows)
+	items, next := orderInfoList.nextPage(items, page)
+	return items, next, nil
 }
 
-func (db *Database) ShowByStatusOrders(status string, limit *int) ([]OrderInfo, error) {
+func (db *Database) ShowByStatusOrders(status string, page Page) ([]OrderInfo, string, error) {
 	query, err := os.ReadFile(ordersPath + "id_by_status.sql")
 	if err != nil {
-		db.Log.Error("Database ShowByStatusOrders() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowByStatusOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := orderInfoList.paginate(string(query), []any{status}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), status, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowByStatusOrders() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowByStatusOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	return db.readRowsOrderInfo(rows)
-}
-
-func (db *Database) RefundOrder(orderID int64) error {
-	query, err := os.ReadFile(ordersPath + "refund_orders.sql")
+	items, err := db.readRowsOrderInfo(rows)
 	if err != nil {
-		db.Log.Error("Database RefundOrder() -> Read SQL file", err)
-		return err
+		return nil, "", err
 	}
 
-	tx, err := db.Begin()
+	items, next := orderInfoList.nextPage(items, page)
+	return items, next, nil
+}
+
+// returnOrderStock moves the order to status if it is in one of from and puts
+// the stock of its shipped lines back on the shelf as refund movements. It
+// returns whether the order was moved.
+func (db *Database) returnOrderStock(tx *sql.Tx, orderID int64, status string, from []string, user string) (bool, error) {
+	query, err := os.ReadFile(ordersPath + "return_stock_orders.sql")
 	if err != nil {
-		db.Log.Error("Database RefundOrder() -> db.Begin()", err)
-		return err
+		return false, err
 	}
-	defer func() {
-		if err := tx.Rollback(); err != nil {
-			db.Log.Error("Some troubles after tx.Rollback()", err)
-		}
-	}()
 
-	if _, err = tx.Exec(string(query), orderID); err != nil {
-		db.Log.Error("Database RefundOrder() -> tx.Exec()", err)
-		return err
-	}
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database RefundOrder() -> tx.Commit()", err)
-		return err
+	var returned int64
+	if err := tx.QueryRow(string(query), orderID, user, status, pq.Array(from)).Scan(&returned); err != nil {
+		return false, err
 	}
+	return returned > 0, nil
+}
 
-	return nil
+func (db *Database) RefundOrder(orderID int64, user string) error {
+	err := db.WithTx(func(tx *sql.Tx) error {
+		refunded, err := db.returnOrderStock(tx, orderID, OrderRefunded, orderRefundable, user)
+		if err != nil {
+			return err
+		}
+		if !refunded {
+			return ErrOrderNotRefundable
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrOrderNotRefundable) {
+		db.Log.Error("Database RefundOrder() -> tx.QueryRow()", slog.String("error", err.Error()))
+	}
+	return err
 }
 
 func (db *Database) readRowsOrder(rows *sql.Rows) ([]Order, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -252,44 +370,50 @@ func (db *Database) readRowsOrder(rows *sql.Rows) ([]Order, error) {
 	for rows.Next() {
 		var o Order
 		if err := rows.Scan(&o.OrderID, &o.CustomerID, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
-			db.Log.Error("Database readRowsOrder() -> parsing rows", err)
+			db.Log.Error("Database readRowsOrder() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
 		products = append(products, o)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsOrder() -> rows.Err()", err)
+		db.Log.Error("Database readRowsOrder() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return products, nil
 }
 
-func (db *Database) ShowCustomerOrders(customerID int64, limit *int) ([]Order, error) {
+func (db *Database) ShowCustomerOrders(customerID int64, page Page) ([]Order, string, error) {
 	query, err := os.ReadFile(ordersPath + "show_customer_orders.sql")
 	if err != nil {
-		db.Log.Error("Database RefundOrder() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowCustomerOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := orderList.paginate(string(query), []any{customerID}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), customerID, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowByCustomerOrders() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowCustomerOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsOrder(rows)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return db.readRowsOrder(rows)
+	items, next := orderList.nextPage(items, page)
+	return items, next, nil
 }
 
 func (db *Database) readRowsOrderDetail(rows *sql.Rows) ([]OrderDetail, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -297,15 +421,19 @@ func (db *Database) readRowsOrderDetail(rows *sql.Rows) ([]OrderDetail, error) {
 
 	for rows.Next() {
 		var o OrderDetail
-		if err := rows.Scan(&o.OrderDetailID, &o.Quantity, &o.Price, &o.Name); err != nil {
-			db.Log.Error("Database readRowsOrderDetail() -> parsing rows", err)
+		var warehouseID sql.NullInt64
+		if err := rows.Scan(&o.OrderDetailID, &o.ProductID, &warehouseID, &o.Backordered, &o.Quantity, &o.Price, &o.Name); err != nil {
+			db.Log.Error("Database readRowsOrderDetail() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
+		if warehouseID.Valid {
+			o.WarehouseID = &warehouseID.Int64
+		}
 		products = append(products, o)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsOrderDetail() -> rows.Err()", err)
+		db.Log.Error("Database readRowsOrderDetail() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return products, nil
@@ -314,7 +442,7 @@ func (db *Database) readRowsOrderDetail(rows *sql.Rows) ([]OrderDetail, error) {
 func (db *Database) ShowOrderDetails(orderID int64, limit *int) ([]OrderDetail, error) {
 	query, err := os.ReadFile(ordersPath + "show_order_details.sql")
 	if err != nil {
-		db.Log.Error("Database ShowOrderDetails() -> Read SQL file", err)
+		db.Log.Error("Database ShowOrderDetails() -> Read SQL file", slog.String("error", err.Error()))
 		return nil, err
 	}
 
@@ -325,75 +453,102 @@ func (db *Database) ShowOrderDetails(orderID int64, limit *int) ([]OrderDetail,
 
 	rows, err := db.Query(string(query), orderID, limitValue)
 	if err != nil {
-		db.Log.Error("Database ShowOrderDetails() -> tx.Query()", err)
+		db.Log.Error("Database ShowOrderDetails() -> tx.Query()", slog.String("error", err.Error()))
 		return nil, err
 	}
 
 	return db.readRowsOrderDetail(rows)
 }
 
-func (db *Database) UpdateStatusOrder(orderID int64, status string) error {
+// UpdateStatusOrder moves the order to status. Moves orderTransitions does
+// not allow return ErrOrderStatus. Cancelling an order returns the stock of
+// its shipped lines the way RefundOrder does.
+func (db *Database) UpdateStatusOrder(orderID int64, status, user string) error {
 	query, err := os.ReadFile(ordersPath + "status_orders.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateStatusOrder() -> Read SQL file", err)
+		db.Log.Error("Database UpdateStatusOrder() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
+	statusQuery, err := os.ReadFile(ordersPath + "check_refund_order.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateStatusOrder() -> db.Begin()", err)
+		db.Log.Error("Database UpdateStatusOrder() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
-	defer func() {
-		if err := tx.Rollback(); err != nil {
-			db.Log.Error("Some troubles after tx.Rollback()", err)
-		}
-	}()
 
-	if _, err = tx.Exec(string(query), orderID, status); err != nil {
-		db.Log.Error("Database UpdateStatusOrder() -> tx.Exec()", err)
-		return err
-	}
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database UpdateStatusOrder() -> tx.Commit()", err)
-		return err
+	from, ok := orderTransitions[status]
+	if !ok {
+		return ErrOrderStatus
 	}
 
-	return nil
+	err = db.WithTx(func(tx *sql.Tx) error {
+		if status == OrderCancelled {
+			cancelled, err := db.returnOrderStock(tx, orderID, status, from, user)
+			if err != nil || cancelled {
+				return err
+			}
+		} else {
+			result, err := tx.Exec(string(query), orderID, status, pq.Array(from))
+			if err != nil {
+				return err
+			}
+			if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+				return err
+			}
+		}
+
+		var current string
+		if err := tx.QueryRow(string(statusQuery), orderID).Scan(&current); errors.Is(err, sql.ErrNoRows) {
+			return ErrNoOrderFound
+		} else if err != nil {
+			return err
+		}
+		return ErrOrderStatus
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database UpdateStatusOrder() -> tx.Exec()", slog.String("error", err.Error()))
+	}
+	return err
 }
 
-func (db *Database) ShowByStatusFullOrders(status string, limit *int) ([]Order, error) {
+func (db *Database) ShowByStatusFullOrders(status string, page Page) ([]Order, string, error) {
 	query, err := os.ReadFile(ordersPath + "show_orders_by_status.sql")
 	if err != nil {
-		db.Log.Error("Database RefundOrder() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowByStatusFullOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := orderList.paginate(string(query), []any{status}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), status, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowByCustomerOrders() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowByStatusFullOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	return db.readRowsOrder(rows)
+	items, err := db.readRowsOrder(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items, next := orderList.nextPage(items, page)
+	return items, next, nil
 }
 
 func (db *Database) CheckOrderExists(orderID int64) (bool, error) {
 	query, err := os.ReadFile(ordersPath + "check_order_exists.sql")
 	if err != nil {
-		db.Log.Error("Database CheckOrderExists() -> Read SQL file", err)
+		db.Log.Error("Database CheckOrderExists() -> Read SQL file", slog.String("error", err.Error()))
 		return false, err
 	}
 
 	var exists bool
 	err = db.QueryRow(string(query), orderID).Scan(&exists)
 	if err != nil {
-		db.Log.Error("Database CheckOrderExists() -> QueryRow()", err)
+		db.Log.Error("Database CheckOrderExists() -> QueryRow()", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -401,16 +556,18 @@ func (db *Database) CheckOrderExists(orderID int64) (bool, error) {
 }
 
 func (db *Database) GetOrderStatus(orderID int64) (string, error) {
-	query, err := os.ReadFile(ordersPath + "check_order_exists.sql")
+	query, err := os.ReadFile(ordersPath + "check_refund_order.sql")
 	if err != nil {
-		db.Log.Error("Database GetOrderStatus() -> Read SQL file", err)
+		db.Log.Error("Database GetOrderStatus() -> Read SQL file", slog.String("error", err.Error()))
 		return "", err
 	}
 
 	var status string
 	err = db.QueryRow(string(query), orderID).Scan(&status)
-	if err != nil {
-		db.Log.Error("Database GetOrderStatus() -> Error retrieving order status", err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoOrderFound
+	} else if err != nil {
+		db.Log.Error("Database GetOrderStatus() -> Error retrieving order status", slog.String("error", err.Error()))
 		return "", err
 	}
 	return status, nil
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const defaultPageSize = 1000
+const maxPageSize = 1000
+
+var ErrInvalidSort error = &Error{Kind: ErrInvalidArgument, Message: "unknown sort field"}
+var ErrInvalidCursor error = &Error{Kind: ErrInvalidArgument, Message: "invalid or expired cursor"}
+
+// Page selects one page of a list. Sort must be one of the keys accepted by
+// the listed entity (empty means its default), Cursor is the NextCursor of
+// the previous page and Limit defaults to and is capped at 1000 rows.
+// Filters narrow the list; a cursor is only meaningful with the filters it
+// was issued for.
+type Page struct {
+	Sort    string
+	Desc    bool
+	Cursor  string
+	Limit   *int
+	Filters []Filter
+}
+
+// cursor is the decoded form of the opaque token handed to clients: the sort
+// it belongs to and the position of the last row of the previous page.
+type cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int64  `json:"i"`
+}
+
+type sortKey[T any] struct {
+	column string
+	cast   string
+	value  func(T) string
+}
+
+// listSpec is the sort and filter whitelist of one entity. Rows are always
+// ordered by the chosen key and then by id, which makes every position unique
+// and lets the next page start strictly after the last row instead of using
+// OFFSET.
+type listSpec[T any] struct {
+	id      string
+	idValue func(T) int64
+	def     string
+	keys    map[string]sortKey[T]
+	filters map[string]filterField
+}
+
+// paginate appends the filter conditions, the keyset condition, ORDER BY and
+// LIMIT to query. query must end inside a WHERE clause; args are its existing
+// parameters. One row more than requested is fetched so nextPage can tell
+// whether more follow.
+func (k listSpec[T]) paginate(query string, args []any, page Page) (string, []any, error) {
+	query, args, err := applyFilters(query, args, page.Filters, k.filters)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := page.Sort
+	if name == "" {
+		name = k.def
+	}
+	key, ok := k.keys[name]
+	if !ok {
+		return "", nil, ErrInvalidSort
+	}
+
+	direction, comparison := "ASC", ">"
+	if page.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil || c.Sort != name || c.Desc != page.Desc || !validCursorValue(key.cast, c.Value) {
+			return "", nil, ErrInvalidCursor
+		}
+		args = append(args, c.Value, c.ID)
+		query += fmt.Sprintf("\n    AND (%s, %s) %s ($%d::%s, $%d)", key.column, k.id, comparison, len(args)-1, key.cast, len(args))
+	}
+
+	args = append(args, pageSize(page.Limit)+1)
+	query += fmt.Sprintf("\nORDER BY %s %s, %s %s\nLIMIT $%d", key.column, direction, k.id, direction, len(args))
+	return query, args, nil
+}
+
+// nextPage trims the extra row fetched by paginate and returns the cursor of
+// the following page, or an empty string on the last page.
+func (k listSpec[T]) nextPage(rows []T, page Page) ([]T, string) {
+	size := pageSize(page.Limit)
+	if len(rows) <= size {
+		return rows, ""
+	}
+	rows = rows[:size]
+
+	name := page.Sort
+	if name == "" {
+		name = k.def
+	}
+	last := rows[len(rows)-1]
+	return rows, encodeCursor(cursor{Sort: name, Desc: page.Desc, Value: k.keys[name].value(last), ID: k.idValue(last)})
+}
+
+func pageSize(limit *int) int {
+	if limit == nil || *limit <= 0 {
+		return defaultPageSize
+	}
+	if *limit > maxPageSize {
+		return maxPageSize
+	}
+	return *limit
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// validCursorValue reports whether a cursor value can be cast to the type of
+// its sort key, so a tampered cursor is rejected before it reaches the query.
+func validCursorValue(cast, value string) bool {
+	var err error
+	switch cast {
+	case "BIGINT":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "NUMERIC":
+		_, err = strconv.ParseFloat(value, 64)
+	case "TIMESTAMP":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	}
+	return err == nil
+}
+
+func intValue(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func floatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func timeValue(v time.Time) string {
+	return v.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pageItem struct {
+	id      int64
+	name    string
+	price   float64
+	created time.Time
+}
+
+var pageItemList = listSpec[pageItem]{
+	id:      "i.item_id",
+	idValue: func(i pageItem) int64 { return i.id },
+	def:     "id",
+	keys: map[string]sortKey[pageItem]{
+		"id":         {column: "i.item_id", cast: "BIGINT", value: func(i pageItem) string { return intValue(i.id) }},
+		"name":       {column: "i.name", cast: "TEXT", value: func(i pageItem) string { return i.name }},
+		"price":      {column: "i.price", cast: "NUMERIC", value: func(i pageItem) string { return floatValue(i.price) }},
+		"created_at": {column: "i.created_at", cast: "TIMESTAMP", value: func(i pageItem) string { return timeValue(i.created) }},
+	},
+	filters: map[string]filterField{
+		"name": {column: "i.name", kind: textField},
+	},
+}
+
+const pageQuery = "SELECT * FROM items i WHERE i.deleted_at IS NULL"
+
+func pageItems(n int) []pageItem {
+	items := make([]pageItem, n)
+	for i := range items {
+		items[i] = pageItem{
+			id:      int64(i + 1),
+			name:    "item " + intValue(int64(i+1)),
+			price:   float64(i) + 0.5,
+			created: time.Date(2024, 5, 1, 10, 0, 0, i*1000, time.UTC),
+		}
+	}
+	return items
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := cursor{Sort: "name", Desc: true, Value: "a,b|c \"quoted\"", ID: 42}
+	got, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if got != c {
+		t.Fatalf("decodeCursor() = %+v, want %+v", got, c)
+	}
+}
+
+func TestPaginateFirstPage(t *testing.T) {
+	limit := 2
+	query, args, err := pageItemList.paginate(pageQuery, []any{true}, Page{Limit: &limit})
+	if err != nil {
+		t.Fatalf("paginate() error = %v", err)
+	}
+	want := pageQuery + "\nORDER BY i.item_id ASC, i.item_id ASC\nLIMIT $2"
+	if query != want {
+		t.Errorf("paginate() query = %q, want %q", query, want)
+	}
+	if !reflect.DeepEqual(args, []any{true, 3}) {
+		t.Errorf("paginate() args = %v, want [true 3]", args)
+	}
+}
+
+func TestPaginateNextPageRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort       string
+		desc       bool
+		comparison string
+		cast       string
+		value      string
+	}{
+		{sort: "", comparison: ">", cast: "BIGINT", value: "2"},
+		{sort: "name", comparison: ">", cast: "TEXT", value: "item 2"},
+		{sort: "price", desc: true, comparison: "<", cast: "NUMERIC", value: "1.5"},
+		{sort: "created_at", comparison: ">", cast: "TIMESTAMP", value: "2024-05-01T10:00:00.000001Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			limit := 2
+			page := Page{Sort: tt.sort, Desc: tt.desc, Limit: &limit}
+
+			rows, next := pageItemList.nextPage(pageItems(3), page)
+			if len(rows) != 2 {
+				t.Fatalf("nextPage() kept %d rows, want 2", len(rows))
+			}
+			if next == "" {
+				t.Fatal("nextPage() returned no cursor although a row was left over")
+			}
+
+			page.Cursor = next
+			query, args, err := pageItemList.paginate(pageQuery, nil, page)
+			if err != nil {
+				t.Fatalf("paginate() error = %v", err)
+			}
+			column := pageItemList.keys[tt.sort].column
+			if tt.sort == "" {
+				column = "i.item_id"
+			}
+			condition := "AND (" + column + ", i.item_id) " + tt.comparison + " ($1::" + tt.cast + ", $2)"
+			if !strings.Contains(query, condition) {
+				t.Errorf("paginate() query = %q, want it to contain %q", query, condition)
+			}
+			if !reflect.DeepEqual(args, []any{tt.value, int64(2), 3}) {
+				t.Errorf("paginate() args = %v, want [%s 2 3]", args, tt.value)
+			}
+		})
+	}
+}
+
+func TestNextPageLastPage(t *testing.T) {
+	limit := 3
+	rows, next := pageItemList.nextPage(pageItems(3), Page{Limit: &limit})
+	if len(rows) != 3 || next != "" {
+		t.Fatalf("nextPage() = %d rows, cursor %q, want 3 rows and no cursor", len(rows), next)
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	zero, small, large := 0, 10, maxPageSize+1
+	for _, tt := range []struct {
+		limit *int
+		want  int
+	}{
+		{nil, defaultPageSize},
+		{&zero, defaultPageSize},
+		{&small, 10},
+		{&large, maxPageSize},
+	} {
+		if got := pageSize(tt.limit); got != tt.want {
+			t.Errorf("pageSize(%v) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestPaginateRejectsCursors(t *testing.T) {
+	valid := encodeCursor(cursor{Sort: "id", Value: "2", ID: 2})
+	tests := []struct {
+		name   string
+		page   Page
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!not-a-cursor!!!"},
+		{name: "padded base64", cursor: valid + "=="},
+		{name: "base64 but not JSON", cursor: "bm90IGpzb24"},
+		{name: "truncated", cursor: valid[:len(valid)-3]},
+		{name: "other sort key", page: Page{Sort: "name"}, cursor: valid},
+		{name: "other direction", page: Page{Desc: true}, cursor: valid},
+		{name: "tampered integer value", cursor: encodeCursor(cursor{Sort: "id", Value: "2 OR 1=1", ID: 2})},
+		{name: "tampered number value", page: Page{Sort: "price"}, cursor: encodeCursor(cursor{Sort: "price", Value: "cheap", ID: 2})},
+		{name: "tampered time value", page: Page{Sort: "created_at"}, cursor: encodeCursor(cursor{Sort: "created_at", Value: "yesterday", ID: 2})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.page.Cursor = tt.cursor
+			_, _, err := pageItemList.paginate(pageQuery, nil, tt.page)
+			if !errors.Is(err, ErrInvalidArgument) || err.Error() != ErrInvalidCursor.Error() {
+				t.Fatalf("paginate() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestPaginateRejectsUnknownSort(t *testing.T) {
+	_, _, err := pageItemList.paginate(pageQuery, nil, Page{Sort: "password"})
+	if !errors.Is(err, ErrInvalidArgument) || err != ErrInvalidSort {
+		t.Fatalf("paginate() error = %v, want %v", err, ErrInvalidSort)
+	}
+}
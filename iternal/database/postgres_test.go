@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/likimiad/golang-restapi-inventory-managment-system/iternal/database/dbtest"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// These tests run against the PostgreSQL configured for dbtest. The
+// connection tests reach it through a proxy that is started and stopped to
+// take the database up and down.
+
+func newPostgresDatabase(t *testing.T, proxy *dbtest.Proxy) *Database {
+	t.Helper()
+	db := InitDatabase(proxy.Config(dbtest.Config(t)), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestWaitForConnectionPostgresStartsLate(t *testing.T) {
+	proxy := dbtest.NewProxy(t, dbtest.Config(t))
+	db := newPostgresDatabase(t, proxy)
+
+	result := make(chan error, 1)
+	go func() { result <- db.WaitForConnection(context.Background()) }()
+
+	select {
+	case err := <-result:
+		t.Fatalf("WaitForConnection() returned %v before the database was up", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	proxy.Start(t)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("WaitForConnection() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForConnection() did not return after the database came up")
+	}
+}
+
+func TestMonitorConnectionPostgresRestarts(t *testing.T) {
+	proxy := dbtest.NewProxy(t, dbtest.Config(t))
+	db := newPostgresDatabase(t, proxy)
+	proxy.Start(t)
+	if err := db.WaitForConnection(context.Background()); err != nil {
+		t.Fatalf("WaitForConnection() error = %v", err)
+	}
+	db.SetReady(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		db.MonitorConnection(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	proxy.Stop()
+	eventually(t, "the database is reported down", func() bool { return !db.IsReady() })
+
+	proxy.Start(t)
+	eventually(t, "the database is reported ready again", db.IsReady)
+}
+
+// newScratchTable connects to the test database and creates a table that
+// is dropped when the test ends.
+func newScratchTable(t *testing.T) (*Database, string) {
+	t.Helper()
+	db := InitDatabase(dbtest.Config(t), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { db.Close() })
+
+	table := fmt.Sprintf("tx_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %s (id INT PRIMARY KEY)", table)); err != nil {
+		t.Fatalf("create %s: %v", table, err)
+	}
+	t.Cleanup(func() { db.Exec(fmt.Sprintf("DROP TABLE %s", table)) })
+	return db, table
+}
+
+func countRows(t *testing.T, db *Database, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestWithTxPostgres(t *testing.T) {
+	db, table := newScratchTable(t)
+	insert := func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s VALUES (1)", table))
+		return err
+	}
+
+	errFailed := errors.New("failed")
+	if err := db.WithTx(func(tx *sql.Tx) error {
+		if err := insert(tx); err != nil {
+			return err
+		}
+		return errFailed
+	}); !errors.Is(err, errFailed) {
+		t.Fatalf("WithTx() error = %v, want %v", err, errFailed)
+	}
+	if n := countRows(t, db, table); n != 0 {
+		t.Fatalf("%d rows after an error, want the insert rolled back", n)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("recovered %v, want the panic to be re-raised", p)
+			}
+		}()
+		_ = db.WithTx(func(tx *sql.Tx) error {
+			if err := insert(tx); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if n := countRows(t, db, table); n != 0 {
+		t.Fatalf("%d rows after a panic, want the insert rolled back", n)
+	}
+
+	if err := db.WithTx(insert); err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if n := countRows(t, db, table); n != 1 {
+		t.Fatalf("%d rows after a commit, want 1", n)
+	}
+
+	if err := db.WithTx(insert); !errors.Is(err, ErrConflict) {
+		t.Fatalf("WithTx() error = %v on a duplicate key, want %v", err, ErrConflict)
+	}
+}
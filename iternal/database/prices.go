@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// ProductPrice is one entry of a product's price history. EffectiveTo is the
+// moment the next price took over and is nil for the current price. Scheduled
+// changes that have not been applied yet have a nil AppliedAt.
+type ProductPrice struct {
+	PriceID       int64      `json:"price_id"`
+	ProductID     int64      `json:"product_id"`
+	Price         float64    `json:"price"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	AppliedAt     *time.Time `json:"applied_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+var ErrNoScheduledPrice error = &Error{Kind: ErrNotFound, Message: "no scheduled price change found with the provided ID"}
+
+// SchedulePriceChange stores a price that RunPriceScheduler applies to the
+// product once effectiveFrom has passed.
+func (db *Database) SchedulePriceChange(productID int64, price float64, effectiveFrom time.Time) (int64, error) {
+	query, err := os.ReadFile(productsPath + "schedule_price_products.sql")
+	if err != nil {
+		db.Log.Error("Database SchedulePriceChange() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var priceID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(string(query), productID, price, effectiveFrom).Scan(&priceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoProductFound
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNoProductFound) {
+		db.Log.Error("Database SchedulePriceChange() -> tx.QueryRow().Scan()", slog.String("error", err.Error()))
+	}
+	return priceID, err
+}
+
+// CancelPriceChange removes a scheduled price change that has not been
+// applied yet.
+func (db *Database) CancelPriceChange(priceID int64) error {
+	query, err := os.ReadFile(productsPath + "cancel_price_products.sql")
+	if err != nil {
+		db.Log.Error("Database CancelPriceChange() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), priceID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoScheduledPrice)
+	})
+	if err != nil && !errors.Is(err, ErrNoScheduledPrice) {
+		db.Log.Error("Database CancelPriceChange()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ApplyScheduledPrices applies every scheduled price that became effective
+// before the given moment and returns the number of updated products. When
+// several changes of one product are due, the latest one wins and the others
+// stay in the history. Changes of deleted products wait until the product is
+// restored.
+func (db *Database) ApplyScheduledPrices(before time.Time) (int64, error) {
+	query, err := os.ReadFile(productsPath + "apply_prices_products.sql")
+	if err != nil {
+		db.Log.Error("Database ApplyScheduledPrices() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var updated int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), before)
+		if err != nil {
+			return err
+		}
+		updated, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		db.Log.Error("Database ApplyScheduledPrices()", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return updated, nil
+}
+
+// RunPriceScheduler calls ApplyScheduledPrices every PriceScheduleInterval
+// until the context is cancelled.
+func (db *Database) RunPriceScheduler(ctx context.Context) {
+	ticker := time.NewTicker(db.cfg.PriceScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !db.IsReady() {
+			continue
+		}
+
+		updated, err := db.ApplyScheduledPrices(time.Now())
+		if err != nil || updated == 0 {
+			continue
+		}
+		db.Log.Info("applied scheduled price changes", slog.Int64("products", updated))
+	}
+}
+
+// ProductPriceHistory returns the prices of a product, newest first,
+// including scheduled changes. from and to optionally narrow the history to
+// the prices in effect during that period.
+func (db *Database) ProductPriceHistory(productID int64, from, to *time.Time) ([]ProductPrice, error) {
+	query, err := os.ReadFile(productsPath + "price_history_products.sql")
+	if err != nil {
+		db.Log.Error("Database ProductPriceHistory() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	fromNull := sql.NullTime{Valid: from != nil}
+	if from != nil {
+		fromNull.Time = *from
+	}
+	toNull := sql.NullTime{Valid: to != nil}
+	if to != nil {
+		toNull.Time = *to
+	}
+
+	rows, err := db.Query(string(query), productID, fromNull, toNull)
+	if err != nil {
+		db.Log.Error("Database ProductPriceHistory() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var prices []ProductPrice
+	for rows.Next() {
+		var p ProductPrice
+		var effectiveTo, appliedAt sql.NullTime
+		if err := rows.Scan(&p.PriceID, &p.ProductID, &p.Price, &p.EffectiveFrom, &effectiveTo, &appliedAt, &p.CreatedAt); err != nil {
+			db.Log.Error("Database ProductPriceHistory() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if effectiveTo.Valid {
+			p.EffectiveTo = &effectiveTo.Time
+		}
+		if appliedAt.Valid {
+			p.AppliedAt = &appliedAt.Time
+		}
+		prices = append(prices, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ProductPriceHistory() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return prices, nil
+}
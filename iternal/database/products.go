@@ -3,20 +3,45 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"log/slog"
 	"os"
 	"time"
 )
 
+// Product is a catalog item. ReorderPoint and ReorderQuantity are nil when
+// purchase suggestions derive them from the product's sales.
 type Product struct {
-	ProductID   int64     `json:"product_id"`
-	SupplierID  int64     `json:"supplier_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Quantity    int64     `json:"quantity"`
-	Category    string    `json:"category"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ProductID       int64      `json:"product_id"`
+	SupplierID      int64      `json:"supplier_id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Price           float64    `json:"price"`
+	Quantity        int64      `json:"quantity"`
+	Available       *int64     `json:"available,omitempty"`
+	CategoryID      *int64     `json:"category_id"`
+	Category        string     `json:"category"`
+	SKU             string     `json:"sku,omitempty"`
+	Barcode         string     `json:"barcode,omitempty"`
+	Unit            string     `json:"unit_of_measure"`
+	PackSize        int64      `json:"pack_size"`
+	TrackLots       bool       `json:"track_lots"`
+	TrackSerials    bool       `json:"track_serials"`
+	AllowBackorders bool       `json:"allow_backorders"`
+	ReorderPoint    *int64     `json:"reorder_point"`
+	ReorderQuantity *int64     `json:"reorder_quantity"`
+	SafetyStock     int64      `json:"safety_stock"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ProductSearchResult is a product matched by SearchProducts. Highlights wrap
+// the matched words in <mark></mark>.
+type ProductSearchResult struct {
+	Product
+	Rank                 float64 `json:"rank"`
+	NameHighlight        string  `json:"name_highlight"`
+	DescriptionHighlight string  `json:"description_highlight"`
 }
 
 type PurchaseRequest struct {
@@ -26,41 +51,84 @@ type PurchaseRequest struct {
 	ContactEmail string `json:"contact_email"`
 }
 
-var ErrNoProductFound = errors.New("no product found with the provided ID")
+var productList = listSpec[Product]{
+	id:      "p.product_id",
+	idValue: func(p Product) int64 { return p.ProductID },
+	def:     "id",
+	keys: map[string]sortKey[Product]{
+		"id":         {column: "p.product_id", cast: "BIGINT", value: func(p Product) string { return intValue(p.ProductID) }},
+		"name":       {column: "p.name", cast: "TEXT", value: func(p Product) string { return p.Name }},
+		"price":      {column: "p.price", cast: "NUMERIC", value: func(p Product) string { return floatValue(p.Price) }},
+		"quantity":   {column: "p.quantity", cast: "BIGINT", value: func(p Product) string { return intValue(p.Quantity) }},
+		"created_at": {column: "p.created_at", cast: "TIMESTAMP", value: func(p Product) string { return timeValue(p.CreatedAt) }},
+		"updated_at": {column: "p.updated_at", cast: "TIMESTAMP", value: func(p Product) string { return timeValue(p.UpdatedAt) }},
+	},
+	filters: map[string]filterField{
+		"id":              {column: "p.product_id", kind: numberField},
+		"supplier_id":     {column: "p.supplier_id", kind: numberField},
+		"category_id":     {column: "p.category_id", kind: numberField},
+		"category":        {column: "c.name", kind: textField},
+		"name":            {column: "p.name", kind: textField},
+		"description":     {column: "p.description", kind: textField},
+		"price":           {column: "p.price", kind: numberField},
+		"quantity":        {column: "p.quantity", kind: numberField},
+		"sku":             {column: "p.sku", kind: textField},
+		"barcode":         {column: "p.barcode", kind: textField},
+		"unit_of_measure": {column: "p.unit_of_measure", kind: textField},
+		"pack_size":       {column: "p.pack_size", kind: numberField},
+		"created_at":      {column: "p.created_at", kind: timeField},
+		"updated_at":      {column: "p.updated_at", kind: timeField},
+	},
+}
 
-func (db *Database) AddProduct(supplierID int64, name, description string, price float64, quantity int64, category string) (int64, error) {
+var ErrNoProductFound error = &Error{Kind: ErrNotFound, Message: "no product found with the provided ID"}
+var ErrNoProductDeleted error = &Error{Kind: ErrNotFound, Message: "no deleted product found with the provided ID"}
+var ErrNoProductSKU error = &Error{Kind: ErrNotFound, Message: "no product found with the provided SKU"}
+var ErrNoProductBarcode error = &Error{Kind: ErrNotFound, Message: "no product found with the provided barcode"}
+
+func (db *Database) AddProduct(supplierID int64, name, description string, price float64, quantity int64, categoryID int64, sku, barcode, unit string, packSize int64, trackLots, trackSerials, allowBackorders bool, user string) (int64, error) {
 	query, err := os.ReadFile(productsPath + "add_products.sql")
 	if err != nil {
-		db.Log.Error("Database AddCustomer() -> Read SQL file", err)
+		db.Log.Error("Database AddProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return 0, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
+	priceQuery, err := os.ReadFile(productsPath + "record_price_products.sql")
 	if err != nil {
-		db.Log.Error("Database AddCustomer() -> db.Begin()", err)
+		db.Log.Error("Database AddProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return 0, err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
 
 	var productID int64
-	err = tx.QueryRow(string(query), supplierID, name, description, price, quantity, category).Scan(&productID)
-	if err != nil {
-		db.Log.Error("Database AddCustomer() -> tx.QueryRow().Scan()", err)
-		return 0, err
-	}
+	err = db.WithTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(string(query), supplierID, name, description, price, quantity, categoryID,
+			sql.NullString{String: sku, Valid: sku != ""}, sql.NullString{String: barcode, Valid: barcode != ""}, unit, packSize, trackLots, trackSerials, allowBackorders).Scan(&productID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(priceQuery), productID); err != nil {
+			return err
+		}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database AddCustomer() -> tx.Commit()", err)
+		warehouse, err := db.resolveWarehouse(tx, nil)
+		if err != nil {
+			return err
+		}
+		return db.placeStock(tx, StockMovement{
+			ProductID:      productID,
+			WarehouseID:    warehouse,
+			QuantityChange: quantity,
+			Type:           MovementAdjustment,
+			Reason:         "opening balance",
+			ReferenceType:  "product",
+			ReferenceID:    &productID,
+			CreatedBy:      user,
+		})
+	})
+	if err != nil {
+		db.Log.Error("Database AddProduct() -> tx.QueryRow().Scan()", slog.String("error", err.Error()))
 		return 0, err
 	}
-	committed = true
 
 	return productID, nil
 }
@@ -68,75 +136,79 @@ func (db *Database) AddProduct(supplierID int64, name, description string, price
 func (db *Database) DeleteProduct(productID int64) error {
 	query, err := os.ReadFile(productsPath + "delete_products.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteProduct() -> Read SQL file", err)
+		db.Log.Error("Database DeleteProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database DeleteProduct() -> db.Begin()", err)
-		return err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Error("Some troubles after tx.Rollback()", err)
-			}
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), productID)
+		if err != nil {
+			return err
 		}
-	}()
-
-	result, err := tx.Exec(string(query), productID)
-	if err != nil {
-		db.Log.Error("Database DeleteProduct()", err)
-		return err
+		return requireAffected(result, ErrNoProductFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoProductFound) {
+		db.Log.Error("Database DeleteProduct()", slog.String("error", err.Error()))
 	}
+	return err
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (db *Database) RestoreProduct(productID int64) error {
+	query, err := os.ReadFile(productsPath + "restore_products.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteProduct() -> Checking rows affected", err)
+		db.Log.Error("Database RestoreProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNoProductFound
-	}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database DeleteProduct() -> tx.Commit()", err)
-		return err
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), productID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoProductDeleted)
+	})
+	if err != nil && !errors.Is(err, ErrNoProductDeleted) {
+		db.Log.Error("Database RestoreProduct()", slog.String("error", err.Error()))
 	}
-	committed = true
-
-	return nil
+	return err
 }
 
-func (db *Database) UpdateProduct(productID int64, name *string, supplierID *int64, description *string, price *float64, quantity *int64, category *string) error {
+// UpdateProduct changes the given fields of a product. Setting quantity is a
+// stock count of the product as a whole; the difference to the current
+// quantity is booked in warehouseID, which countWarehouse picks if it is nil.
+// A lower count cannot take stock held by active reservations.
+func (db *Database) UpdateProduct(productID int64, name *string, supplierID *int64, description *string, price *float64, quantity *int64, warehouseID *int64, categoryID *int64, sku, barcode, unit *string, packSize *int64, trackLots, trackSerials, allowBackorders *bool, user string) error {
 	query, err := os.ReadFile(productsPath + "set_products.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateProduct() -> Read SQL file", err)
+		db.Log.Error("Database UpdateProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
-	committed := false
+	priceQuery, err := os.ReadFile(productsPath + "record_price_products.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateProduct() -> db.Begin()", err)
+		db.Log.Error("Database UpdateProduct() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	lockQuery, err := os.ReadFile(productsPath + "lock_quantity_products.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
 
 	nameNull := sql.NullString{String: "", Valid: name != nil && *name != ""}
 	supplierIDNull := sql.NullInt64{Int64: 0, Valid: supplierID != nil && *supplierID > 0}
 	descriptionNull := sql.NullString{String: "", Valid: description != nil && *description != ""}
 	priceNull := sql.NullFloat64{Float64: 0, Valid: price != nil && *price >= 0}
 	quantityNull := sql.NullInt64{Int64: 0, Valid: quantity != nil && *quantity >= 0}
-	categoryNull := sql.NullString{String: "", Valid: category != nil && *category != ""}
+	categoryIDNull := sql.NullInt64{Int64: 0, Valid: categoryID != nil && *categoryID > 0}
+	skuNull := sql.NullString{String: "", Valid: sku != nil && *sku != ""}
+	barcodeNull := sql.NullString{String: "", Valid: barcode != nil && *barcode != ""}
+	unitNull := sql.NullString{String: "", Valid: unit != nil && *unit != ""}
+	packSizeNull := sql.NullInt64{Int64: 0, Valid: packSize != nil && *packSize > 0}
+	trackLotsNull := sql.NullBool{Bool: false, Valid: trackLots != nil}
+	trackSerialsNull := sql.NullBool{Bool: false, Valid: trackSerials != nil}
+	allowBackordersNull := sql.NullBool{Bool: false, Valid: allowBackorders != nil}
 
 	if nameNull.Valid {
 		nameNull.String = *name
@@ -153,32 +225,101 @@ func (db *Database) UpdateProduct(productID int64, name *string, supplierID *int
 	if quantityNull.Valid {
 		quantityNull.Int64 = *quantity
 	}
-	if categoryNull.Valid {
-		categoryNull.String = *category
+	if categoryIDNull.Valid {
+		categoryIDNull.Int64 = *categoryID
 	}
-
-	result, err := tx.Exec(string(query), productID, nameNull, supplierIDNull, descriptionNull, priceNull, quantityNull, categoryNull)
-	if err != nil {
-		db.Log.Error("Database UpdateProduct() -> tx.Exec()", err)
-		return err
+	if skuNull.Valid {
+		skuNull.String = *sku
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		db.Log.Error("Database UpdateProduct() -> Checking rows affected", err)
-		return err
+	if barcodeNull.Valid {
+		barcodeNull.String = *barcode
 	}
-	if rowsAffected == 0 {
-		return ErrNoProductFound
+	if unitNull.Valid {
+		unitNull.String = *unit
 	}
-
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database UpdateProduct() -> tx.Commit()", err)
-		return err
+	if packSizeNull.Valid {
+		packSizeNull.Int64 = *packSize
+	}
+	if trackLotsNull.Valid {
+		trackLotsNull.Bool = *trackLots
+	}
+	if trackSerialsNull.Valid {
+		trackSerialsNull.Bool = *trackSerials
+	}
+	if allowBackordersNull.Valid {
+		allowBackordersNull.Bool = *allowBackorders
 	}
-	committed = true
 
-	return nil
+	err = db.WithTx(func(tx *sql.Tx) error {
+		// Setting the quantity directly is a stock count; the difference to
+		// the current quantity is booked in the counted warehouse and goes to
+		// the ledger as an adjustment.
+		// Units of a product tracked by serial number each need their own
+		// serial, so its stock cannot be counted here and tracking cannot be
+		// turned on while units without a serial are in stock.
+		var previous int64
+		if quantityNull.Valid || trackSerialsNull.Bool {
+			var tracked bool
+			if err := tx.QueryRow(string(lockQuery), productID).Scan(&previous, &tracked); errors.Is(err, sql.ErrNoRows) {
+				return ErrNoProductFound
+			} else if err != nil {
+				return err
+			}
+			if quantityNull.Valid && (tracked || trackSerialsNull.Bool) {
+				return errSerialStockCount(productID)
+			}
+			if !tracked && trackSerialsNull.Bool && previous > 0 {
+				return errSerialTrackingWithStock(productID)
+			}
+		}
+
+		result, err := tx.Exec(string(query), productID, nameNull, supplierIDNull, descriptionNull, priceNull, quantityNull, categoryIDNull,
+			skuNull, barcodeNull, unitNull, packSizeNull, trackLotsNull, trackSerialsNull, allowBackordersNull)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, ErrNoProductFound); err != nil {
+			return err
+		}
+		if priceNull.Valid {
+			if _, err := tx.Exec(string(priceQuery), productID); err != nil {
+				return err
+			}
+		}
+		if !quantityNull.Valid {
+			return nil
+		}
+
+		warehouse, err := db.countWarehouse(tx, productID, warehouseID)
+		if err != nil {
+			return err
+		}
+		if previous > quantityNull.Int64 {
+			if err := db.requireUnreserved(tx, productID, warehouse, previous-quantityNull.Int64); err != nil {
+				return err
+			}
+			if _, err := db.takeLots(tx, productID, warehouse, previous-quantityNull.Int64, false); err != nil {
+				return err
+			}
+			if err := db.takeBins(tx, productID, warehouse, previous-quantityNull.Int64); err != nil {
+				return err
+			}
+		}
+		return db.placeStock(tx, StockMovement{
+			ProductID:      productID,
+			WarehouseID:    warehouse,
+			QuantityChange: quantityNull.Int64 - previous,
+			Type:           MovementAdjustment,
+			Reason:         "stock count",
+			ReferenceType:  "product",
+			ReferenceID:    &productID,
+			CreatedBy:      user,
+		})
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database UpdateProduct() -> tx.Exec()", slog.String("error", err.Error()))
+	}
+	return err
 }
 
 func (db *Database) IDProduct(name string) (bool, error) {
@@ -186,33 +327,46 @@ func (db *Database) IDProduct(name string) (bool, error) {
 
 	query, err := os.ReadFile(productsPath + "id_products.sql")
 	if err != nil {
-		db.Log.Error("Database IDProduct() -> Read SQL file", err)
+		db.Log.Error("Database IDProduct() -> Read SQL file", slog.String("error", err.Error()))
 		return false, err
 	}
 
 	err = db.QueryRow(string(query), name).Scan(&exists)
 	if err != nil {
-		db.Log.Error("error checking if product exists:", err)
+		db.Log.Error("error checking if product exists:", slog.String("error", err.Error()))
 		return false, err
 	}
 	return exists, nil
 }
 
-func (db *Database) PurchaseRequestProducts(maxQuantity int64) ([]PurchaseRequest, error) {
+// PurchaseRequestProducts lists the products with less than maxQuantity in
+// stock, or in the stock of one warehouse if warehouseID is given. Without
+// maxQuantity it lists the products at or below their own reorder point.
+func (db *Database) PurchaseRequestProducts(maxQuantity, warehouseID *int64) ([]PurchaseRequest, error) {
 	query, err := os.ReadFile(productsPath + "purchase_request_products.sql")
 	if err != nil {
-		db.Log.Error("Database PurchaseRequestProducts() -> Read SQL file", err)
+		db.Log.Error("Database PurchaseRequestProducts() -> Read SQL file", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	rows, err := db.Query(string(query), maxQuantity)
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
+	}
+
+	maxQuantityNull := sql.NullInt64{Valid: maxQuantity != nil}
+	if maxQuantity != nil {
+		maxQuantityNull.Int64 = *maxQuantity
+	}
+
+	rows, err := db.Query(string(query), maxQuantityNull, warehouseNull)
 	if err != nil {
-		db.Log.Error("Database PurchaseRequestProducts() -> tx.Query()", err)
+		db.Log.Error("Database PurchaseRequestProducts() -> tx.Query()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -220,22 +374,52 @@ func (db *Database) PurchaseRequestProducts(maxQuantity int64) ([]PurchaseReques
 	for rows.Next() {
 		var p PurchaseRequest
 		if err := rows.Scan(&p.ProductID, &p.Name, &p.SupplierID, &p.ContactEmail); err != nil {
-			db.Log.Error("Database CheckProducts() -> parsing rows", err)
+			db.Log.Error("Database CheckProducts() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
 		products = append(products, p)
 	}
 	if err = rows.Err(); err != nil {
-		db.Log.Error("Database CheckProducts() -> rows.Err()", err)
+		db.Log.Error("Database CheckProducts() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return products, nil
 }
 
+// scanProduct reads the standard product column list into p, followed by any
+// extra columns the query selects after it.
+func scanProduct(rows *sql.Rows, p *Product, extra ...any) error {
+	var categoryID, reorderPoint, reorderQuantity sql.NullInt64
+	var category, sku, barcode sql.NullString
+	var deletedAt sql.NullTime
+	dest := []any{&p.ProductID, &p.SupplierID, &p.Name, &p.Description, &p.Price, &p.Quantity, &categoryID, &category,
+		&sku, &barcode, &p.Unit, &p.PackSize, &p.TrackLots, &p.TrackSerials, &p.AllowBackorders, &reorderPoint, &reorderQuantity, &p.SafetyStock,
+		&p.CreatedAt, &p.UpdatedAt, &deletedAt}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if categoryID.Valid {
+		p.CategoryID = &categoryID.Int64
+	}
+	if reorderPoint.Valid {
+		p.ReorderPoint = &reorderPoint.Int64
+	}
+	if reorderQuantity.Valid {
+		p.ReorderQuantity = &reorderQuantity.Int64
+	}
+	p.Category = category.String
+	p.SKU = sku.String
+	p.Barcode = barcode.String
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+	return nil
+}
+
 func (db *Database) readRowsProduct(rows *sql.Rows) ([]Product, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -243,113 +427,262 @@ func (db *Database) readRowsProduct(rows *sql.Rows) ([]Product, error) {
 
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.ProductID, &p.SupplierID, &p.Name, &p.Description, &p.Price, &p.Quantity, &p.Category, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			db.Log.Error("Database readRowsProduct() -> parsing rows", err)
+		if err := scanProduct(rows, &p); err != nil {
+			db.Log.Error("Database readRowsProduct() -> parsing rows", slog.String("error", err.Error()))
 			return nil, err
 		}
 		products = append(products, p)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsProduct() -> rows.Err()", err)
+		db.Log.Error("Database readRowsProduct() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return products, nil
 }
 
-func (db *Database) ShowNotEmptyQuantityProducts(limit *int) ([]Product, error) {
+// ShowNotEmptyQuantityProducts lists the products in stock. With warehouseID
+// only the stock of that warehouse counts and is returned as the quantity.
+// Available is the quantity on hand less the active reservations.
+func (db *Database) ShowNotEmptyQuantityProducts(page Page, includeDeleted bool, warehouseID *int64) ([]Product, string, error) {
 	query, err := os.ReadFile(productsPath + "show_by_quantity_products.sql")
 	if err != nil {
-		db.Log.Error("Database ShowNotEmptyQuantityProducts() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowNotEmptyQuantityProducts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
 	}
 
-	rows, err := db.Query(string(query), limitValue)
+	paged, args, err := productList.paginate(string(query), []any{includeDeleted, warehouseNull}, page)
 	if err != nil {
-		db.Log.Error("Database ShowNotEmptyQuantityProducts() -> tx.Query()", err)
-		return nil, err
+		return nil, "", err
+	}
+
+	rows, err := db.Query(paged, args...)
+	if err != nil {
+		db.Log.Error("Database ShowNotEmptyQuantityProducts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var items []Product
+	for rows.Next() {
+		var p Product
+		var available int64
+		if err := scanProduct(rows, &p, &available); err != nil {
+			db.Log.Error("Database ShowNotEmptyQuantityProducts() -> parsing rows", slog.String("error", err.Error()))
+			return nil, "", err
+		}
+		p.Available = &available
+		items = append(items, p)
+	}
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowNotEmptyQuantityProducts() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	return db.readRowsProduct(rows)
+	items, next := productList.nextPage(items, page)
+	return items, next, nil
 }
 
-func (db *Database) ShowByCategoryProducts(category string, limit *int) ([]Product, error) {
+// ShowByCategoryProducts returns the products of a category given either by
+// id or, when categoryID is nil, by case-insensitive name. With descendants
+// set, products of every subcategory are included as well.
+func (db *Database) ShowByCategoryProducts(categoryID *int64, category string, descendants bool, page Page, includeDeleted bool) ([]Product, string, error) {
 	query, err := os.ReadFile(productsPath + "show_by_category_products.sql")
 	if err != nil {
-		db.Log.Error("Database ShowByCategoryProducts() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowByCategoryProducts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	categoryIDValue := sql.NullInt64{Valid: categoryID != nil}
+	if categoryID != nil {
+		categoryIDValue.Int64 = *categoryID
 	}
 
-	rows, err := db.Query(string(query), category, limitValue)
+	paged, args, err := productList.paginate(string(query), []any{categoryIDValue, category, descendants, includeDeleted}, page)
 	if err != nil {
-		db.Log.Error("Database ShowByCategoryProducts() -> tx.Query()", err)
-		return nil, err
+		return nil, "", err
+	}
+
+	rows, err := db.Query(paged, args...)
+	if err != nil {
+		db.Log.Error("Database ShowByCategoryProducts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	return db.readRowsProduct(rows)
+	items, err := db.readRowsProduct(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items, next := productList.nextPage(items, page)
+	return items, next, nil
 }
 
-func (db *Database) ShowBetweenPriceProducts(min, max int64, limit *int) ([]Product, error) {
+func (db *Database) ShowBetweenPriceProducts(min, max int64, page Page, includeDeleted bool) ([]Product, string, error) {
 	query, err := os.ReadFile(productsPath + "show_price_products.sql")
 	if err != nil {
-		db.Log.Error("Database ShowBetweenPriceProducts() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowBetweenPriceProducts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	limitValue := sql.NullInt64{Valid: limit != nil}
-	if limit != nil {
-		limitValue.Int64 = int64(*limit)
+	paged, args, err := productList.paginate(string(query), []any{min, max, includeDeleted}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), min, max, limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowBetweenPriceProducts() -> tx.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowBetweenPriceProducts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	return db.readRowsProduct(rows)
+	items, err := db.readRowsProduct(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items, next := productList.nextPage(items, page)
+	return items, next, nil
 }
 
-func (db *Database) ShowProducts(limit *int) ([]Product, error) {
+func (db *Database) ShowProducts(page Page, includeDeleted bool) ([]Product, string, error) {
 	query, err := os.ReadFile(productsPath + "show_products.sql")
 	if err != nil {
-		db.Log.Error("Database ShowProducts() -> Read SQL file", err)
+		db.Log.Error("Database ShowProducts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	paged, args, err := productList.paginate(string(query), []any{includeDeleted}, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.Query(paged, args...)
+	if err != nil {
+		db.Log.Error("Database ShowProducts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsProduct(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items, next := productList.nextPage(items, page)
+	return items, next, nil
+}
+
+// SearchProducts combines full-text search over name, SKU, description and
+// category with trigram similarity, so misspelled queries still find
+// products. Results are ordered by relevance; nil filters are not applied.
+// With inStock and warehouseID only products in stock in that warehouse
+// match.
+func (db *Database) SearchProducts(search string, minPrice, maxPrice *float64, inStock bool, supplierID, warehouseID *int64, limit *int) ([]ProductSearchResult, error) {
+	query, err := os.ReadFile(productsPath + "search_products.sql")
+	if err != nil {
+		db.Log.Error("Database SearchProducts() -> Read SQL file", slog.String("error", err.Error()))
 		return nil, err
 	}
 
+	minPriceValue := sql.NullFloat64{Valid: minPrice != nil}
+	if minPrice != nil {
+		minPriceValue.Float64 = *minPrice
+	}
+	maxPriceValue := sql.NullFloat64{Valid: maxPrice != nil}
+	if maxPrice != nil {
+		maxPriceValue.Float64 = *maxPrice
+	}
+	supplierIDValue := sql.NullInt64{Valid: supplierID != nil}
+	if supplierID != nil {
+		supplierIDValue.Int64 = *supplierID
+	}
 	limitValue := sql.NullInt64{Valid: limit != nil}
 	if limit != nil {
 		limitValue.Int64 = int64(*limit)
 	}
+	warehouseIDValue := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseIDValue.Int64 = *warehouseID
+	}
 
-	rows, err := db.Query(string(query), limitValue)
+	rows, err := db.Query(string(query), search, minPriceValue, maxPriceValue, inStock, supplierIDValue, limitValue, warehouseIDValue)
 	if err != nil {
-		db.Log.Error("Database ShowProducts() -> db.Query()", err)
+		db.Log.Error("Database SearchProducts() -> db.Query()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			db.Log.Error("Some troubles after rows.Close()", err)
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
 		}
 	}()
 
-	return db.readRowsProduct(rows)
+	var results []ProductSearchResult
+	for rows.Next() {
+		var r ProductSearchResult
+		if err := scanProduct(rows, &r.Product, &r.Rank, &r.NameHighlight, &r.DescriptionHighlight); err != nil {
+			db.Log.Error("Database SearchProducts() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database SearchProducts() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return results, nil
+}
+
+// ProductBySKU returns the active product with the given stock keeping unit.
+func (db *Database) ProductBySKU(sku string) (Product, error) {
+	return db.findProduct("ProductBySKU", "sku_products.sql", sku, ErrNoProductSKU)
+}
+
+// ProductByBarcode returns the active product with the given EAN/UPC code.
+// Codes are compared as GTIN-14, so a UPC-A scanned as EAN-13 (with a leading
+// zero) still matches.
+func (db *Database) ProductByBarcode(barcode string) (Product, error) {
+	return db.findProduct("ProductByBarcode", "barcode_products.sql", barcode, ErrNoProductBarcode)
+}
+
+func (db *Database) findProduct(caller, file, value string, notFound error) (Product, error) {
+	query, err := os.ReadFile(productsPath + file)
+	if err != nil {
+		db.Log.Error("Database "+caller+"() -> Read SQL file", slog.String("error", err.Error()))
+		return Product{}, err
+	}
+
+	rows, err := db.Query(string(query), value)
+	if err != nil {
+		db.Log.Error("Database "+caller+"() -> db.Query()", slog.String("error", err.Error()))
+		return Product{}, err
+	}
+
+	products, err := db.readRowsProduct(rows)
+	if err != nil {
+		return Product{}, err
+	}
+	if len(products) == 0 {
+		return Product{}, notFound
+	}
+	return products[0], nil
 }
 
+// CheckProductAvailability reports whether quantity units of a product are
+// available: on hand and not held by an active reservation.
 func (db *Database) CheckProductAvailability(productID, quantity int64) (bool, error) {
 	query, err := os.ReadFile(productsPath + "check_product_availability.sql")
 	if err != nil {
-		db.Log.Error("Database CheckProductAvailability() -> Read SQL file", err)
+		db.Log.Error("Database CheckProductAvailability() -> Read SQL file", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -357,10 +690,10 @@ func (db *Database) CheckProductAvailability(productID, quantity int64) (bool, e
 	err = db.QueryRow(string(query), productID).Scan(&currentQuantity)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			db.Log.Error("Database CheckProductAvailability() -> No product found with given ID", err)
+			db.Log.Error("Database CheckProductAvailability() -> No product found with given ID", slog.String("error", err.Error()))
 			return false, nil
 		}
-		db.Log.Error("Database CheckProductAvailability() -> QueryRow()", err)
+		db.Log.Error("Database CheckProductAvailability() -> QueryRow()", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -370,14 +703,14 @@ func (db *Database) CheckProductAvailability(productID, quantity int64) (bool, e
 func (db *Database) CheckProductExists(productID int64) (bool, error) {
 	query, err := os.ReadFile(productsPath + "check_product_exists.sql")
 	if err != nil {
-		db.Log.Error("Database CheckProductExists() -> Read SQL file", err)
+		db.Log.Error("Database CheckProductExists() -> Read SQL file", slog.String("error", err.Error()))
 		return false, err
 	}
 
 	var exists bool
 	err = db.QueryRow(string(query), productID).Scan(&exists)
 	if err != nil {
-		db.Log.Error("Database CheckProductExists() -> Error checking if product exists", err)
+		db.Log.Error("Database CheckProductExists() -> Error checking if product exists", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -0,0 +1,409 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderCancelled         = "cancelled"
+)
+
+// purchaseOrderTransitions lists the statuses a purchase order may be moved
+// to by hand and the statuses it may come from. The received statuses are
+// set by goods receiving only.
+var purchaseOrderTransitions = map[string][]string{
+	PurchaseOrderSent:      {PurchaseOrderDraft},
+	PurchaseOrderCancelled: {PurchaseOrderDraft, PurchaseOrderSent},
+}
+
+type PurchaseOrder struct {
+	PurchaseOrderID     int64               `json:"purchase_order_id"`
+	SupplierID          int64               `json:"supplier_id"`
+	SupplierName        string              `json:"supplier_name"`
+	SupplierContactName string              `json:"supplier_contact_name"`
+	SupplierEmail       string              `json:"supplier_email"`
+	WarehouseID         int64               `json:"warehouse_id"`
+	WarehouseName       string              `json:"warehouse_name"`
+	WarehouseAddress    string              `json:"warehouse_address"`
+	Status              string              `json:"status"`
+	Notes               string              `json:"notes"`
+	ExpectedAt          *time.Time          `json:"expected_at"`
+	SentAt              *time.Time          `json:"sent_at"`
+	TotalCost           float64             `json:"total_cost"`
+	CreatedAt           time.Time           `json:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+	Lines               []PurchaseOrderLine `json:"lines,omitempty"`
+}
+
+// PurchaseOrderLine is one product of a purchase order. A nil UnitCost when
+// creating a line means the cost price from the supplier catalog.
+type PurchaseOrderLine struct {
+	PurchaseOrderLineID int64    `json:"purchase_order_line_id"`
+	ProductID           int64    `json:"product_id"`
+	ProductName         string   `json:"product_name"`
+	SKU                 string   `json:"sku,omitempty"`
+	SupplierSKU         string   `json:"supplier_sku,omitempty"`
+	Quantity            int64    `json:"quantity"`
+	ReceivedQuantity    int64    `json:"received_quantity"`
+	UnitCost            *float64 `json:"unit_cost"`
+	LineTotal           float64  `json:"line_total"`
+}
+
+// PurchaseOrdersFromRequests is the outcome of CreatePurchaseOrdersFromRequests.
+// Skipped holds the low-stock products that could not be ordered because
+// their primary supplier has no cost price for them in the catalog.
+type PurchaseOrdersFromRequests struct {
+	PurchaseOrderIDs []int64 `json:"purchase_order_ids"`
+	Skipped          []int64 `json:"skipped_product_ids"`
+}
+
+var purchaseOrderList = listSpec[PurchaseOrder]{
+	id:      "po.purchase_order_id",
+	idValue: func(po PurchaseOrder) int64 { return po.PurchaseOrderID },
+	def:     "id",
+	keys: map[string]sortKey[PurchaseOrder]{
+		"id":          {column: "po.purchase_order_id", cast: "BIGINT", value: func(po PurchaseOrder) string { return intValue(po.PurchaseOrderID) }},
+		"supplier_id": {column: "po.supplier_id", cast: "BIGINT", value: func(po PurchaseOrder) string { return intValue(po.SupplierID) }},
+		"status":      {column: "po.status", cast: "TEXT", value: func(po PurchaseOrder) string { return po.Status }},
+		"created_at":  {column: "po.created_at", cast: "TIMESTAMP", value: func(po PurchaseOrder) string { return timeValue(po.CreatedAt) }},
+		"updated_at":  {column: "po.updated_at", cast: "TIMESTAMP", value: func(po PurchaseOrder) string { return timeValue(po.UpdatedAt) }},
+	},
+	filters: map[string]filterField{
+		"id":           {column: "po.purchase_order_id", kind: numberField},
+		"supplier_id":  {column: "po.supplier_id", kind: numberField},
+		"supplier":     {column: "s.name", kind: textField},
+		"warehouse_id": {column: "po.warehouse_id", kind: numberField},
+		"status":       {column: "po.status", kind: textField},
+		"expected_at":  {column: "po.expected_at", kind: timeField},
+		"sent_at":      {column: "po.sent_at", kind: timeField},
+		"created_at":   {column: "po.created_at", kind: timeField},
+		"updated_at":   {column: "po.updated_at", kind: timeField},
+	},
+}
+
+var ErrNoPurchaseOrderFound error = &Error{Kind: ErrNotFound, Message: "no purchase order found with the provided ID"}
+var ErrPurchaseOrderStatus error = &Error{Kind: ErrConflict, Message: "the purchase order cannot be moved to this status from its current one"}
+
+func errNoCostPrice(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("no unit cost given and the supplier catalog has no cost price for product %d", productID)}
+}
+
+// CreatePurchaseOrder creates a draft purchase order with its lines in one
+// transaction. The goods are delivered to warehouseID, or to the default
+// warehouse if it is nil.
+func (db *Database) CreatePurchaseOrder(supplierID int64, warehouseID *int64, notes string, expectedAt *time.Time, lines []PurchaseOrderLine) (int64, error) {
+	query, err := os.ReadFile(purchaseOrdersPath + "add_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	lineQuery, err := os.ReadFile(purchaseOrdersPath + "add_purchase_order_lines.sql")
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var purchaseOrderID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		warehouse, err := db.resolveWarehouse(tx, warehouseID)
+		if err != nil {
+			return err
+		}
+		purchaseOrderID, err = db.insertPurchaseOrder(tx, string(query), string(lineQuery), supplierID, warehouse, notes, expectedAt, lines)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrNotFound) {
+		db.Log.Error("Database CreatePurchaseOrder()", slog.String("error", err.Error()))
+	}
+	return purchaseOrderID, err
+}
+
+func (db *Database) insertPurchaseOrder(tx *sql.Tx, query, lineQuery string, supplierID, warehouseID int64, notes string, expectedAt *time.Time, lines []PurchaseOrderLine) (int64, error) {
+	expectedNull := sql.NullTime{Valid: expectedAt != nil}
+	if expectedAt != nil {
+		expectedNull.Time = *expectedAt
+	}
+
+	var purchaseOrderID int64
+	if err := tx.QueryRow(query, supplierID, warehouseID, notes, expectedNull).Scan(&purchaseOrderID); err != nil {
+		return 0, err
+	}
+
+	for _, line := range lines {
+		costNull := sql.NullFloat64{Valid: line.UnitCost != nil}
+		if line.UnitCost != nil {
+			costNull.Float64 = *line.UnitCost
+		}
+
+		var lineID int64
+		err := tx.QueryRow(lineQuery, purchaseOrderID, line.ProductID, line.Quantity, costNull).Scan(&lineID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errNoCostPrice(line.ProductID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return purchaseOrderID, nil
+}
+
+// CreatePurchaseOrdersFromRequests turns the purchase-request list into draft
+// purchase orders, one per primary supplier. Every product below maxQuantity
+// is ordered up to targetQuantity, but at least the supplier's minimum order
+// quantity. Products already on an open purchase order are left out, so
+// calling it twice does not order twice. With warehouseID only the stock of
+// that warehouse is considered and the goods are delivered there; otherwise
+// the total stock counts and the goods go to the default warehouse.
+func (db *Database) CreatePurchaseOrdersFromRequests(maxQuantity, targetQuantity int64, warehouseID *int64) (PurchaseOrdersFromRequests, error) {
+	var result PurchaseOrdersFromRequests
+
+	requestQuery, err := os.ReadFile(purchaseOrdersPath + "purchase_request_lines.sql")
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrdersFromRequests() -> Read SQL file", slog.String("error", err.Error()))
+		return result, err
+	}
+
+	query, err := os.ReadFile(purchaseOrdersPath + "add_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrdersFromRequests() -> Read SQL file", slog.String("error", err.Error()))
+		return result, err
+	}
+
+	lineQuery, err := os.ReadFile(purchaseOrdersPath + "add_purchase_order_lines.sql")
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrdersFromRequests() -> Read SQL file", slog.String("error", err.Error()))
+		return result, err
+	}
+
+	err = db.WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		result = PurchaseOrdersFromRequests{}
+
+		warehouse, err := db.resolveWarehouse(tx, warehouseID)
+		if err != nil {
+			return err
+		}
+
+		warehouseNull := sql.NullInt64{Valid: warehouseID != nil, Int64: warehouse}
+		rows, err := tx.Query(string(requestQuery), maxQuantity, targetQuantity, warehouseNull)
+		if err != nil {
+			return err
+		}
+
+		var suppliers []int64
+		bySupplier := make(map[int64][]PurchaseOrderLine)
+		for rows.Next() {
+			var line PurchaseOrderLine
+			var supplierID int64
+			var cost sql.NullFloat64
+			if err := rows.Scan(&line.ProductID, &supplierID, &line.Quantity, &cost); err != nil {
+				rows.Close()
+				return err
+			}
+			if !cost.Valid {
+				result.Skipped = append(result.Skipped, line.ProductID)
+				continue
+			}
+			line.UnitCost = &cost.Float64
+			if _, ok := bySupplier[supplierID]; !ok {
+				suppliers = append(suppliers, supplierID)
+			}
+			bySupplier[supplierID] = append(bySupplier[supplierID], line)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, supplierID := range suppliers {
+			id, err := db.insertPurchaseOrder(tx, string(query), string(lineQuery), supplierID, warehouse, "", nil, bySupplier[supplierID])
+			if err != nil {
+				return err
+			}
+			result.PurchaseOrderIDs = append(result.PurchaseOrderIDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Log.Error("Database CreatePurchaseOrdersFromRequests()", slog.String("error", err.Error()))
+		return PurchaseOrdersFromRequests{}, err
+	}
+
+	return result, nil
+}
+
+// UpdatePurchaseOrderStatus sends or cancels a purchase order. Moves that
+// purchaseOrderTransitions does not allow return ErrPurchaseOrderStatus.
+func (db *Database) UpdatePurchaseOrderStatus(purchaseOrderID int64, status string) error {
+	query, err := os.ReadFile(purchaseOrdersPath + "status_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database UpdatePurchaseOrderStatus() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	statusQuery, err := os.ReadFile(purchaseOrdersPath + "status_by_id_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database UpdatePurchaseOrderStatus() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	from, ok := purchaseOrderTransitions[status]
+	if !ok {
+		return ErrPurchaseOrderStatus
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), purchaseOrderID, status, pq.Array(from))
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+			return err
+		}
+
+		var current string
+		if err := tx.QueryRow(string(statusQuery), purchaseOrderID).Scan(&current); errors.Is(err, sql.ErrNoRows) {
+			return ErrNoPurchaseOrderFound
+		} else if err != nil {
+			return err
+		}
+		return ErrPurchaseOrderStatus
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database UpdatePurchaseOrderStatus() -> tx.Exec()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+func (db *Database) readRowsPurchaseOrder(rows *sql.Rows) ([]PurchaseOrder, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var orders []PurchaseOrder
+	for rows.Next() {
+		var po PurchaseOrder
+		var expectedAt, sentAt sql.NullTime
+		if err := rows.Scan(&po.PurchaseOrderID, &po.SupplierID, &po.SupplierName, &po.SupplierContactName, &po.SupplierEmail,
+			&po.WarehouseID, &po.WarehouseName, &po.WarehouseAddress, &po.Status, &po.Notes, &expectedAt, &sentAt, &po.TotalCost, &po.CreatedAt, &po.UpdatedAt); err != nil {
+			db.Log.Error("Database readRowsPurchaseOrder() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if expectedAt.Valid {
+			po.ExpectedAt = &expectedAt.Time
+		}
+		if sentAt.Valid {
+			po.SentAt = &sentAt.Time
+		}
+		orders = append(orders, po)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database readRowsPurchaseOrder() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ShowPurchaseOrders lists purchase orders without their lines.
+func (db *Database) ShowPurchaseOrders(page Page) ([]PurchaseOrder, string, error) {
+	query, err := os.ReadFile(purchaseOrdersPath + "show_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database ShowPurchaseOrders() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	paged, args, err := purchaseOrderList.paginate(string(query), []any{nil}, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.Query(paged, args...)
+	if err != nil {
+		db.Log.Error("Database ShowPurchaseOrders() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	orders, err := db.readRowsPurchaseOrder(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	orders, next := purchaseOrderList.nextPage(orders, page)
+	return orders, next, nil
+}
+
+// GetPurchaseOrder returns one purchase order with all of its lines.
+func (db *Database) GetPurchaseOrder(purchaseOrderID int64) (PurchaseOrder, error) {
+	query, err := os.ReadFile(purchaseOrdersPath + "show_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database GetPurchaseOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return PurchaseOrder{}, err
+	}
+
+	lineQuery, err := os.ReadFile(purchaseOrdersPath + "lines_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database GetPurchaseOrder() -> Read SQL file", slog.String("error", err.Error()))
+		return PurchaseOrder{}, err
+	}
+
+	rows, err := db.Query(string(query), purchaseOrderID)
+	if err != nil {
+		db.Log.Error("Database GetPurchaseOrder() -> db.Query()", slog.String("error", err.Error()))
+		return PurchaseOrder{}, err
+	}
+
+	orders, err := db.readRowsPurchaseOrder(rows)
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	if len(orders) == 0 {
+		return PurchaseOrder{}, ErrNoPurchaseOrderFound
+	}
+	po := orders[0]
+
+	lineRows, err := db.Query(string(lineQuery), purchaseOrderID)
+	if err != nil {
+		db.Log.Error("Database GetPurchaseOrder() -> db.Query() lines", slog.String("error", err.Error()))
+		return PurchaseOrder{}, err
+	}
+	defer func() {
+		if err := lineRows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	for lineRows.Next() {
+		var line PurchaseOrderLine
+		var sku, supplierSKU sql.NullString
+		var unitCost float64
+		if err := lineRows.Scan(&line.PurchaseOrderLineID, &line.ProductID, &line.ProductName, &sku, &supplierSKU,
+			&line.Quantity, &line.ReceivedQuantity, &unitCost, &line.LineTotal); err != nil {
+			db.Log.Error("Database GetPurchaseOrder() -> parsing rows", slog.String("error", err.Error()))
+			return PurchaseOrder{}, err
+		}
+		line.SKU = sku.String
+		line.SupplierSKU = supplierSKU.String
+		line.UnitCost = &unitCost
+		po.Lines = append(po.Lines, line)
+	}
+
+	if err := lineRows.Err(); err != nil {
+		db.Log.Error("Database GetPurchaseOrder() -> rows.Err()", slog.String("error", err.Error()))
+		return PurchaseOrder{}, err
+	}
+	return po, nil
+}
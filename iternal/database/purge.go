@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"time"
+)
+
+type PurgeResult struct {
+	Products  int64 `json:"products"`
+	Suppliers int64 `json:"suppliers"`
+	Customers int64 `json:"customers"`
+}
+
+// PurgeDeleted hard-deletes rows soft-deleted before the given moment.
+// Rows still referenced by orders or products are kept, so the history they
+// belong to stays intact. Products go first because they reference suppliers.
+func (db *Database) PurgeDeleted(before time.Time) (PurgeResult, error) {
+	var result PurgeResult
+
+	steps := []struct {
+		path  string
+		count *int64
+	}{
+		{productsPath + "purge_products.sql", &result.Products},
+		{suppliersPath + "purge_suppliers.sql", &result.Suppliers},
+		{customersPath + "purge_customers.sql", &result.Customers},
+	}
+
+	queries := make([]string, len(steps))
+	for i, step := range steps {
+		query, err := os.ReadFile(step.path)
+		if err != nil {
+			db.Log.Error("Database PurgeDeleted() -> Read SQL file", slog.String("error", err.Error()))
+			return result, err
+		}
+		queries[i] = string(query)
+	}
+
+	err := db.WithTx(func(tx *sql.Tx) error {
+		for i, step := range steps {
+			res, err := tx.Exec(queries[i], before)
+			if err != nil {
+				return err
+			}
+			if *step.count, err = res.RowsAffected(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Log.Error("Database PurgeDeleted()", slog.String("error", err.Error()))
+		return PurgeResult{}, err
+	}
+
+	return result, nil
+}
+
+// RunPurge calls PurgeDeleted every PurgeInterval for rows deleted longer
+// than DeletedRetention ago, until the context is cancelled.
+func (db *Database) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(db.cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !db.IsReady() {
+			continue
+		}
+
+		result, err := db.PurgeDeleted(time.Now().Add(-db.cfg.DeletedRetention))
+		if err != nil {
+			continue
+		}
+		db.Log.Info("purged soft-deleted rows",
+			slog.Int64("products", result.Products),
+			slog.Int64("suppliers", result.Suppliers),
+			slog.Int64("customers", result.Customers))
+	}
+}
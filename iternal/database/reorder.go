@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"os"
+)
+
+// PurchaseSuggestion proposes ordering Quantity units of a product from its
+// primary supplier. Position is the stock available to new orders plus the
+// units on open purchase orders, less the units backordered; the product is
+// suggested once it is at or below ReorderPoint. UnitCost is the supplier's
+// cost price, or nil if the supplier catalog has none.
+type PurchaseSuggestion struct {
+	ProductID    int64    `json:"product_id"`
+	ProductName  string   `json:"product_name"`
+	Available    int64    `json:"available"`
+	OnOrder      int64    `json:"on_order"`
+	Backordered  int64    `json:"backordered"`
+	Position     int64    `json:"position"`
+	AvgDailySold float64  `json:"avg_daily_sold"`
+	LeadTimeDays int64    `json:"lead_time_days"`
+	SafetyStock  int64    `json:"safety_stock"`
+	ReorderPoint int64    `json:"reorder_point"`
+	Quantity     int64    `json:"quantity"`
+	UnitCost     *float64 `json:"unit_cost"`
+}
+
+// SupplierSuggestions are the purchase suggestions of one supplier.
+// TotalCost sums the lines with a known unit cost.
+type SupplierSuggestions struct {
+	SupplierID   int64                `json:"supplier_id"`
+	SupplierName string               `json:"supplier_name"`
+	ContactEmail string               `json:"contact_email"`
+	TotalCost    float64              `json:"total_cost"`
+	Lines        []PurchaseSuggestion `json:"lines"`
+}
+
+// SetReorderPolicy replaces the reorder settings of a product. A nil
+// reorderPoint or reorderQuantity lets PurchaseSuggestions derive it from the
+// product's sales.
+func (db *Database) SetReorderPolicy(productID int64, reorderPoint, reorderQuantity *int64, safetyStock int64) error {
+	query, err := os.ReadFile(productsPath + "reorder_products.sql")
+	if err != nil {
+		db.Log.Error("Database SetReorderPolicy() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	pointNull := sql.NullInt64{Valid: reorderPoint != nil}
+	if reorderPoint != nil {
+		pointNull.Int64 = *reorderPoint
+	}
+	quantityNull := sql.NullInt64{Valid: reorderQuantity != nil}
+	if reorderQuantity != nil {
+		quantityNull.Int64 = *reorderQuantity
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), productID, pointNull, quantityNull, safetyStock)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoProductFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoProductFound) {
+		db.Log.Error("Database SetReorderPolicy()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// PurchaseSuggestions proposes what to order from each supplier, using the
+// average daily sales of the last days days. A product's reorder point is its
+// own, or the sales expected over the supplier's lead time plus the safety
+// stock. Products at or below it are ordered by their own reorder quantity,
+// or up to the reorder point plus coverDays days of sales, but at least the
+// supplier's minimum order quantity. Suppliers are returned by ID.
+func (db *Database) PurchaseSuggestions(days, coverDays int64) ([]SupplierSuggestions, error) {
+	query, err := os.ReadFile(purchaseOrdersPath + "suggestions_purchase_orders.sql")
+	if err != nil {
+		db.Log.Error("Database PurchaseSuggestions() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	averages, err := db.FetchRequirementsReport(days)
+	if err != nil {
+		return nil, err
+	}
+	dailySold := make(map[int64]float64, len(averages))
+	for _, a := range averages {
+		dailySold[a.ProductID] = a.AvgDailySold
+	}
+
+	rows, err := db.Query(string(query))
+	if err != nil {
+		db.Log.Error("Database PurchaseSuggestions() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var suggestions []SupplierSuggestions
+	for rows.Next() {
+		var line PurchaseSuggestion
+		var supplier SupplierSuggestions
+		var reorderPoint, reorderQuantity sql.NullInt64
+		var minOrderQuantity int64
+		var cost sql.NullFloat64
+		if err := rows.Scan(&line.ProductID, &line.ProductName, &supplier.SupplierID, &supplier.SupplierName, &supplier.ContactEmail,
+			&line.Available, &line.OnOrder, &line.Backordered, &reorderPoint, &reorderQuantity, &line.SafetyStock,
+			&line.LeadTimeDays, &minOrderQuantity, &cost); err != nil {
+			db.Log.Error("Database PurchaseSuggestions() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+
+		line.AvgDailySold = dailySold[line.ProductID]
+		line.Position = line.Available + line.OnOrder - line.Backordered
+		line.ReorderPoint = reorderPoint.Int64
+		if !reorderPoint.Valid {
+			line.ReorderPoint = int64(math.Ceil(line.AvgDailySold*float64(line.LeadTimeDays))) + line.SafetyStock
+		}
+		if line.Position > line.ReorderPoint {
+			continue
+		}
+
+		line.Quantity = reorderQuantity.Int64
+		if !reorderQuantity.Valid {
+			line.Quantity = line.ReorderPoint + int64(math.Ceil(line.AvgDailySold*float64(coverDays))) - line.Position
+		}
+		if line.Quantity <= 0 {
+			continue
+		}
+		line.Quantity = max(line.Quantity, minOrderQuantity)
+		if cost.Valid {
+			line.UnitCost = &cost.Float64
+		}
+
+		if n := len(suggestions); n == 0 || suggestions[n-1].SupplierID != supplier.SupplierID {
+			suggestions = append(suggestions, supplier)
+		}
+		last := &suggestions[len(suggestions)-1]
+		last.Lines = append(last.Lines, line)
+		if line.UnitCost != nil {
+			last.TotalCost += *line.UnitCost * float64(line.Quantity)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database PurchaseSuggestions() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return suggestions, nil
+}
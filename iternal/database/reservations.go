@@ -0,0 +1,338 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	ReservationActive    = "active"
+	ReservationConverted = "converted"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation sets aside quantity units of a product in one warehouse, for
+// a shopping cart or a quote, until ExpiresAt. Reserved units stay on hand
+// but are not available to other orders. Price, if set, is the price the
+// order is placed at when the reservation is converted; otherwise the
+// product's price at that moment is used.
+type Reservation struct {
+	ReservationID int64     `json:"reservation_id"`
+	ProductID     int64     `json:"product_id"`
+	ProductName   string    `json:"product_name,omitempty"`
+	WarehouseID   int64     `json:"warehouse_id"`
+	WarehouseName string    `json:"warehouse_name,omitempty"`
+	CustomerID    *int64    `json:"customer_id"`
+	Quantity      int64     `json:"quantity"`
+	Price         *float64  `json:"price"`
+	Reference     string    `json:"reference"`
+	Status        string    `json:"status"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	OrderID       *int64    `json:"order_id"`
+	CreatedBy     string    `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+var ErrNoReservationFound error = &Error{Kind: ErrNotFound, Message: "no reservation found with the provided ID"}
+var errReservationCustomerRequired error = &Error{Kind: ErrInvalidArgument, Message: "the reservation has no customer, a customer ID is required"}
+
+func errReservationNotActive(status string) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("the reservation is %s", status)}
+}
+
+func errReservationOtherCustomer(customerID int64) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("the reservation is held for customer %d", customerID)}
+}
+
+func errNotEnoughUnreserved(warehouseID int64) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough unreserved product in stock in warehouse %d", warehouseID)}
+}
+
+// requireUnreserved makes sure quantity units of a product can leave a
+// warehouse other than by converting a reservation without taking stock
+// held by active reservations. It locks the stock row, so no reservation is
+// added until the transaction ends.
+func (db *Database) requireUnreserved(tx *sql.Tx, productID, warehouseID, quantity int64) error {
+	query, err := os.ReadFile(reservationsPath + "available_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database requireUnreserved() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	var available int64
+	err = tx.QueryRow(string(query), warehouseID, productID).Scan(&available)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if available < quantity {
+		return errNotEnoughUnreserved(warehouseID)
+	}
+	return nil
+}
+
+// AddReservation reserves quantity units of a product for ttl, or for the
+// configured ReservationTTL if ttl is nil. The units are held in warehouseID,
+// or in the warehouse allocateWarehouse picks; they must be available there.
+func (db *Database) AddReservation(productID, quantity int64, warehouseID, customerID *int64, price *float64, reference string, ttl *time.Duration, user string) (Reservation, error) {
+	query, err := os.ReadFile(reservationsPath + "add_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database AddReservation() -> Read SQL file", slog.String("error", err.Error()))
+		return Reservation{}, err
+	}
+
+	hold := db.cfg.ReservationTTL
+	if ttl != nil {
+		hold = *ttl
+	}
+
+	customerNull := sql.NullInt64{Valid: customerID != nil}
+	if customerID != nil {
+		customerNull.Int64 = *customerID
+	}
+	priceNull := sql.NullFloat64{Valid: price != nil}
+	if price != nil {
+		priceNull.Float64 = *price
+	}
+
+	r := Reservation{
+		ProductID:  productID,
+		CustomerID: customerID,
+		Quantity:   quantity,
+		Price:      price,
+		Reference:  reference,
+		CreatedBy:  user,
+	}
+	err = db.WithTx(func(tx *sql.Tx) error {
+		warehouse, err := db.allocateWarehouse(tx, productID, quantity, warehouseID, nil, nil)
+		if err != nil {
+			return err
+		}
+		r.WarehouseID = warehouse
+
+		return tx.QueryRow(string(query), productID, warehouse, customerNull, quantity, priceNull, reference, hold.Seconds(), user).
+			Scan(&r.ReservationID, &r.Status, &r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInsufficientStock) {
+		db.Log.Error("Database AddReservation()", slog.String("error", err.Error()))
+	}
+	return r, err
+}
+
+// reservation is an active reservation locked for converting or releasing.
+type reservation struct {
+	productID   int64
+	warehouseID int64
+	customerID  sql.NullInt64
+	quantity    int64
+	price       float64
+}
+
+// lockReservation locks a reservation and makes sure it is still active.
+func (db *Database) lockReservation(tx *sql.Tx, reservationID int64) (reservation, error) {
+	query, err := os.ReadFile(reservationsPath + "lock_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database lockReservation() -> Read SQL file", slog.String("error", err.Error()))
+		return reservation{}, err
+	}
+
+	var r reservation
+	var status string
+	var expired bool
+	err = tx.QueryRow(string(query), reservationID).Scan(&r.productID, &r.warehouseID, &r.customerID, &r.quantity, &r.price, &status, &expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNoReservationFound
+	}
+	if err != nil {
+		return r, err
+	}
+	if status == ReservationActive && expired {
+		status = ReservationExpired
+	}
+	if status != ReservationActive {
+		return r, errReservationNotActive(status)
+	}
+	return r, nil
+}
+
+// ReleaseReservation gives the units of an active reservation back before it
+// expires, e.g. when a cart is abandoned.
+func (db *Database) ReleaseReservation(reservationID int64) error {
+	query, err := os.ReadFile(reservationsPath + "status_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database ReleaseReservation() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		if _, err := db.lockReservation(tx, reservationID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(string(query), reservationID, ReservationReleased, sql.NullInt64{})
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database ReleaseReservation()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ConvertReservation turns an active reservation into an order in one
+// transaction. The order ships from the reservation's warehouse at the
+// reserved price and goes to the reservation's customer; customerID is
+// only needed if the reservation has none. The reservation stops holding
+// stock before the order takes it, so the reserved units are always
+// available to the order.
+func (db *Database) ConvertReservation(reservationID int64, customerID *int64, user string) (int64, int64, error) {
+	query, err := os.ReadFile(reservationsPath + "status_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database ConvertReservation() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, 0, err
+	}
+
+	var orderID, orderDetailID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		r, err := db.lockReservation(tx, reservationID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case r.customerID.Valid && customerID != nil && *customerID != r.customerID.Int64:
+			return errReservationOtherCustomer(r.customerID.Int64)
+		case !r.customerID.Valid && customerID == nil:
+			return errReservationCustomerRequired
+		case customerID == nil:
+			customerID = &r.customerID.Int64
+		}
+
+		if _, err := tx.Exec(string(query), reservationID, ReservationConverted, sql.NullInt64{}); err != nil {
+			return err
+		}
+
+		orderID, orderDetailID, _, err = db.addOrder(tx, *customerID, r.productID, r.quantity, r.price, &r.warehouseID, nil, nil, false, user)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(string(query), reservationID, ReservationConverted, orderID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrInsufficientStock) {
+		db.Log.Error("Database ConvertReservation()", slog.String("error", err.Error()))
+	}
+	return orderID, orderDetailID, err
+}
+
+// ShowReservations lists reservations, newest first, optionally of a single
+// product, warehouse or customer or with a single status. Active
+// reservations past their expiry are reported as expired even before the
+// sweeper marks them.
+func (db *Database) ShowReservations(productID, warehouseID, customerID *int64, status string) ([]Reservation, error) {
+	query, err := os.ReadFile(reservationsPath + "show_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database ShowReservations() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
+	}
+	customerNull := sql.NullInt64{Valid: customerID != nil}
+	if customerID != nil {
+		customerNull.Int64 = *customerID
+	}
+
+	rows, err := db.Query(string(query), productNull, warehouseNull, customerNull, status)
+	if err != nil {
+		db.Log.Error("Database ShowReservations() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var reservations []Reservation
+	for rows.Next() {
+		var r Reservation
+		var customer, orderID sql.NullInt64
+		var price sql.NullFloat64
+		if err := rows.Scan(&r.ReservationID, &r.ProductID, &r.ProductName, &r.WarehouseID, &r.WarehouseName, &customer, &r.Quantity, &price,
+			&r.Reference, &r.Status, &r.ExpiresAt, &orderID, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowReservations() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if customer.Valid {
+			r.CustomerID = &customer.Int64
+		}
+		if price.Valid {
+			r.Price = &price.Float64
+		}
+		if orderID.Valid {
+			r.OrderID = &orderID.Int64
+		}
+		reservations = append(reservations, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowReservations() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// ExpireReservations marks the active reservations that have expired as
+// expired and returns how many there were. Expiry is compared with the
+// database clock, which also set expires_at.
+func (db *Database) ExpireReservations() (int64, error) {
+	query, err := os.ReadFile(reservationsPath + "expire_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database ExpireReservations() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	result, err := db.Exec(string(query))
+	if err != nil {
+		db.Log.Error("Database ExpireReservations() -> db.Exec()", slog.String("error", err.Error()))
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunReservationSweeper calls ExpireReservations every
+// ReservationSweepInterval until the context is cancelled.
+func (db *Database) RunReservationSweeper(ctx context.Context) {
+	ticker := time.NewTicker(db.cfg.ReservationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !db.IsReady() {
+			continue
+		}
+
+		expired, err := db.ExpireReservations()
+		if err != nil || expired == 0 {
+			continue
+		}
+		db.Log.Info("expired reservations", slog.Int64("reservations", expired))
+	}
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	SerialInStock    = "in_stock"
+	SerialSold       = "sold"
+	SerialWrittenOff = "written_off"
+)
+
+// SerialNumber is one unit of a product tracked by serial number. While it
+// is in stock WarehouseID is where it is kept; once sold OrderID and
+// CustomerID tell where it went. History lists everything that happened to
+// the unit, oldest first.
+type SerialNumber struct {
+	SerialNumberID int64         `json:"serial_number_id"`
+	ProductID      int64         `json:"product_id"`
+	ProductName    string        `json:"product_name"`
+	SerialNumber   string        `json:"serial_number"`
+	Status         string        `json:"status"`
+	WarehouseID    *int64        `json:"warehouse_id"`
+	WarehouseName  *string       `json:"warehouse_name"`
+	OrderID        *int64        `json:"order_id,omitempty"`
+	CustomerID     *int64        `json:"customer_id,omitempty"`
+	CustomerName   *string       `json:"customer_name,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	History        []SerialEvent `json:"history"`
+}
+
+// SerialEvent is one step in the history of a serial number. Type is the
+// stock movement type that caused it and Status the status of the unit
+// afterwards. WarehouseID is the warehouse the unit arrived at, or the one
+// it left when it went out of stock. For sales and refunds ReferenceID is
+// the order and CustomerID its customer.
+type SerialEvent struct {
+	Type          string    `json:"event_type"`
+	Status        string    `json:"status"`
+	WarehouseID   *int64    `json:"warehouse_id"`
+	WarehouseName *string   `json:"warehouse_name,omitempty"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   *int64    `json:"reference_id,omitempty"`
+	CustomerID    *int64    `json:"customer_id,omitempty"`
+	CreatedBy     string    `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+var ErrNoSerialNumberFound error = &Error{Kind: ErrNotFound, Message: "no serial number found"}
+
+func errSerialsRequired(productID, quantity int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d is tracked by serial number, %d serial numbers are required", productID, quantity)}
+}
+
+func errNotSerialTracked(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d is not tracked by serial number", productID)}
+}
+
+func errDuplicateSerial(serialNumber string) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("serial number %s is given more than once", serialNumber)}
+}
+
+func errNoOrderDetail(orderDetailID, orderID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("order detail %d does not belong to order %d", orderDetailID, orderID)}
+}
+
+func errSerialStockCount(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d is tracked by serial number, its stock can only change with serial numbers", productID)}
+}
+
+func errSerialTrackingWithStock(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d has stock without serial numbers, serial tracking can only be turned on without stock", productID)}
+}
+
+func errSerialInStock(serialNumber string) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("serial number %s is already in stock", serialNumber)}
+}
+
+func errSerialNotInStock(serialNumber string, warehouseID int64) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf("serial number %s is not in stock in warehouse %d", serialNumber, warehouseID)}
+}
+
+// checkSerials makes sure the serial numbers given for a stock change of
+// quantity units fit the product: a product tracked by serial number needs
+// exactly one distinct serial per unit, other products take none.
+func (db *Database) checkSerials(tx *sql.Tx, productID, quantity int64, serials []string) error {
+	query, err := os.ReadFile(serialNumbersPath + "track_serial_numbers.sql")
+	if err != nil {
+		db.Log.Error("Database checkSerials() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	var tracked bool
+	err = tx.QueryRow(string(query), productID).Scan(&tracked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoProductFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !tracked {
+		if len(serials) > 0 {
+			return errNotSerialTracked(productID)
+		}
+		return nil
+	}
+	if int64(len(serials)) != quantity {
+		return errSerialsRequired(productID, quantity)
+	}
+
+	seen := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		if seen[serial] {
+			return errDuplicateSerial(serial)
+		}
+		seen[serial] = true
+	}
+	return nil
+}
+
+// recordSerialEvent adds a step to the history of a serial number.
+func (db *Database) recordSerialEvent(tx *sql.Tx, serialNumberID int64, e SerialEvent) error {
+	query, err := os.ReadFile(serialNumbersPath + "add_serial_number_events.sql")
+	if err != nil {
+		db.Log.Error("Database recordSerialEvent() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	warehouseNull := sql.NullInt64{Valid: e.WarehouseID != nil}
+	if e.WarehouseID != nil {
+		warehouseNull.Int64 = *e.WarehouseID
+	}
+	referenceNull := sql.NullInt64{Valid: e.ReferenceID != nil}
+	if e.ReferenceID != nil {
+		referenceNull.Int64 = *e.ReferenceID
+	}
+
+	_, err = tx.Exec(string(query), serialNumberID, e.Type, e.Status, warehouseNull, e.ReferenceType, referenceNull, e.CreatedBy)
+	return err
+}
+
+// receiveSerials puts the serial numbers of quantity units of a product that
+// arrive at the event's warehouse in stock and records the event. Serial
+// numbers seen before, such as units that were sold and come back, are put
+// back in stock; a serial number can only be in stock once.
+func (db *Database) receiveSerials(tx *sql.Tx, productID, quantity int64, serials []string, event SerialEvent) error {
+	if err := db.checkSerials(tx, productID, quantity, serials); err != nil {
+		return err
+	}
+
+	query, err := os.ReadFile(serialNumbersPath + "receive_serial_numbers.sql")
+	if err != nil {
+		db.Log.Error("Database receiveSerials() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	event.Status = SerialInStock
+	for _, serial := range serials {
+		var id int64
+		err := tx.QueryRow(string(query), productID, serial, *event.WarehouseID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSerialInStock(serial)
+		}
+		if err != nil {
+			return err
+		}
+		if err := db.recordSerialEvent(tx, id, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSerials takes the serial numbers of quantity units of a product out of
+// warehouseID, where they have to be in stock, and records the event. The
+// units get the event's status; units that stay in stock move to the
+// event's warehouse. Sold units keep the order line they were sold with.
+func (db *Database) moveSerials(tx *sql.Tx, productID, warehouseID, quantity int64, serials []string, orderDetailID *int64, event SerialEvent) error {
+	if err := db.checkSerials(tx, productID, quantity, serials); err != nil {
+		return err
+	}
+
+	query, err := os.ReadFile(serialNumbersPath + "move_serial_numbers.sql")
+	if err != nil {
+		db.Log.Error("Database moveSerials() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	destinationNull := sql.NullInt64{Valid: event.Status == SerialInStock}
+	if destinationNull.Valid {
+		destinationNull.Int64 = *event.WarehouseID
+	}
+	orderDetailNull := sql.NullInt64{Valid: orderDetailID != nil}
+	if orderDetailID != nil {
+		orderDetailNull.Int64 = *orderDetailID
+	}
+
+	for _, serial := range serials {
+		var id int64
+		err := tx.QueryRow(string(query), productID, serial, warehouseID, event.Status, destinationNull, orderDetailNull).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSerialNotInStock(serial, warehouseID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := db.recordSerialEvent(tx, id, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TraceSerial returns every unit with the given serial number, optionally
+// only of one product, with its full history.
+func (db *Database) TraceSerial(serialNumber string, productID *int64) ([]SerialNumber, error) {
+	query, err := os.ReadFile(serialNumbersPath + "trace_serial_numbers.sql")
+	if err != nil {
+		db.Log.Error("Database TraceSerial() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+
+	rows, err := db.Query(string(query), serialNumber, productNull)
+	if err != nil {
+		db.Log.Error("Database TraceSerial() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var units []SerialNumber
+	for rows.Next() {
+		var s SerialNumber
+		var e SerialEvent
+		var warehouseID, orderID, customerID, eventWarehouseID, referenceID, eventCustomerID sql.NullInt64
+		var warehouseName, customerName, eventWarehouseName sql.NullString
+		if err := rows.Scan(&s.SerialNumberID, &s.ProductID, &s.ProductName, &s.SerialNumber, &s.Status, &warehouseID, &warehouseName,
+			&orderID, &customerID, &customerName, &s.CreatedAt, &s.UpdatedAt,
+			&e.Type, &e.Status, &eventWarehouseID, &eventWarehouseName, &e.ReferenceType, &referenceID, &eventCustomerID, &e.CreatedBy, &e.CreatedAt); err != nil {
+			db.Log.Error("Database TraceSerial() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if eventWarehouseID.Valid {
+			e.WarehouseID = &eventWarehouseID.Int64
+		}
+		if eventWarehouseName.Valid {
+			e.WarehouseName = &eventWarehouseName.String
+		}
+		if referenceID.Valid {
+			e.ReferenceID = &referenceID.Int64
+		}
+		if eventCustomerID.Valid {
+			e.CustomerID = &eventCustomerID.Int64
+		}
+
+		if n := len(units); n > 0 && units[n-1].SerialNumberID == s.SerialNumberID {
+			units[n-1].History = append(units[n-1].History, e)
+			continue
+		}
+
+		if warehouseID.Valid {
+			s.WarehouseID = &warehouseID.Int64
+		}
+		if warehouseName.Valid {
+			s.WarehouseName = &warehouseName.String
+		}
+		if orderID.Valid {
+			s.OrderID = &orderID.Int64
+		}
+		if customerID.Valid {
+			s.CustomerID = &customerID.Int64
+		}
+		if customerName.Valid {
+			s.CustomerName = &customerName.String
+		}
+		s.History = []SerialEvent{e}
+		units = append(units, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database TraceSerial() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, ErrNoSerialNumberFound
+	}
+	return units, nil
+}
@@ -0,0 +1,332 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	MovementSale       = "sale"
+	MovementRefund     = "refund"
+	MovementReceipt    = "receipt"
+	MovementAdjustment = "adjustment"
+	MovementTransfer   = "transfer"
+)
+
+// StockMovement is one entry of the stock ledger. The sum of the quantity
+// changes of a product is its on-hand quantity; Balance is that sum up to
+// and including this movement. WarehouseID is the warehouse whose stock
+// changed; a transfer is recorded as two movements that cancel out.
+// ReferenceType and ReferenceID point at the document that caused the
+// movement, such as an order or a goods receipt.
+type StockMovement struct {
+	StockMovementID int64     `json:"stock_movement_id"`
+	ProductID       int64     `json:"product_id"`
+	WarehouseID     int64     `json:"warehouse_id"`
+	QuantityChange  int64     `json:"quantity_change"`
+	Balance         int64     `json:"balance"`
+	Type            string    `json:"movement_type"`
+	Reason          string    `json:"reason"`
+	ReferenceType   string    `json:"reference_type,omitempty"`
+	ReferenceID     *int64    `json:"reference_id,omitempty"`
+	CreatedBy       string    `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// StockDrift is a product whose quantity no longer matches its ledger.
+type StockDrift struct {
+	ProductID      int64  `json:"product_id"`
+	Name           string `json:"name"`
+	Quantity       int64  `json:"quantity"`
+	LedgerQuantity int64  `json:"ledger_quantity"`
+	Difference     int64  `json:"difference"`
+}
+
+var stockMovementList = listSpec[StockMovement]{
+	id:      "m.stock_movement_id",
+	idValue: func(m StockMovement) int64 { return m.StockMovementID },
+	def:     "id",
+	keys: map[string]sortKey[StockMovement]{
+		"id":         {column: "m.stock_movement_id", cast: "BIGINT", value: func(m StockMovement) string { return intValue(m.StockMovementID) }},
+		"created_at": {column: "m.created_at", cast: "TIMESTAMP", value: func(m StockMovement) string { return timeValue(m.CreatedAt) }},
+	},
+	filters: map[string]filterField{
+		"id":              {column: "m.stock_movement_id", kind: numberField},
+		"product_id":      {column: "m.product_id", kind: numberField},
+		"warehouse_id":    {column: "m.warehouse_id", kind: numberField},
+		"quantity_change": {column: "m.quantity_change", kind: numberField},
+		"movement_type":   {column: "m.movement_type", kind: textField},
+		"reason":          {column: "m.reason", kind: textField},
+		"reference_type":  {column: "m.reference_type", kind: textField},
+		"reference_id":    {column: "m.reference_id", kind: numberField},
+		"created_by":      {column: "m.created_by", kind: textField},
+		"created_at":      {column: "m.created_at", kind: timeField},
+	},
+}
+
+// recordMovement adds a movement to the ledger inside the transaction that
+// changes the quantity. Zero changes are not recorded.
+func (db *Database) recordMovement(tx *sql.Tx, m StockMovement) error {
+	if m.QuantityChange == 0 {
+		return nil
+	}
+
+	query, err := os.ReadFile(stockMovementsPath + "add_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database recordMovement() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	referenceNull := sql.NullInt64{Valid: m.ReferenceID != nil}
+	if m.ReferenceID != nil {
+		referenceNull.Int64 = *m.ReferenceID
+	}
+
+	var id int64
+	if err := tx.QueryRow(string(query), m.ProductID, m.QuantityChange, m.Type, m.Reason, m.ReferenceType, referenceNull, m.CreatedBy, m.WarehouseID).Scan(&id); err != nil {
+		db.Log.Error("Database recordMovement() -> tx.QueryRow()", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// placeStock changes the stock of a product in the movement's warehouse and
+// records the movement. The product quantity is left to the caller.
+func (db *Database) placeStock(tx *sql.Tx, m StockMovement) error {
+	query, err := os.ReadFile(warehousesPath + "stock_add_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database placeStock() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	if m.QuantityChange == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(string(query), m.WarehouseID, m.ProductID, m.QuantityChange); err != nil {
+		return err
+	}
+	return db.recordMovement(tx, m)
+}
+
+// moveStock changes the quantity of a product and its stock in the
+// movement's warehouse by the movement's quantity change and records it.
+func (db *Database) moveStock(tx *sql.Tx, m StockMovement) error {
+	query, err := os.ReadFile(productsPath + "quantity_add_products.sql")
+	if err != nil {
+		db.Log.Error("Database moveStock() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	result, err := tx.Exec(string(query), m.ProductID, m.QuantityChange)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrNoProductFound); err != nil {
+		return err
+	}
+	return db.placeStock(tx, m)
+}
+
+// AdjustStock changes the quantity of a product in a warehouse, the default
+// one if warehouseID is nil, by change, which may be negative, and records it
+// as an adjustment with the given reason. Units added go to the lot named by
+// lotNumber, which is created with expiresAt if it is new. Units removed
+// come from that lot, or without a lot number from outside the lots first
+// and then from the lots that expire first, and leave the bins as takeBins
+// decides. Stock held by active reservations cannot be written off.
+// Products tracked by serial number need the serials of the units added or
+// written off.
+func (db *Database) AdjustStock(productID int64, warehouseID *int64, change int64, reason, lotNumber string, expiresAt *time.Time, serials []string, user string) error {
+	err := db.WithTx(func(tx *sql.Tx) error {
+		warehouse, err := db.resolveWarehouse(tx, warehouseID)
+		if err != nil {
+			return err
+		}
+
+		if change < 0 {
+			if err := db.requireUnreserved(tx, productID, warehouse, -change); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case change > 0:
+			_, err = db.receiveLot(tx, productID, warehouse, lotNumber, expiresAt, change)
+		case lotNumber != "":
+			_, err = db.removeFromLot(tx, productID, warehouse, lotNumber, -change)
+		default:
+			_, err = db.takeLots(tx, productID, warehouse, -change, false)
+		}
+		if err != nil {
+			return err
+		}
+		if change < 0 {
+			if err := db.takeBins(tx, productID, warehouse, -change); err != nil {
+				return err
+			}
+		}
+
+		event := SerialEvent{Type: MovementAdjustment, Status: SerialInStock, WarehouseID: &warehouse, CreatedBy: user}
+		if change > 0 {
+			err = db.receiveSerials(tx, productID, change, serials, event)
+		} else {
+			event.Status = SerialWrittenOff
+			err = db.moveSerials(tx, productID, warehouse, -change, serials, nil, event)
+		}
+		if err != nil {
+			return err
+		}
+
+		return db.moveStock(tx, StockMovement{
+			ProductID:      productID,
+			WarehouseID:    warehouse,
+			QuantityChange: change,
+			Type:           MovementAdjustment,
+			Reason:         reason,
+			CreatedBy:      user,
+		})
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database AdjustStock()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ShowStockMovements lists the ledger, optionally of a single product.
+func (db *Database) ShowStockMovements(productID *int64, page Page) ([]StockMovement, string, error) {
+	query, err := os.ReadFile(stockMovementsPath + "show_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ShowStockMovements() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+
+	paged, args, err := stockMovementList.paginate(string(query), []any{productNull}, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.Query(paged, args...)
+	if err != nil {
+		db.Log.Error("Database ShowStockMovements() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var movements []StockMovement
+	for rows.Next() {
+		var m StockMovement
+		var referenceID sql.NullInt64
+		if err := rows.Scan(&m.StockMovementID, &m.ProductID, &m.WarehouseID, &m.QuantityChange, &m.Balance, &m.Type, &m.Reason,
+			&m.ReferenceType, &referenceID, &m.CreatedBy, &m.CreatedAt); err != nil {
+			db.Log.Error("Database ShowStockMovements() -> parsing rows", slog.String("error", err.Error()))
+			return nil, "", err
+		}
+		if referenceID.Valid {
+			m.ReferenceID = &referenceID.Int64
+		}
+		movements = append(movements, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowStockMovements() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	movements, next := stockMovementList.nextPage(movements, page)
+	return movements, next, nil
+}
+
+// ReconcileStock compares every product quantity with the sum of its ledger
+// and returns the products that drifted. With apply the ledger wins: the
+// quantities of those products, and the stock of every warehouse, are reset
+// to their ledger sums in the same transaction. Lots and bins that then hold
+// more than their warehouse has are trimmed to fit, in the order stock is
+// written off: lots by expiry and bins along the walking route. Bins may
+// keep the units of orders that were sold but not picked yet.
+func (db *Database) ReconcileStock(apply bool) ([]StockDrift, error) {
+	query, err := os.ReadFile(stockMovementsPath + "drift_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ReconcileStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	fixQuery, err := os.ReadFile(stockMovementsPath + "reconcile_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ReconcileStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	warehouseQuery, err := os.ReadFile(stockMovementsPath + "reconcile_warehouses_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ReconcileStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	lotsQuery, err := os.ReadFile(stockMovementsPath + "reconcile_lots_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ReconcileStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	binsQuery, err := os.ReadFile(stockMovementsPath + "reconcile_bins_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database ReconcileStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	var drifts []StockDrift
+	err = db.WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		drifts = nil
+
+		rows, err := tx.Query(string(query))
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var d StockDrift
+			if err := rows.Scan(&d.ProductID, &d.Name, &d.Quantity, &d.LedgerQuantity); err != nil {
+				rows.Close()
+				return err
+			}
+			d.Difference = d.Quantity - d.LedgerQuantity
+			drifts = append(drifts, d)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if !apply {
+			return nil
+		}
+		for _, q := range [][]byte{fixQuery, warehouseQuery, lotsQuery, binsQuery} {
+			if _, err := tx.Exec(string(q)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Log.Error("Database ReconcileStock()", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if len(drifts) > 0 {
+		db.Log.Warn("stock quantities drifted from the ledger", slog.Int("products", len(drifts)), slog.Bool("applied", apply))
+	}
+	return drifts, nil
+}
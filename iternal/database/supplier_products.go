@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// SupplierProduct is one entry of the supplier catalog: what a supplier
+// charges for a product and how it delivers. IsPrimary marks the supplier set
+// on the product itself.
+type SupplierProduct struct {
+	SupplierProductID int64     `json:"supplier_product_id"`
+	SupplierID        int64     `json:"supplier_id"`
+	SupplierName      string    `json:"supplier_name"`
+	ProductID         int64     `json:"product_id"`
+	ProductName       string    `json:"product_name"`
+	CostPrice         float64   `json:"cost_price"`
+	SupplierSKU       string    `json:"supplier_sku,omitempty"`
+	LeadTimeDays      int64     `json:"lead_time_days"`
+	MinOrderQuantity  int64     `json:"min_order_quantity"`
+	IsPrimary         bool      `json:"is_primary"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+var ErrNoSupplierProductFound error = &Error{Kind: ErrNotFound, Message: "no supplier product found with the provided ID"}
+
+func (db *Database) AddSupplierProduct(supplierID, productID int64, costPrice float64, supplierSKU string, leadTimeDays, minOrderQuantity int64) (int64, error) {
+	query, err := os.ReadFile(supplierProductsPath + "add_supplier_products.sql")
+	if err != nil {
+		db.Log.Error("Database AddSupplierProduct() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var supplierProductID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(string(query), supplierID, productID, costPrice,
+			sql.NullString{String: supplierSKU, Valid: supplierSKU != ""}, leadTimeDays, minOrderQuantity).Scan(&supplierProductID)
+	})
+	if err != nil {
+		db.Log.Error("Database AddSupplierProduct() -> tx.QueryRow().Scan()", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	return supplierProductID, nil
+}
+
+func (db *Database) UpdateSupplierProduct(supplierProductID int64, costPrice *float64, supplierSKU *string, leadTimeDays, minOrderQuantity *int64) error {
+	query, err := os.ReadFile(supplierProductsPath + "set_supplier_products.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateSupplierProduct() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	costPriceNull := sql.NullFloat64{Valid: costPrice != nil && *costPrice >= 0}
+	supplierSKUNull := sql.NullString{Valid: supplierSKU != nil && *supplierSKU != ""}
+	leadTimeNull := sql.NullInt64{Valid: leadTimeDays != nil && *leadTimeDays >= 0}
+	minOrderNull := sql.NullInt64{Valid: minOrderQuantity != nil && *minOrderQuantity > 0}
+
+	if costPriceNull.Valid {
+		costPriceNull.Float64 = *costPrice
+	}
+	if supplierSKUNull.Valid {
+		supplierSKUNull.String = *supplierSKU
+	}
+	if leadTimeNull.Valid {
+		leadTimeNull.Int64 = *leadTimeDays
+	}
+	if minOrderNull.Valid {
+		minOrderNull.Int64 = *minOrderQuantity
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), supplierProductID, costPriceNull, supplierSKUNull, leadTimeNull, minOrderNull)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoSupplierProductFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoSupplierProductFound) {
+		db.Log.Error("Database UpdateSupplierProduct() -> tx.Exec()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+func (db *Database) DeleteSupplierProduct(supplierProductID int64) error {
+	query, err := os.ReadFile(supplierProductsPath + "delete_supplier_products.sql")
+	if err != nil {
+		db.Log.Error("Database DeleteSupplierProduct() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), supplierProductID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoSupplierProductFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoSupplierProductFound) {
+		db.Log.Error("Database DeleteSupplierProduct()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ShowSupplierProducts lists the catalog entries of active suppliers and
+// products, optionally narrowed to one product and/or one supplier. Entries
+// of a product are ordered primary supplier first, then by cost.
+func (db *Database) ShowSupplierProducts(productID, supplierID *int64) ([]SupplierProduct, error) {
+	query, err := os.ReadFile(supplierProductsPath + "show_supplier_products.sql")
+	if err != nil {
+		db.Log.Error("Database ShowSupplierProducts() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+	supplierNull := sql.NullInt64{Valid: supplierID != nil}
+	if supplierID != nil {
+		supplierNull.Int64 = *supplierID
+	}
+
+	rows, err := db.Query(string(query), productNull, supplierNull)
+	if err != nil {
+		db.Log.Error("Database ShowSupplierProducts() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var catalog []SupplierProduct
+	for rows.Next() {
+		var sp SupplierProduct
+		var supplierSKU sql.NullString
+		if err := rows.Scan(&sp.SupplierProductID, &sp.SupplierID, &sp.SupplierName, &sp.ProductID, &sp.ProductName,
+			&sp.CostPrice, &supplierSKU, &sp.LeadTimeDays, &sp.MinOrderQuantity, &sp.IsPrimary, &sp.CreatedAt, &sp.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowSupplierProducts() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		sp.SupplierSKU = supplierSKU.String
+		catalog = append(catalog, sp)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowSupplierProducts() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return catalog, nil
+}
@@ -4,52 +4,57 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"time"
 )
 
-var ErrNoSupplierFound = errors.New("no supplier found with the provided ID")
+var supplierList = listSpec[Supplier]{
+	id:      "supplier_id",
+	idValue: func(s Supplier) int64 { return s.SupplierID },
+	def:     "id",
+	keys: map[string]sortKey[Supplier]{
+		"id":            {column: "supplier_id", cast: "BIGINT", value: func(s Supplier) string { return intValue(s.SupplierID) }},
+		"name":          {column: "name", cast: "TEXT", value: func(s Supplier) string { return s.Name }},
+		"contact_name":  {column: "contact_name", cast: "TEXT", value: func(s Supplier) string { return s.ContactName }},
+		"contact_email": {column: "contact_email", cast: "TEXT", value: func(s Supplier) string { return s.ContactEmail }},
+	},
+	filters: map[string]filterField{
+		"id":            {column: "supplier_id", kind: numberField},
+		"name":          {column: "name", kind: textField},
+		"contact_name":  {column: "contact_name", kind: textField},
+		"contact_email": {column: "contact_email", kind: textField},
+		"contact_phone": {column: "contact_phone", kind: textField},
+	},
+}
+
+var ErrNoSupplierFound error = &Error{Kind: ErrNotFound, Message: "no supplier found with the provided ID"}
+var ErrNoSupplierDeleted error = &Error{Kind: ErrNotFound, Message: "no deleted supplier found with the provided ID"}
 
 type Supplier struct {
-	SupplierID   int64  `json:"id"`
-	Name         string `json:"name"`
-	ContactName  string `json:"contact_name"`
-	ContactEmail string `json:"contact_email"`
-	ContactPhone string `json:"contact_phone"`
+	SupplierID   int64      `json:"id"`
+	Name         string     `json:"name"`
+	ContactName  string     `json:"contact_name"`
+	ContactEmail string     `json:"contact_email"`
+	ContactPhone string     `json:"contact_phone"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (db *Database) AddSupplier(name, contactName, contactEmail, contactPhone string) (int64, error) {
 	query, err := os.ReadFile(suppliersPath + "add_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database AddSupplier() -> Read SQL file", err)
+		db.Log.Error("Database AddSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return 0, err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database AddSupplier() -> db.Begin()", err)
-		return -1, err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	var supplierID int64
-	err = tx.QueryRow(string(query), name, contactName, contactEmail, contactPhone).Scan(&supplierID)
+	err = db.WithTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(string(query), name, contactName, contactEmail, contactPhone).Scan(&supplierID)
+	})
 	if err != nil {
-		db.Log.Error("Database AddSupplier()", err)
-		return -1, err
-	}
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database AddSupplier() -> tx.Commit()", err)
+		db.Log.Error("Database AddSupplier()", slog.String("error", err.Error()))
 		return -1, err
 	}
-	committed = true
 
 	return supplierID, nil
 }
@@ -57,149 +62,91 @@ func (db *Database) AddSupplier(name, contactName, contactEmail, contactPhone st
 func (db *Database) CheckEmailSupplier(contactEmail string) (int64, error) {
 	query, err := os.ReadFile(suppliersPath + "check_by_email_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database CheckEmailSupplier() -> Read SQL file", err)
-		return -1, err
-	}
-
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database CheckEmailSupplier() -> db.Begin()", err)
+		db.Log.Error("Database CheckEmailSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return -1, err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
 
 	var supplierID int64
-	err = tx.QueryRow(string(query), contactEmail).Scan(&supplierID)
+	err = db.QueryRow(string(query), contactEmail).Scan(&supplierID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return -1, nil
 		}
-		db.Log.Error("Database CheckEmailSupplier()", err)
+		db.Log.Error("Database CheckEmailSupplier()", slog.String("error", err.Error()))
 		return -1, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database CheckEmailSupplier() -> tx.Commit()", err)
-		return -1, err
-	}
-	committed = true
-
 	return supplierID, nil
 }
 
 func (db *Database) CheckPhoneSupplier(contactPhone string) (int64, error) {
 	query, err := os.ReadFile(suppliersPath + "check_by_phone_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database CheckPhoneSupplier() -> Read SQL file", err)
-		return -1, err
-	}
-
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database CheckPhoneSupplier() -> db.Begin()", err)
+		db.Log.Error("Database CheckPhoneSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return -1, err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
 
 	var supplierID int64
-	err = tx.QueryRow(string(query), contactPhone).Scan(&supplierID)
+	err = db.QueryRow(string(query), contactPhone).Scan(&supplierID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return -1, nil
 		}
-		db.Log.Error("Database CheckPhoneSupplier()", err)
+		db.Log.Error("Database CheckPhoneSupplier()", slog.String("error", err.Error()))
 		return -1, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database CheckPhoneSupplier() -> tx.Commit()", err)
-		return -1, err
-	}
-	committed = true
-
 	return supplierID, nil
 }
 
 func (db *Database) DeleteSupplier(supplierID int64) error {
 	query, err := os.ReadFile(suppliersPath + "delete_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteSupplier() -> Read SQL file", err)
+		db.Log.Error("Database DeleteSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database DeleteSupplier() -> db.Begin()", err)
-		return err
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), supplierID)
+		if err != nil {
+			return err
 		}
-	}()
-
-	result, err := tx.Exec(string(query), supplierID)
-	if err != nil {
-		db.Log.Error("Database DeleteSupplier()", err)
-		return err
+		return requireAffected(result, ErrNoSupplierFound)
+	})
+	if errors.Is(err, ErrNoSupplierFound) {
+		db.Log.Info(fmt.Sprintf("Database DeleteSupplier() -> No supplier found with ID %d", supplierID))
+	} else if err != nil {
+		db.Log.Error("Database DeleteSupplier()", slog.String("error", err.Error()))
 	}
+	return err
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (db *Database) RestoreSupplier(supplierID int64) error {
+	query, err := os.ReadFile(suppliersPath + "restore_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database DeleteSupplier() -> Checking rows affected", err)
+		db.Log.Error("Database RestoreSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
 
-	if rowsAffected == 0 {
-		db.Log.Info(fmt.Sprintf("Database DeleteSupplier() -> No supplier found with ID %d", supplierID))
-		return ErrNoSupplierFound
-	}
-
-	if err := tx.Commit(); err != nil {
-		db.Log.Error("Database DeleteSupplier() -> tx.Commit()", err)
-		return err
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), supplierID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoSupplierDeleted)
+	})
+	if err != nil && !errors.Is(err, ErrNoSupplierDeleted) {
+		db.Log.Error("Database RestoreSupplier()", slog.String("error", err.Error()))
 	}
-	committed = true
-
-	return nil
+	return err
 }
 
 func (db *Database) UpdateSupplier(supplierID int64, name, contactName, contactEmail, contactPhone *string) error {
 	query, err := os.ReadFile(suppliersPath + "set_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database UpdateSupplier() -> Read SQL file", err)
-		return err
-	}
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		db.Log.Error("Database UpdateSupplier() -> db.Begin()", err)
+		db.Log.Error("Database UpdateSupplier() -> Read SQL file", slog.String("error", err.Error()))
 		return err
 	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Warn("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
 
 	nameNull := sql.NullString{String: "", Valid: false}
 	contactNameNull := sql.NullString{String: "", Valid: false}
@@ -219,77 +166,78 @@ func (db *Database) UpdateSupplier(supplierID int64, name, contactName, contactE
 		contactPhoneNull = sql.NullString{String: *contactPhone, Valid: true}
 	}
 
-	result, err := tx.Exec(string(query), supplierID, nameNull, contactNameNull, contactEmailNull, contactPhoneNull)
-	if err != nil {
-		db.Log.Error("Database UpdateSupplier() -> tx.Exec()", err)
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		db.Log.Error("Database UpdateSupplier() -> Checking rows affected", err)
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return ErrNoSupplierFound
-	}
-
-	if err = tx.Commit(); err != nil {
-		db.Log.Error("Database UpdateSupplier() -> tx.Commit()", err)
-		return err
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), supplierID, nameNull, contactNameNull, contactEmailNull, contactPhoneNull)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrNoSupplierFound)
+	})
+	if err != nil && !errors.Is(err, ErrNoSupplierFound) {
+		db.Log.Error("Database UpdateSupplier() -> tx.Exec()", slog.String("error", err.Error()))
 	}
-	committed = true
-
-	return nil
+	return err
 }
 
 func (db *Database) readRowsSupplier(rows *sql.Rows) ([]Supplier, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
 	var suppliers []Supplier
 	for rows.Next() {
 		var s Supplier
-		if err := rows.Scan(&s.SupplierID, &s.Name, &s.ContactName, &s.ContactEmail, &s.ContactPhone); err != nil {
-			db.Log.Error("Database readRowsSupplier() -> rows.Scan()", err)
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&s.SupplierID, &s.Name, &s.ContactName, &s.ContactEmail, &s.ContactPhone, &deletedAt); err != nil {
+			db.Log.Error("Database readRowsSupplier() -> rows.Scan()", slog.String("error", err.Error()))
 			return nil, err
 		}
+		if deletedAt.Valid {
+			s.DeletedAt = &deletedAt.Time
+		}
 		suppliers = append(suppliers, s)
 	}
 
 	if err := rows.Err(); err != nil {
-		db.Log.Error("Database readRowsSupplier() -> rows.Err()", err)
+		db.Log.Error("Database readRowsSupplier() -> rows.Err()", slog.String("error", err.Error()))
 		return nil, err
 	}
 	return suppliers, nil
 }
 
-func (db *Database) ShowSuppliers(limit *int) ([]Supplier, error) {
+func (db *Database) ShowSuppliers(page Page, includeDeleted bool) ([]Supplier, string, error) {
 	query, err := os.ReadFile(suppliersPath + "show_suppliers.sql")
 	if err != nil {
-		db.Log.Error("Database ShowSuppliers() -> Read SQL file", err)
-		return nil, err
+		db.Log.Error("Database ShowSuppliers() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, "", err
 	}
 
-	var limitValue sql.NullInt64
-	if limit != nil {
-		limitValue = sql.NullInt64{Int64: int64(*limit), Valid: true}
-	} else {
-		limitValue = sql.NullInt64{Int64: 1000, Valid: true}
+	paged, args, err := supplierList.paginate(string(query), []any{includeDeleted}, page)
+	if err != nil {
+		return nil, "", err
 	}
 
-	rows, err := db.Query(string(query), limitValue)
+	rows, err := db.Query(paged, args...)
 	if err != nil {
-		db.Log.Error("Database ShowSuppliers() -> db.Query()", err)
-		return nil, err
+		db.Log.Error("Database ShowSuppliers() -> db.Query()", slog.String("error", err.Error()))
+		return nil, "", err
+	}
+
+	items, err := db.readRowsSupplier(rows)
+	if err != nil {
+		return nil, "", err
 	}
-	defer rows.Close()
 
-	return db.readRowsSupplier(rows)
+	items, next := supplierList.nextPage(items, page)
+	return items, next, nil
 }
 
 func (db *Database) CheckSupplierExists(supplierID int64) (bool, error) {
 	query, err := os.ReadFile(productsPath + "check_supplier_exists.sql")
 	if err != nil {
-		db.Log.Error("Database CheckSupplierExists() -> Read SQL file", err)
+		db.Log.Error("Database CheckSupplierExists() -> Read SQL file", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -299,7 +247,7 @@ func (db *Database) CheckSupplierExists(supplierID int64) (bool, error) {
 		if err == sql.ErrNoRows {
 			return false, nil
 		}
-		db.Log.Error("Database CheckSupplierExists() -> Error checking if supplier exists", err)
+		db.Log.Error("Database CheckSupplierExists() -> Error checking if supplier exists", slog.String("error", err.Error()))
 		return false, err
 	}
 
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 )
@@ -36,43 +38,30 @@ func (db *Database) LoadTrustedUsers() {
 		log.Fatalf("error, \"base_add_trusted_users.sql\" can't be empty")
 	}
 
-	tx, err := db.Begin()
-	committed := false
-	if err != nil {
-		log.Fatalf("LoadTrustedUsers() -> db.Begin: %s", err)
-	}
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				db.Log.Error("Some troubles after tx.Rollback()", err)
-			}
-		}
-	}()
-
 	requests := strings.Split(string(file), ";")
 
-	for _, request := range requests {
-		_, err := tx.Exec(request)
-		if err != nil {
-			log.Fatalf("error database during table initialization: %s", err)
+	err = db.WithTx(func(tx *sql.Tx) error {
+		for _, request := range requests {
+			if _, err := tx.Exec(request); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("error database during trusted users initialization: %s", err)
 	}
-
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("LoadTrustedUsers() -> db.Commit: %s", err)
-	}
-	committed = true
 }
 
 func (db *Database) CheckTrustedUser(login string) (bool, string, error) {
 	query, err := os.ReadFile(trustedUsersPath + "check_trusted_user.sql")
 	if err != nil {
-		db.Log.Error("Database CheckTrustedUser() -> Read SQL file", err)
+		db.Log.Error("Database CheckTrustedUser() -> Read SQL file", slog.String("error", err.Error()))
 		return false, "", err
 	}
 	var password string
 	if err := db.QueryRow(string(query), login).Scan(&password); err != nil {
-		db.Log.Error("Database CheckTrustedUser() -> db.QueryRow()", err)
+		db.Log.Error("Database CheckTrustedUser() -> db.QueryRow()", slog.String("error", err.Error()))
 	}
 	return password != "", password, nil
 }
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"time"
+)
+
+const maxTxAttempts = 3
+const txRetryDelay = 50 * time.Millisecond
+
+// WithTx runs fn inside a single transaction. The transaction is committed
+// when fn returns nil and rolled back when it returns an error or panics;
+// a panic is re-raised after the rollback. Serialization failures and
+// deadlocks reported by PostgreSQL restart the whole unit of work, so fn
+// must not have side effects outside the transaction. Integrity violations
+// are returned as *Error.
+func (db *Database) WithTx(fn func(tx *sql.Tx) error) error {
+	return db.WithTxOptions(nil, fn)
+}
+
+// WithTxOptions is WithTx with an explicit isolation level or read-only flag.
+func (db *Database) WithTxOptions(opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = db.runTx(opts, fn)
+		if err == nil || !isRetryableTxError(err) {
+			return translateError(err)
+		}
+		db.Log.Warn("transaction conflict, retrying",
+			slog.Int("attempt", attempt),
+			slog.String("error", err.Error()))
+		time.Sleep(time.Duration(attempt) * txRetryDelay)
+	}
+	return translateError(err)
+}
+
+func (db *Database) runTx(opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			db.rollback(tx)
+			panic(p)
+		}
+		if err != nil {
+			db.rollback(tx)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (db *Database) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		db.Log.Warn("Some troubles after tx.Rollback()", slog.String("error", err.Error()))
+	}
+}
+
+// isRetryableTxError reports whether PostgreSQL aborted the transaction
+// because of a concurrent one (serialization_failure or deadlock_detected).
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// requireAffected turns an UPDATE or DELETE that matched no rows into
+// notFound, which also rolls back the surrounding transaction.
+func requireAffected(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
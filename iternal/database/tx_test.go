@@ -0,0 +1,158 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"testing"
+)
+
+func insert(tx *sql.Tx) error {
+	_, err := tx.Exec("INSERT INTO t VALUES (1)")
+	return err
+}
+
+func TestWithTxCommits(t *testing.T) {
+	db, server := newFakeDatabase(t, fastRetries)
+
+	if err := db.WithTx(insert); err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if server.commits != 1 || server.rollbacks != 0 {
+		t.Errorf("commits = %d, rollbacks = %d, want 1 and 0", server.commits, server.rollbacks)
+	}
+	if len(server.committed) != 1 {
+		t.Errorf("committed statements = %v, want one", server.committed)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	db, server := newFakeDatabase(t, fastRetries)
+	errFailed := errors.New("failed")
+
+	err := db.WithTx(func(tx *sql.Tx) error {
+		if err := insert(tx); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("WithTx() error = %v, want %v", err, errFailed)
+	}
+	if server.commits != 0 || server.rollbacks != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", server.commits, server.rollbacks)
+	}
+	if len(server.committed) != 0 {
+		t.Errorf("committed statements = %v, want none", server.committed)
+	}
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	db, server := newFakeDatabase(t, fastRetries)
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatalf("recovered %v, want the panic to be re-raised", p)
+		}
+		if server.commits != 0 || server.rollbacks != 1 {
+			t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", server.commits, server.rollbacks)
+		}
+		if len(server.committed) != 0 {
+			t.Errorf("committed statements = %v, want none", server.committed)
+		}
+	}()
+
+	_ = db.WithTx(func(tx *sql.Tx) error {
+		if err := insert(tx); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	t.Fatal("WithTx() returned, want a panic")
+}
+
+func TestWithTxRetriesSerializationFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "succeeds after a conflict", failures: maxTxAttempts - 1, wantCalls: maxTxAttempts},
+		{name: "gives up after maxTxAttempts", failures: maxTxAttempts, wantCalls: maxTxAttempts, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, server := newFakeDatabase(t, fastRetries)
+			for i := 0; i < tt.failures; i++ {
+				server.execErrors = append(server.execErrors, &pq.Error{Code: "40001", Message: "could not serialize access"})
+			}
+
+			calls := 0
+			err := db.WithTx(func(tx *sql.Tx) error {
+				calls++
+				return insert(tx)
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+			var pqErr *pq.Error
+			if tt.wantErr != errors.As(err, &pqErr) {
+				t.Fatalf("WithTx() error = %v, want a serialization failure: %t", err, tt.wantErr)
+			}
+			if server.rollbacks != tt.failures {
+				t.Errorf("rollbacks = %d, want %d", server.rollbacks, tt.failures)
+			}
+			wantCommitted := 1
+			if tt.wantErr {
+				wantCommitted = 0
+			}
+			if len(server.committed) != wantCommitted {
+				t.Errorf("committed statements = %v, want %d", server.committed, wantCommitted)
+			}
+		})
+	}
+}
+
+func TestWithTxTranslatesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "no rows", err: sql.ErrNoRows, want: ErrNotFound},
+		{name: "unique violation", err: &pq.Error{Code: "23505", Constraint: "products_name_key"}, want: ErrConflict},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"}, want: ErrConstraint},
+		{name: "stock check", err: &pq.Error{Code: "23514", Constraint: "products_quantity_check"}, want: ErrInsufficientStock},
+		{name: "other check", err: &pq.Error{Code: "23514", Constraint: "products_price_check"}, want: ErrConstraint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, server := newFakeDatabase(t, fastRetries)
+			server.execErrors = []error{tt.err}
+
+			calls := 0
+			err := db.WithTx(func(tx *sql.Tx) error {
+				calls++
+				return insert(tx)
+			})
+
+			var dbErr *Error
+			if !errors.As(err, &dbErr) || !errors.Is(err, tt.want) {
+				t.Fatalf("WithTx() error = %#v, want *Error of kind %v", err, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("WithTx() error does not wrap %v", tt.err)
+			}
+			if calls != 1 {
+				t.Errorf("fn called %d times, want 1", calls)
+			}
+			if server.rollbacks != 1 {
+				t.Errorf("rollbacks = %d, want 1", server.rollbacks)
+			}
+		})
+	}
+}
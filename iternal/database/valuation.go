@@ -0,0 +1,318 @@
+package database
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	ValuationFIFO            = "fifo"
+	ValuationWeightedAverage = "weighted_average"
+)
+
+// ValuationLine is the stock of a product in a warehouse and its value at
+// cost. UnitCost is the value divided by the quantity on hand, or 0 when
+// there is none. Uncosted is true when some of the units on hand are valued
+// at 0 because neither a purchase cost nor a supplier cost price was known.
+type ValuationLine struct {
+	ProductID     int64   `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	CategoryID    *int64  `json:"category_id"`
+	Category      string  `json:"category"`
+	WarehouseID   int64   `json:"warehouse_id"`
+	WarehouseName string  `json:"warehouse_name"`
+	Quantity      int64   `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+	Value         float64 `json:"value"`
+	Uncosted      bool    `json:"uncosted"`
+}
+
+// ValuationTotal is the stock and its value summed over a category or a
+// warehouse. ID is nil for the products without a category.
+type ValuationTotal struct {
+	ID       *int64  `json:"id"`
+	Name     string  `json:"name"`
+	Quantity int64   `json:"quantity"`
+	Value    float64 `json:"value"`
+}
+
+// InventoryValuation is the value at cost of the stock on hand at AsOf.
+type InventoryValuation struct {
+	AsOf       time.Time        `json:"as_of"`
+	Method     string           `json:"method"`
+	Quantity   int64            `json:"quantity"`
+	TotalValue float64          `json:"total_value"`
+	Lines      []ValuationLine  `json:"lines"`
+	Categories []ValuationTotal `json:"categories"`
+	Warehouses []ValuationTotal `json:"warehouses"`
+}
+
+// costLayer is a number of units that came in at the same unit cost.
+// Uncosted layers came in without a known cost and are valued at 0.
+type costLayer struct {
+	quantity int64
+	cost     float64
+	uncosted bool
+}
+
+// valuedStock is the stock of a product in a warehouse while the ledger is
+// replayed. Layers are the units on hand, oldest first; short is the number
+// of units that went out while there were none on hand, which the next
+// units in cover.
+type valuedStock struct {
+	line     ValuationLine
+	layers   []costLayer
+	short    int64
+	lastCost float64
+	known    bool
+}
+
+// add puts units on hand. With average set the layers are merged into one
+// at their weighted average cost.
+func (s *valuedStock) add(layers []costLayer, average bool) {
+	for _, l := range layers {
+		covered := min(s.short, l.quantity)
+		s.short -= covered
+		l.quantity -= covered
+		if !l.uncosted {
+			s.lastCost, s.known = l.cost, true
+		}
+		if l.quantity > 0 {
+			s.layers = append(s.layers, l)
+		}
+	}
+
+	if average && len(s.layers) > 1 {
+		merged := costLayer{}
+		var value float64
+		for _, l := range s.layers {
+			merged.quantity += l.quantity
+			merged.uncosted = merged.uncosted || l.uncosted
+			value += float64(l.quantity) * l.cost
+		}
+		merged.cost = value / float64(merged.quantity)
+		s.layers = []costLayer{merged}
+	}
+}
+
+// take removes units from stock, oldest first, and returns the layers they
+// were valued at. Units taken while none are on hand are valued at the last
+// known cost.
+func (s *valuedStock) take(quantity int64) []costLayer {
+	var taken []costLayer
+	for quantity > 0 && len(s.layers) > 0 {
+		l := &s.layers[0]
+		n := min(quantity, l.quantity)
+		taken = append(taken, costLayer{quantity: n, cost: l.cost, uncosted: l.uncosted})
+		if !l.uncosted {
+			s.lastCost, s.known = l.cost, true
+		}
+		l.quantity -= n
+		quantity -= n
+		if l.quantity == 0 {
+			s.layers = s.layers[1:]
+		}
+	}
+	if quantity > 0 {
+		s.short += quantity
+		taken = append(taken, costLayer{quantity: quantity, cost: s.lastCost, uncosted: !s.known})
+	}
+	return taken
+}
+
+// stockKey is a product in a warehouse.
+type stockKey struct{ productID, warehouseID int64 }
+
+// valuationMovement is one movement of the stock ledger, with the cost it
+// came in at if known and the product's current cost price to fall back on.
+type valuationMovement struct {
+	line         ValuationLine
+	change       int64
+	movementType string
+	referenceID  sql.NullInt64
+	unitCost     sql.NullFloat64
+	fallbackCost sql.NullFloat64
+}
+
+// valuationReplay is the stock of every product in every warehouse while the
+// ledger is replayed, in the order the stocks first moved.
+type valuationReplay struct {
+	average bool
+	stocks  map[stockKey]*valuedStock
+	order   []stockKey
+	// Transfers take units out of the source warehouse before putting them
+	// into the destination, so the layers taken wait here in between.
+	transfers map[int64][]costLayer
+}
+
+func newValuationReplay(average bool) *valuationReplay {
+	return &valuationReplay{
+		average:   average,
+		stocks:    make(map[stockKey]*valuedStock),
+		transfers: make(map[int64][]costLayer),
+	}
+}
+
+// apply replays one movement of the ledger.
+func (r *valuationReplay) apply(m valuationMovement) {
+	key := stockKey{m.line.ProductID, m.line.WarehouseID}
+	s, ok := r.stocks[key]
+	if !ok {
+		s = &valuedStock{line: m.line}
+		r.stocks[key] = s
+		r.order = append(r.order, key)
+	}
+
+	if m.change < 0 {
+		taken := s.take(-m.change)
+		if m.movementType == MovementTransfer && m.referenceID.Valid {
+			r.transfers[m.referenceID.Int64] = taken
+		}
+		return
+	}
+
+	if m.movementType == MovementTransfer && m.referenceID.Valid {
+		if taken, ok := r.transfers[m.referenceID.Int64]; ok {
+			delete(r.transfers, m.referenceID.Int64)
+			s.add(taken, r.average)
+			return
+		}
+	}
+
+	layer := costLayer{quantity: m.change}
+	switch {
+	case m.unitCost.Valid:
+		layer.cost = m.unitCost.Float64
+	case m.movementType == MovementAdjustment && s.known:
+		layer.cost = s.lastCost
+	case m.fallbackCost.Valid:
+		layer.cost = m.fallbackCost.Float64
+	default:
+		layer.uncosted = true
+	}
+	s.add([]costLayer{layer}, r.average)
+}
+
+// InventoryValuation values the stock on hand at asOf by replaying the stock
+// ledger up to it. Receipts come in at the unit cost of their purchase order
+// line and refunds at the cost recorded on the order, or at the product's
+// current supplier cost price when there is none. Other units added are
+// valued at the last cost of the stock they join. Sales, write-offs and
+// transfers take units out at FIFO cost or at the weighted average cost,
+// depending on method, and transferred units keep their cost in the
+// destination warehouse. Units sold while none were on hand are left out of
+// the value, so a warehouse with negative stock is valued at 0.
+func (db *Database) InventoryValuation(asOf time.Time, method string) (InventoryValuation, error) {
+	valuation := InventoryValuation{AsOf: asOf, Method: method}
+
+	query, err := os.ReadFile(stockMovementsPath + "valuation_stock_movements.sql")
+	if err != nil {
+		db.Log.Error("Database InventoryValuation() -> Read SQL file", slog.String("error", err.Error()))
+		return valuation, err
+	}
+
+	rows, err := db.Query(string(query), asOf)
+	if err != nil {
+		db.Log.Error("Database InventoryValuation() -> db.Query()", slog.String("error", err.Error()))
+		return valuation, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	replay := newValuationReplay(method == ValuationWeightedAverage)
+	for rows.Next() {
+		var m valuationMovement
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&m.line.ProductID, &m.line.ProductName, &categoryID, &m.line.Category, &m.line.WarehouseID, &m.line.WarehouseName,
+			&m.change, &m.movementType, &m.referenceID, &m.unitCost, &m.fallbackCost); err != nil {
+			db.Log.Error("Database InventoryValuation() -> parsing rows", slog.String("error", err.Error()))
+			return valuation, err
+		}
+		if categoryID.Valid {
+			m.line.CategoryID = &categoryID.Int64
+		}
+		replay.apply(m)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database InventoryValuation() -> rows.Err()", slog.String("error", err.Error()))
+		return valuation, err
+	}
+
+	categories := make(map[int64]int)
+	uncategorized := -1
+	warehouses := make(map[int64]int)
+	for _, key := range replay.order {
+		s := replay.stocks[key]
+		line := s.line
+		for _, l := range s.layers {
+			line.Quantity += l.quantity
+			line.Value += float64(l.quantity) * l.cost
+			line.Uncosted = line.Uncosted || l.uncosted
+		}
+		line.Quantity -= s.short
+		if line.Quantity == 0 {
+			continue
+		}
+		if line.Quantity > 0 {
+			line.UnitCost = line.Value / float64(line.Quantity)
+		}
+		valuation.Lines = append(valuation.Lines, line)
+		valuation.Quantity += line.Quantity
+		valuation.TotalValue += line.Value
+
+		var category *ValuationTotal
+		if line.CategoryID == nil {
+			if uncategorized < 0 {
+				uncategorized = len(valuation.Categories)
+				valuation.Categories = append(valuation.Categories, ValuationTotal{})
+			}
+			category = &valuation.Categories[uncategorized]
+		} else {
+			i, ok := categories[*line.CategoryID]
+			if !ok {
+				i = len(valuation.Categories)
+				categories[*line.CategoryID] = i
+				valuation.Categories = append(valuation.Categories, ValuationTotal{ID: line.CategoryID, Name: line.Category})
+			}
+			category = &valuation.Categories[i]
+		}
+		category.Quantity += line.Quantity
+		category.Value += line.Value
+
+		i, ok := warehouses[line.WarehouseID]
+		if !ok {
+			i = len(valuation.Warehouses)
+			warehouses[line.WarehouseID] = i
+			id := line.WarehouseID
+			valuation.Warehouses = append(valuation.Warehouses, ValuationTotal{ID: &id, Name: line.WarehouseName})
+		}
+		valuation.Warehouses[i].Quantity += line.Quantity
+		valuation.Warehouses[i].Value += line.Value
+	}
+
+	sort.Slice(valuation.Lines, func(i, j int) bool {
+		a, b := valuation.Lines[i], valuation.Lines[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		return a.WarehouseID < b.WarehouseID
+	})
+	byID := func(totals []ValuationTotal) func(i, j int) bool {
+		return func(i, j int) bool {
+			if totals[i].ID == nil || totals[j].ID == nil {
+				return totals[i].ID == nil && totals[j].ID != nil
+			}
+			return *totals[i].ID < *totals[j].ID
+		}
+	}
+	sort.Slice(valuation.Categories, byID(valuation.Categories))
+	sort.Slice(valuation.Warehouses, byID(valuation.Warehouses))
+	return valuation, nil
+}
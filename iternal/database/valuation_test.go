@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestValuedStockTake(t *testing.T) {
+	tests := []struct {
+		name       string
+		layers     []costLayer
+		take       int64
+		wantTaken  []costLayer
+		wantLayers []costLayer
+		wantShort  int64
+	}{
+		{name: "part of the oldest layer", layers: []costLayer{{quantity: 5, cost: 2}, {quantity: 5, cost: 3}}, take: 3,
+			wantTaken:  []costLayer{{quantity: 3, cost: 2}},
+			wantLayers: []costLayer{{quantity: 2, cost: 2}, {quantity: 5, cost: 3}}},
+		{name: "across layers", layers: []costLayer{{quantity: 5, cost: 2}, {quantity: 5, cost: 3}}, take: 7,
+			wantTaken:  []costLayer{{quantity: 5, cost: 2}, {quantity: 2, cost: 3}},
+			wantLayers: []costLayer{{quantity: 3, cost: 3}}},
+		{name: "everything on hand", layers: []costLayer{{quantity: 5, cost: 2}}, take: 5,
+			wantTaken:  []costLayer{{quantity: 5, cost: 2}},
+			wantLayers: []costLayer{}},
+		{name: "more than on hand", layers: []costLayer{{quantity: 3, cost: 4}}, take: 5,
+			wantTaken:  []costLayer{{quantity: 3, cost: 4}, {quantity: 2, cost: 4}},
+			wantLayers: []costLayer{}, wantShort: 2},
+		{name: "nothing ever costed", layers: nil, take: 2,
+			wantTaken:  []costLayer{{quantity: 2, uncosted: true}},
+			wantLayers: nil, wantShort: 2},
+		{name: "uncosted layer", layers: []costLayer{{quantity: 2, uncosted: true}}, take: 2,
+			wantTaken:  []costLayer{{quantity: 2, uncosted: true}},
+			wantLayers: []costLayer{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s valuedStock
+			s.add(tt.layers, false)
+			taken := s.take(tt.take)
+			if !reflect.DeepEqual(taken, tt.wantTaken) {
+				t.Errorf("take(%d) = %+v, want %+v", tt.take, taken, tt.wantTaken)
+			}
+			if len(s.layers) != len(tt.wantLayers) || (len(s.layers) > 0 && !reflect.DeepEqual(s.layers, tt.wantLayers)) {
+				t.Errorf("layers = %+v, want %+v", s.layers, tt.wantLayers)
+			}
+			if s.short != tt.wantShort {
+				t.Errorf("short = %d, want %d", s.short, tt.wantShort)
+			}
+		})
+	}
+}
+
+func TestValuedStockAddCoversShort(t *testing.T) {
+	var s valuedStock
+	s.add([]costLayer{{quantity: 3, cost: 4}}, false)
+	s.take(5)
+
+	s.add([]costLayer{{quantity: 1, cost: 6}}, false)
+	if s.short != 1 || len(s.layers) != 0 {
+		t.Fatalf("after covering part of the shortage: short = %d, layers = %+v, want 1 and none", s.short, s.layers)
+	}
+	s.add([]costLayer{{quantity: 4, cost: 6}}, false)
+	want := []costLayer{{quantity: 3, cost: 6}}
+	if s.short != 0 || !reflect.DeepEqual(s.layers, want) {
+		t.Fatalf("short = %d, layers = %+v, want 0 and %+v", s.short, s.layers, want)
+	}
+	if s.lastCost != 6 || !s.known {
+		t.Errorf("lastCost = %v, known = %v, want 6 and true", s.lastCost, s.known)
+	}
+}
+
+func TestValuedStockWeightedAverage(t *testing.T) {
+	var s valuedStock
+	s.add([]costLayer{{quantity: 10, cost: 2}}, true)
+	s.add([]costLayer{{quantity: 10, cost: 4}}, true)
+	if len(s.layers) != 1 || s.layers[0].quantity != 20 || s.layers[0].cost != 3 {
+		t.Fatalf("after two receipts: layers = %+v, want 20 at 3", s.layers)
+	}
+
+	taken := s.take(5)
+	if want := []costLayer{{quantity: 5, cost: 3}}; !reflect.DeepEqual(taken, want) {
+		t.Fatalf("take(5) = %+v, want %+v", taken, want)
+	}
+
+	s.add([]costLayer{{quantity: 5, cost: 7}}, true)
+	s.take(8)
+	s.add([]costLayer{{quantity: 8, cost: 1}}, true)
+
+	// 15 at 3 and 5 at 7 average 4; 12 left at 4 and 8 at 1 average 2.8.
+	if len(s.layers) != 1 || s.layers[0].quantity != 20 || math.Abs(s.layers[0].cost-2.8) > 1e-9 {
+		t.Fatalf("layers = %+v, want 20 at 2.8", s.layers)
+	}
+	if s.layers[0].uncosted {
+		t.Error("the merged layer is uncosted, want costed")
+	}
+
+	s.add([]costLayer{{quantity: 20, uncosted: true}}, true)
+	if len(s.layers) != 1 || s.layers[0].quantity != 40 || math.Abs(s.layers[0].cost-1.4) > 1e-9 || !s.layers[0].uncosted {
+		t.Fatalf("after an uncosted receipt: layers = %+v, want 40 at 1.4, uncosted", s.layers)
+	}
+}
+
+func TestValuationReplayTransfer(t *testing.T) {
+	movement := func(warehouseID, change int64, movementType string, referenceID int64) valuationMovement {
+		m := valuationMovement{
+			line:         ValuationLine{ProductID: 1, WarehouseID: warehouseID},
+			change:       change,
+			movementType: movementType,
+			referenceID:  sql.NullInt64{Int64: referenceID, Valid: referenceID != 0},
+			fallbackCost: sql.NullFloat64{Float64: 9, Valid: true},
+		}
+		if movementType == MovementReceipt {
+			m.unitCost = sql.NullFloat64{Float64: float64(referenceID), Valid: true}
+		}
+		return m
+	}
+
+	for _, average := range []bool{false, true} {
+		replay := newValuationReplay(average)
+		replay.apply(movement(1, 10, MovementReceipt, 2))
+		replay.apply(movement(1, 10, MovementReceipt, 4))
+		replay.apply(movement(1, -12, MovementTransfer, 70))
+		replay.apply(movement(1, -3, MovementTransfer, 71))
+		replay.apply(movement(3, 3, MovementTransfer, 71))
+		replay.apply(movement(2, 12, MovementTransfer, 70))
+		replay.apply(movement(2, 4, MovementTransfer, 72))
+
+		source := replay.stocks[stockKey{1, 1}]
+		destination := replay.stocks[stockKey{1, 2}]
+		other := replay.stocks[stockKey{1, 3}]
+		want := map[bool]struct{ source, destination, other []costLayer }{
+			false: {
+				source:      []costLayer{{quantity: 5, cost: 4}},
+				destination: []costLayer{{quantity: 10, cost: 2}, {quantity: 2, cost: 4}, {quantity: 4, cost: 9}},
+				other:       []costLayer{{quantity: 3, cost: 4}},
+			},
+			true: {
+				source:      []costLayer{{quantity: 5, cost: 3}},
+				destination: []costLayer{{quantity: 16, cost: 4.5}},
+				other:       []costLayer{{quantity: 3, cost: 3}},
+			},
+		}[average]
+
+		if !reflect.DeepEqual(source.layers, want.source) {
+			t.Errorf("average %v: source layers = %+v, want %+v", average, source.layers, want.source)
+		}
+		if !reflect.DeepEqual(destination.layers, want.destination) {
+			t.Errorf("average %v: destination layers = %+v, want %+v", average, destination.layers, want.destination)
+		}
+		if !reflect.DeepEqual(other.layers, want.other) {
+			t.Errorf("average %v: other layers = %+v, want %+v", average, other.layers, want.other)
+		}
+		if len(replay.transfers) != 0 {
+			t.Errorf("average %v: transfers left waiting = %+v, want none", average, replay.transfers)
+		}
+		if want := []stockKey{{1, 1}, {1, 3}, {1, 2}}; !reflect.DeepEqual(replay.order, want) {
+			t.Errorf("average %v: order = %+v, want %+v", average, replay.order, want)
+		}
+	}
+}
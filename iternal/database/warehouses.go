@@ -0,0 +1,448 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Warehouse is a stock location. Stock that arrives without a warehouse,
+// such as the opening quantity of a new product, goes to the default one.
+// Latitude and Longitude are used to allocate orders to the nearest
+// warehouse.
+type Warehouse struct {
+	WarehouseID   int64     `json:"warehouse_id"`
+	Name          string    `json:"name"`
+	Address       string    `json:"address"`
+	Latitude      *float64  `json:"latitude"`
+	Longitude     *float64  `json:"longitude"`
+	IsDefault     bool      `json:"is_default"`
+	TotalQuantity int64     `json:"total_quantity"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// WarehouseStock is the stock of one product in one warehouse.
+type WarehouseStock struct {
+	WarehouseID   int64     `json:"warehouse_id"`
+	WarehouseName string    `json:"warehouse_name"`
+	ProductID     int64     `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Quantity      int64     `json:"quantity"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+var ErrNoWarehouseFound error = &Error{Kind: ErrNotFound, Message: "no warehouse found with the provided ID"}
+var ErrNoDefaultWarehouse error = &Error{Kind: ErrConflict, Message: "no default warehouse is set"}
+
+func errNotEnoughInWarehouse(warehouseID int64) error {
+	return &Error{Kind: ErrInsufficientStock, Message: fmt.Sprintf("not enough product in stock in warehouse %d", warehouseID)}
+}
+
+var errNoWarehouseWithStock error = &Error{Kind: ErrInsufficientStock, Message: "no single warehouse has that amount of product in stock"}
+
+func errCountWarehouseRequired(productID int64) error {
+	return &Error{Kind: ErrInvalidArgument, Message: fmt.Sprintf("product %d is in stock in several warehouses, a warehouse_id is required to count its stock", productID)}
+}
+
+func (db *Database) AddWarehouse(name, address string, latitude, longitude *float64) (int64, error) {
+	query, err := os.ReadFile(warehousesPath + "add_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database AddWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	latitudeNull := sql.NullFloat64{Valid: latitude != nil}
+	if latitude != nil {
+		latitudeNull.Float64 = *latitude
+	}
+	longitudeNull := sql.NullFloat64{Valid: longitude != nil}
+	if longitude != nil {
+		longitudeNull.Float64 = *longitude
+	}
+
+	var warehouseID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		return tx.QueryRow(string(query), name, address, latitudeNull, longitudeNull).Scan(&warehouseID)
+	})
+	if err != nil && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database AddWarehouse()", slog.String("error", err.Error()))
+	}
+	return warehouseID, err
+}
+
+// UpdateWarehouse changes the given fields of a warehouse. With isDefault
+// the warehouse replaces the current default warehouse.
+func (db *Database) UpdateWarehouse(warehouseID int64, name, address *string, latitude, longitude *float64, isDefault bool) error {
+	query, err := os.ReadFile(warehousesPath + "set_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	defaultQuery, err := os.ReadFile(warehousesPath + "default_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database UpdateWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return err
+	}
+
+	nameNull := sql.NullString{Valid: name != nil && *name != ""}
+	if nameNull.Valid {
+		nameNull.String = *name
+	}
+	addressNull := sql.NullString{Valid: address != nil}
+	if addressNull.Valid {
+		addressNull.String = *address
+	}
+	latitudeNull := sql.NullFloat64{Valid: latitude != nil}
+	if latitude != nil {
+		latitudeNull.Float64 = *latitude
+	}
+	longitudeNull := sql.NullFloat64{Valid: longitude != nil}
+	if longitude != nil {
+		longitudeNull.Float64 = *longitude
+	}
+
+	err = db.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(string(query), warehouseID, nameNull, addressNull, latitudeNull, longitudeNull)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, ErrNoWarehouseFound); err != nil {
+			return err
+		}
+		if isDefault {
+			_, err = tx.Exec(string(defaultQuery), warehouseID)
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database UpdateWarehouse()", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+func (db *Database) ShowWarehouses() ([]Warehouse, error) {
+	query, err := os.ReadFile(warehousesPath + "show_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database ShowWarehouses() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	rows, err := db.Query(string(query))
+	if err != nil {
+		db.Log.Error("Database ShowWarehouses() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var warehouses []Warehouse
+	for rows.Next() {
+		var w Warehouse
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&w.WarehouseID, &w.Name, &w.Address, &latitude, &longitude, &w.IsDefault,
+			&w.TotalQuantity, &w.CreatedAt, &w.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowWarehouses() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		if latitude.Valid {
+			w.Latitude = &latitude.Float64
+		}
+		if longitude.Valid {
+			w.Longitude = &longitude.Float64
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowWarehouses() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+// ShowWarehouseStock lists the stock levels per warehouse and product,
+// optionally of a single warehouse or product. Empty stock is left out.
+func (db *Database) ShowWarehouseStock(warehouseID, productID *int64) ([]WarehouseStock, error) {
+	query, err := os.ReadFile(warehousesPath + "stock_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database ShowWarehouseStock() -> Read SQL file", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	warehouseNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		warehouseNull.Int64 = *warehouseID
+	}
+	productNull := sql.NullInt64{Valid: productID != nil}
+	if productID != nil {
+		productNull.Int64 = *productID
+	}
+
+	rows, err := db.Query(string(query), warehouseNull, productNull)
+	if err != nil {
+		db.Log.Error("Database ShowWarehouseStock() -> db.Query()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			db.Log.Error("Some troubles after rows.Close()", slog.String("error", err.Error()))
+		}
+	}()
+
+	var stock []WarehouseStock
+	for rows.Next() {
+		var s WarehouseStock
+		if err := rows.Scan(&s.WarehouseID, &s.WarehouseName, &s.ProductID, &s.ProductName, &s.Quantity, &s.UpdatedAt); err != nil {
+			db.Log.Error("Database ShowWarehouseStock() -> parsing rows", slog.String("error", err.Error()))
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.Log.Error("Database ShowWarehouseStock() -> rows.Err()", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return stock, nil
+}
+
+// resolveWarehouse returns warehouseID if that warehouse exists, or the
+// default warehouse if warehouseID is nil.
+func (db *Database) resolveWarehouse(tx *sql.Tx, warehouseID *int64) (int64, error) {
+	query, err := os.ReadFile(warehousesPath + "resolve_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database resolveWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	idNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		idNull.Int64 = *warehouseID
+	}
+
+	var id int64
+	err = tx.QueryRow(string(query), idNull).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if warehouseID == nil {
+			return 0, ErrNoDefaultWarehouse
+		}
+		return 0, ErrNoWarehouseFound
+	}
+	return id, err
+}
+
+// countWarehouse returns the warehouse a stock count of a product is booked
+// in: warehouseID if given, otherwise the only warehouse that has the
+// product in stock, or the default warehouse if none has. A product in stock
+// in several warehouses needs warehouseID.
+func (db *Database) countWarehouse(tx *sql.Tx, productID int64, warehouseID *int64) (int64, error) {
+	if warehouseID != nil {
+		return db.resolveWarehouse(tx, warehouseID)
+	}
+
+	query, err := os.ReadFile(warehousesPath + "stocked_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database countWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	rows, err := tx.Query(string(query), productID)
+	if err != nil {
+		return 0, err
+	}
+	var stocked []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stocked = append(stocked, id)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	switch len(stocked) {
+	case 0:
+		return db.resolveWarehouse(tx, nil)
+	case 1:
+		return stocked[0], nil
+	default:
+		return 0, errCountWarehouseRequired(productID)
+	}
+}
+
+// allocateWarehouse picks the warehouse an order line is shipped from and
+// locks its stock row. With warehouseID only that warehouse is considered.
+// Otherwise, among the warehouses that have the whole quantity in stock, the
+// one nearest to the delivery location wins; without a location, or for
+// warehouses without coordinates, the default warehouse is preferred and
+// then the one with the most stock. Expired lots and stock held by active
+// reservations do not count as stock.
+func (db *Database) allocateWarehouse(tx *sql.Tx, productID, quantity int64, warehouseID *int64, latitude, longitude *float64) (int64, error) {
+	query, err := os.ReadFile(warehousesPath + "allocate_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database allocateWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	availableQuery, err := os.ReadFile(reservationsPath + "available_reservations.sql")
+	if err != nil {
+		db.Log.Error("Database allocateWarehouse() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	if warehouseID != nil {
+		if _, err := db.resolveWarehouse(tx, warehouseID); err != nil {
+			return 0, err
+		}
+	}
+
+	idNull := sql.NullInt64{Valid: warehouseID != nil}
+	if warehouseID != nil {
+		idNull.Int64 = *warehouseID
+	}
+	latitudeNull := sql.NullFloat64{Valid: latitude != nil && longitude != nil}
+	longitudeNull := sql.NullFloat64{Valid: latitudeNull.Valid}
+	if latitudeNull.Valid {
+		latitudeNull.Float64 = *latitude
+		longitudeNull.Float64 = *longitude
+	}
+
+	var id int64
+	err = tx.QueryRow(string(query), productID, quantity, idNull, latitudeNull, longitudeNull).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if warehouseID != nil {
+			return 0, errNotEnoughInWarehouse(*warehouseID)
+		}
+		return 0, errNoWarehouseWithStock
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// The stock row is locked now, but a reservation committed while waiting
+	// for the lock was not seen by the query above.
+	var available int64
+	if err := tx.QueryRow(string(availableQuery), id, productID).Scan(&available); err != nil {
+		return 0, err
+	}
+	if available < quantity {
+		return 0, errNotEnoughInWarehouse(id)
+	}
+	return id, nil
+}
+
+// TransferStock moves quantity units of a product from one warehouse to
+// another in one transaction. The transfer is recorded as two ledger
+// movements that cancel out, so the product quantity does not change. Lots
+// taken from the source warehouse keep their number and expiry date in the
+// destination. The units leave the source bins as takeBins decides and
+// arrive unbinned. Stock held by active reservations stays where it is.
+// Products tracked by serial number need the serials of the units that move.
+func (db *Database) TransferStock(productID, fromWarehouseID, toWarehouseID, quantity int64, serials []string, reason, user string) (int64, error) {
+	removeQuery, err := os.ReadFile(warehousesPath + "stock_remove_warehouses.sql")
+	if err != nil {
+		db.Log.Error("Database TransferStock() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	transferQuery, err := os.ReadFile(warehousesPath + "add_stock_transfers.sql")
+	if err != nil {
+		db.Log.Error("Database TransferStock() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	lotQuery, err := os.ReadFile(lotsPath + "add_lots.sql")
+	if err != nil {
+		db.Log.Error("Database TransferStock() -> Read SQL file", slog.String("error", err.Error()))
+		return 0, err
+	}
+
+	var transferID int64
+	err = db.WithTx(func(tx *sql.Tx) error {
+		for _, id := range []int64{fromWarehouseID, toWarehouseID} {
+			if _, err := db.resolveWarehouse(tx, &id); err != nil {
+				return err
+			}
+		}
+
+		if err := db.requireUnreserved(tx, productID, fromWarehouseID, quantity); err != nil {
+			return err
+		}
+
+		takes, err := db.takeLots(tx, productID, fromWarehouseID, quantity, false)
+		if err != nil {
+			return err
+		}
+		for _, take := range takes {
+			if _, err := tx.Exec(string(lotQuery), productID, toWarehouseID, take.lotNumber, take.expiresAt, take.quantity); err != nil {
+				return err
+			}
+		}
+
+		if err := db.takeBins(tx, productID, fromWarehouseID, quantity); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(string(removeQuery), fromWarehouseID, productID, quantity)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, errNotEnoughInWarehouse(fromWarehouseID)); err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow(string(transferQuery), productID, fromWarehouseID, toWarehouseID, quantity, reason, user).Scan(&transferID); err != nil {
+			return err
+		}
+
+		if err := db.moveSerials(tx, productID, fromWarehouseID, quantity, serials, nil, SerialEvent{
+			Type:          MovementTransfer,
+			Status:        SerialInStock,
+			WarehouseID:   &toWarehouseID,
+			ReferenceType: "stock_transfer",
+			ReferenceID:   &transferID,
+			CreatedBy:     user,
+		}); err != nil {
+			return err
+		}
+
+		movement := StockMovement{
+			ProductID:     productID,
+			Type:          MovementTransfer,
+			Reason:        reason,
+			ReferenceType: "stock_transfer",
+			ReferenceID:   &transferID,
+			CreatedBy:     user,
+		}
+
+		out := movement
+		out.WarehouseID = fromWarehouseID
+		out.QuantityChange = -quantity
+		if err := db.recordMovement(tx, out); err != nil {
+			return err
+		}
+
+		in := movement
+		in.WarehouseID = toWarehouseID
+		in.QuantityChange = quantity
+		return db.placeStock(tx, in)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrConflict) {
+		db.Log.Error("Database TransferStock()", slog.String("error", err.Error()))
+	}
+	return transferID, err
+}
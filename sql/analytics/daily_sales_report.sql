@@ -0,0 +1,17 @@
+WITH sales AS (
+    SELECT od.product_id, o.created_at::DATE AS day, SUM(od.quantity) AS quantity
+    FROM order_details od
+    JOIN orders o ON o.order_id = od.order_id
+    WHERE o.status NOT IN ('refunded', 'cancelled')
+      AND o.created_at >= CURRENT_DATE - $2::INT
+      AND o.created_at < CURRENT_DATE
+      AND ($1::INT IS NULL OR od.product_id = $1)
+    GROUP BY od.product_id, o.created_at::DATE
+)
+SELECT p.product_id, p.name, d.day::DATE, COALESCE(s.quantity, 0)
+FROM products p
+CROSS JOIN generate_series(CURRENT_DATE - $2::INT, CURRENT_DATE - 1, INTERVAL '1 day') AS d(day)
+LEFT JOIN sales s ON s.product_id = p.product_id AND s.day = d.day::DATE
+WHERE p.product_id IN (SELECT product_id FROM sales)
+   OR (p.product_id = $1 AND p.deleted_at IS NULL)
+ORDER BY p.product_id, d.day;
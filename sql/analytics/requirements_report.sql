@@ -1,3 +1,7 @@
-SELECT product_id, avg(quantity) as avg_sold
-FROM order_details
-GROUP BY product_id;
\ No newline at end of file
+SELECT od.product_id, avg(od.quantity) as avg_sold,
+       COALESCE(sum(od.quantity) FILTER (
+           WHERE o.status NOT IN ('refunded', 'cancelled') AND o.created_at >= CURRENT_TIMESTAMP - make_interval(days => $1::INT)
+       ), 0)::NUMERIC / $1 as avg_daily_sold
+FROM order_details od
+JOIN orders o ON o.order_id = od.order_id
+GROUP BY od.product_id;
@@ -0,0 +1,23 @@
+SELECT
+    cu.customer_id,
+    cu.name,
+    COALESCE(SUM(l.quantity), 0) AS units,
+    COALESCE(SUM(l.price * l.quantity), 0) AS total_sales,
+    SUM(l.unit_cost * l.quantity) AS total_cost,
+    SUM((l.price - l.unit_cost) * l.quantity) AS margin,
+    ROUND(SUM((l.price - l.unit_cost) * l.quantity) * 100
+        / NULLIF(SUM(CASE WHEN l.unit_cost IS NOT NULL THEN l.price * l.quantity END), 0), 2) AS margin_percent
+FROM
+    sales_lines l
+        JOIN
+    products p ON p.product_id = l.product_id
+        JOIN
+    customers cu ON cu.customer_id = l.customer_id
+WHERE
+    ($1::TIMESTAMP IS NULL OR l.created_at >= $1)
+    AND ($2::TIMESTAMP IS NULL OR l.created_at < $2)
+    AND ($3::BOOLEAN OR (p.deleted_at IS NULL AND cu.deleted_at IS NULL))
+GROUP BY
+    cu.customer_id, cu.name
+ORDER BY
+    cu.customer_id;
@@ -1,10 +1,21 @@
 SELECT
     p.product_id,
     p.name,
-    COALESCE(SUM(od.price * od.quantity), 0) AS total_sales
+    COALESCE(SUM(l.quantity), 0) AS units,
+    COALESCE(SUM(l.price * l.quantity), 0) AS total_sales,
+    SUM(l.unit_cost * l.quantity) AS total_cost,
+    SUM((l.price - l.unit_cost) * l.quantity) AS margin,
+    ROUND(SUM((l.price - l.unit_cost) * l.quantity) * 100
+        / NULLIF(SUM(CASE WHEN l.unit_cost IS NOT NULL THEN l.price * l.quantity END), 0), 2) AS margin_percent
 FROM
     products p
         LEFT JOIN
-    order_details od ON p.product_id = od.product_id
+    sales_lines l ON p.product_id = l.product_id
+        AND ($1::TIMESTAMP IS NULL OR l.created_at >= $1)
+        AND ($2::TIMESTAMP IS NULL OR l.created_at < $2)
+WHERE
+    $3::BOOLEAN OR p.deleted_at IS NULL
 GROUP BY
-    p.product_id, p.name;
\ No newline at end of file
+    p.product_id, p.name
+ORDER BY
+    p.product_id;
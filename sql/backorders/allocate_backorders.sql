@@ -0,0 +1,10 @@
+WITH allocated AS (
+    UPDATE order_details
+    SET warehouse_id = $3,
+        backordered = FALSE
+    WHERE order_detail_id = $2
+)
+UPDATE orders
+SET status = 'new',
+    updated_at = CURRENT_TIMESTAMP
+WHERE order_id = $1;
@@ -0,0 +1,7 @@
+SELECT o.order_id, od.order_detail_id, od.quantity
+FROM orders o
+JOIN order_details od ON od.order_id = o.order_id
+WHERE o.status = 'backordered' AND od.backordered AND od.product_id = $1
+  AND (od.warehouse_id IS NULL OR od.warehouse_id = $2)
+ORDER BY o.created_at, o.order_id
+FOR UPDATE OF o, od;
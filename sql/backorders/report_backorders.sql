@@ -0,0 +1,32 @@
+WITH reserved AS (
+    SELECT r.product_id, SUM(r.quantity) AS quantity
+    FROM reservations r
+    WHERE r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+    GROUP BY r.product_id
+), on_order AS (
+    SELECT pol.product_id, SUM(GREATEST(pol.quantity - pol.received_quantity, 0)) AS quantity
+    FROM purchase_order_lines pol
+    JOIN purchase_orders po ON po.purchase_order_id = pol.purchase_order_id
+    WHERE po.status IN ('sent', 'partially_received')
+    GROUP BY pol.product_id
+), backorders AS (
+    SELECT o.order_id, od.order_detail_id, o.customer_id, c.name AS customer_name, od.product_id, p.name AS product_name,
+           od.warehouse_id, od.quantity, od.price, o.created_at,
+           SUM(od.quantity) OVER (PARTITION BY od.product_id ORDER BY o.created_at, o.order_id
+               ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING) AS ahead,
+           GREATEST(p.quantity - COALESCE(rs.quantity, 0), 0) AS available,
+           COALESCE(oo.quantity, 0) AS on_order
+    FROM orders o
+    JOIN order_details od ON od.order_id = o.order_id
+    JOIN customers c ON c.customer_id = o.customer_id
+    JOIN products p ON p.product_id = od.product_id
+    LEFT JOIN reserved rs ON rs.product_id = od.product_id
+    LEFT JOIN on_order oo ON oo.product_id = od.product_id
+    WHERE o.status = 'backordered' AND od.backordered
+)
+SELECT order_id, order_detail_id, customer_id, customer_name, product_id, product_name, warehouse_id, quantity, price, created_at,
+       COALESCE(ahead, 0), available, on_order
+FROM backorders
+WHERE ($1::INT IS NULL OR product_id = $1)
+  AND ($2::INT IS NULL OR customer_id = $2)
+ORDER BY product_id, created_at, order_id;
@@ -0,0 +1,2 @@
+INSERT INTO bin_locations (warehouse_id, zone, aisle, shelf, bin, pick_sequence, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING bin_location_id;
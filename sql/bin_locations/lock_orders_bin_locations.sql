@@ -0,0 +1 @@
+SELECT status, picked_at FROM orders WHERE order_id = $1 FOR UPDATE;
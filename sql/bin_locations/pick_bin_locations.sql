@@ -0,0 +1,8 @@
+SELECT bs.bin_location_id,
+       CONCAT_WS('-', NULLIF(b.zone, ''), NULLIF(b.aisle, ''), NULLIF(b.shelf, ''), NULLIF(b.bin, '')) AS code,
+       b.zone, b.aisle, b.shelf, b.bin, b.pick_sequence, bs.quantity
+FROM bin_stock bs
+JOIN bin_locations b ON b.bin_location_id = bs.bin_location_id
+WHERE b.warehouse_id = $1 AND bs.product_id = $2 AND bs.quantity > 0
+ORDER BY b.pick_sequence, b.zone, b.aisle, b.shelf, b.bin, b.bin_location_id
+FOR UPDATE OF bs;
@@ -0,0 +1,4 @@
+UPDATE orders
+SET picked_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE order_id = $1 AND picked_at IS NULL;
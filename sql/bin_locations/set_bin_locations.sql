@@ -0,0 +1,8 @@
+UPDATE bin_locations
+SET zone = COALESCE($2, zone),
+    aisle = COALESCE($3, aisle),
+    shelf = COALESCE($4, shelf),
+    bin = COALESCE($5, bin),
+    pick_sequence = COALESCE($6, pick_sequence),
+    updated_at = CURRENT_TIMESTAMP
+WHERE bin_location_id = $1;
@@ -0,0 +1,9 @@
+SELECT b.bin_location_id, b.warehouse_id, w.name,
+       CONCAT_WS('-', NULLIF(b.zone, ''), NULLIF(b.aisle, ''), NULLIF(b.shelf, ''), NULLIF(b.bin, '')) AS code,
+       b.zone, b.aisle, b.shelf, b.bin, b.pick_sequence,
+       COALESCE((SELECT SUM(bs.quantity) FROM bin_stock bs WHERE bs.bin_location_id = b.bin_location_id), 0) AS total_quantity,
+       b.created_at, b.updated_at
+FROM bin_locations b
+JOIN warehouses w ON w.warehouse_id = b.warehouse_id
+WHERE ($1::INT IS NULL OR b.warehouse_id = $1)
+ORDER BY b.warehouse_id, b.pick_sequence, b.zone, b.aisle, b.shelf, b.bin, b.bin_location_id;
@@ -0,0 +1,5 @@
+INSERT INTO bin_stock (bin_location_id, product_id, quantity, updated_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+ON CONFLICT (bin_location_id, product_id) DO UPDATE
+SET quantity = bin_stock.quantity + EXCLUDED.quantity,
+    updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,11 @@
+SELECT bs.bin_location_id, b.warehouse_id,
+       CONCAT_WS('-', NULLIF(b.zone, ''), NULLIF(b.aisle, ''), NULLIF(b.shelf, ''), NULLIF(b.bin, '')) AS code,
+       bs.product_id, p.name, bs.quantity, bs.updated_at
+FROM bin_stock bs
+JOIN bin_locations b ON b.bin_location_id = bs.bin_location_id
+JOIN products p ON p.product_id = bs.product_id
+WHERE ($1::INT IS NULL OR b.warehouse_id = $1)
+  AND ($2::INT IS NULL OR bs.product_id = $2)
+  AND ($3::INT IS NULL OR bs.bin_location_id = $3)
+  AND bs.quantity > 0
+ORDER BY b.warehouse_id, b.pick_sequence, b.zone, b.aisle, b.shelf, b.bin, b.bin_location_id, bs.product_id;
@@ -0,0 +1,4 @@
+UPDATE bin_stock
+SET quantity = quantity - $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE bin_location_id = $1 AND product_id = $2 AND quantity >= $3;
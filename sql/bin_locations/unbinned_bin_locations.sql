@@ -0,0 +1,9 @@
+SELECT ws.quantity - COALESCE((
+           SELECT SUM(bs.quantity)
+           FROM bin_stock bs
+           JOIN bin_locations b ON b.bin_location_id = bs.bin_location_id
+           WHERE b.warehouse_id = ws.warehouse_id AND bs.product_id = ws.product_id
+       ), 0)
+FROM warehouse_stock ws
+WHERE ws.warehouse_id = $1 AND ws.product_id = $2
+FOR UPDATE OF ws;
@@ -0,0 +1 @@
+SELECT warehouse_id FROM bin_locations WHERE bin_location_id = $1;
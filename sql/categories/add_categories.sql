@@ -0,0 +1,2 @@
+INSERT INTO categories (parent_id, name, created_at, updated_at)
+VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING category_id;
@@ -0,0 +1,14 @@
+WITH RECURSIVE tree AS (
+    SELECT category_id
+    FROM categories
+    WHERE category_id = $1
+    UNION
+    SELECT c.category_id
+    FROM categories c
+    JOIN tree t ON c.parent_id = t.category_id
+)
+SELECT EXISTS (
+    SELECT 1
+    FROM tree
+    WHERE category_id = $2
+);
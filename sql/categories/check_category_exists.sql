@@ -0,0 +1,5 @@
+SELECT EXISTS (
+    SELECT 1
+    FROM categories
+    WHERE category_id = $1
+);
@@ -0,0 +1,10 @@
+UPDATE categories
+SET
+    name = COALESCE($2, name),
+    parent_id = CASE
+        WHEN $3::INT IS NULL THEN parent_id
+        WHEN $3 = 0 THEN NULL
+        ELSE $3
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE category_id = $1;
@@ -0,0 +1,13 @@
+WITH RECURSIVE tree AS (
+    SELECT category_id, parent_id, name, name::TEXT AS path, 0 AS depth, created_at, updated_at
+    FROM categories
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.category_id, c.parent_id, c.name, t.path || ' / ' || c.name, t.depth + 1, c.created_at, c.updated_at
+    FROM categories c
+    JOIN tree t ON c.parent_id = t.category_id
+)
+SELECT category_id, parent_id, name, path, depth, created_at, updated_at
+FROM tree
+ORDER BY path
+LIMIT COALESCE($1, 1000);
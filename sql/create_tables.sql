@@ -1,9 +1,10 @@
 CREATE TABLE IF NOT EXISTS customers (
     customer_id INT GENERATED ALWAYS AS IDENTITY,
     name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL UNIQUE,
-    phone VARCHAR(15) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
+    phone VARCHAR(15) NOT NULL,
     address VARCHAR(255) NOT NULL,
+    deleted_at TIMESTAMP,
     PRIMARY KEY(customer_id)
 );
 CREATE TABLE IF NOT EXISTS suppliers
@@ -11,24 +12,46 @@ CREATE TABLE IF NOT EXISTS suppliers
     supplier_id   INT GENERATED ALWAYS AS IDENTITY,
     name          VARCHAR(255) NOT NULL,
     contact_name  VARCHAR(255) NOT NULL,
-    contact_email VARCHAR(255) NOT NULL UNIQUE,
-    contact_phone VARCHAR(15)  NOT NULL UNIQUE,
+    contact_email VARCHAR(255) NOT NULL,
+    contact_phone VARCHAR(15)  NOT NULL,
+    deleted_at    TIMESTAMP,
     PRIMARY KEY (supplier_id)
 );
+CREATE TABLE IF NOT EXISTS categories (
+    category_id INT GENERATED ALWAYS AS IDENTITY,
+    parent_id INT,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(category_id),
+    CONSTRAINT fk_parent_category
+        FOREIGN KEY(parent_id)
+            REFERENCES categories(category_id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS categories_name_key ON categories (COALESCE(parent_id, 0), LOWER(name));
 CREATE TABLE IF NOT EXISTS products (
     product_id INT GENERATED ALWAYS AS IDENTITY,
     supplier_id INT NOT NULL,
-    name VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
     description TEXT NOT NULL,
     price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
     quantity INTEGER NOT NULL CHECK (quantity >= 0),
-    category VARCHAR(255) NOT NULL,
+    category VARCHAR(255),
+    category_id INT,
+    sku VARCHAR(64),
+    barcode VARCHAR(14) CHECK (barcode ~ '^[0-9]{8,14}$'),
+    unit_of_measure VARCHAR(16) NOT NULL DEFAULT 'pcs',
+    pack_size INTEGER NOT NULL DEFAULT 1 CHECK (pack_size > 0),
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
     PRIMARY KEY(product_id),
     CONSTRAINT fk_suppliers
         FOREIGN KEY(supplier_id)
-            REFERENCES suppliers(supplier_id)
+            REFERENCES suppliers(supplier_id),
+    CONSTRAINT fk_categories
+        FOREIGN KEY(category_id)
+            REFERENCES categories(category_id)
 );
 CREATE TABLE IF NOT EXISTS orders (
     order_id INT GENERATED ALWAYS AS IDENTITY,
@@ -61,4 +84,397 @@ CREATE TABLE IF NOT EXISTS trusted_users (
     password VARCHAR(255) NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     last_activity TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE suppliers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS barcode VARCHAR(14) CHECK (barcode ~ '^[0-9]{8,14}$');
+ALTER TABLE products ADD COLUMN IF NOT EXISTS unit_of_measure VARCHAR(16) NOT NULL DEFAULT 'pcs';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS pack_size INTEGER NOT NULL DEFAULT 1 CHECK (pack_size > 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INT CONSTRAINT fk_categories REFERENCES categories(category_id);
+ALTER TABLE products ALTER COLUMN category DROP NOT NULL;
+INSERT INTO categories (name)
+SELECT DISTINCT ON (LOWER(TRIM(category))) TRIM(category)
+FROM products
+WHERE category_id IS NULL AND TRIM(category) <> ''
+ORDER BY LOWER(TRIM(category)), category
+ON CONFLICT DO NOTHING;
+UPDATE products p
+SET category_id = c.category_id
+FROM categories c
+WHERE p.category_id IS NULL AND c.parent_id IS NULL AND LOWER(c.name) = LOWER(TRIM(p.category));
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS products_search_idx ON products USING GIN ((
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', COALESCE(sku, '')), 'A') ||
+    setweight(to_tsvector('english', description), 'C')
+));
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS products_sku_trgm_idx ON products USING GIN (sku gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS categories_name_trgm_idx ON categories USING GIN (name gin_trgm_ops);
+CREATE TABLE IF NOT EXISTS product_prices (
+    price_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    effective_from TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(price_id),
+    CONSTRAINT fk_product_prices
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS product_prices_product_idx ON product_prices (product_id, effective_from);
+CREATE INDEX IF NOT EXISTS product_prices_pending_idx ON product_prices (effective_from) WHERE applied_at IS NULL;
+INSERT INTO product_prices (product_id, price, effective_from, applied_at)
+SELECT p.product_id, p.price, p.created_at, p.created_at
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.product_id);
+CREATE TABLE IF NOT EXISTS supplier_products (
+    supplier_product_id INT GENERATED ALWAYS AS IDENTITY,
+    supplier_id INT NOT NULL,
+    product_id INT NOT NULL,
+    cost_price NUMERIC(10, 2) NOT NULL CHECK (cost_price >= 0),
+    supplier_sku VARCHAR(64),
+    lead_time_days INTEGER NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    min_order_quantity INTEGER NOT NULL DEFAULT 1 CHECK (min_order_quantity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(supplier_product_id),
+    CONSTRAINT supplier_products_key UNIQUE (supplier_id, product_id),
+    CONSTRAINT supplier_products_sku_key UNIQUE (supplier_id, supplier_sku),
+    CONSTRAINT fk_supplier_products_suppliers
+        FOREIGN KEY(supplier_id)
+            REFERENCES suppliers(supplier_id) ON DELETE CASCADE,
+    CONSTRAINT fk_supplier_products_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS supplier_products_product_idx ON supplier_products (product_id);
+ALTER TABLE order_details ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(10, 2) CHECK (unit_cost >= 0);
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    purchase_order_id INT GENERATED ALWAYS AS IDENTITY,
+    supplier_id INT NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    expected_at TIMESTAMP,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(purchase_order_id),
+    CONSTRAINT fk_purchase_orders_suppliers
+        FOREIGN KEY(supplier_id)
+            REFERENCES suppliers(supplier_id)
+);
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    purchase_order_line_id INT GENERATED ALWAYS AS IDENTITY,
+    purchase_order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_cost NUMERIC(10, 2) NOT NULL CHECK (unit_cost >= 0),
+    received_quantity INTEGER NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+    PRIMARY KEY(purchase_order_line_id),
+    CONSTRAINT purchase_order_lines_key UNIQUE (purchase_order_id, product_id),
+    CONSTRAINT fk_purchase_order_lines_orders
+        FOREIGN KEY(purchase_order_id)
+            REFERENCES purchase_orders(purchase_order_id) ON DELETE CASCADE,
+    CONSTRAINT fk_purchase_order_lines_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id)
+);
+CREATE INDEX IF NOT EXISTS purchase_order_lines_product_idx ON purchase_order_lines (product_id);
+CREATE TABLE IF NOT EXISTS goods_receipts (
+    goods_receipt_id INT GENERATED ALWAYS AS IDENTITY,
+    purchase_order_id INT NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(goods_receipt_id),
+    CONSTRAINT fk_goods_receipts_purchase_orders
+        FOREIGN KEY(purchase_order_id)
+            REFERENCES purchase_orders(purchase_order_id)
+);
+CREATE TABLE IF NOT EXISTS goods_receipt_lines (
+    goods_receipt_line_id INT GENERATED ALWAYS AS IDENTITY,
+    goods_receipt_id INT NOT NULL,
+    purchase_order_line_id INT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    damaged_quantity INTEGER NOT NULL DEFAULT 0 CHECK (damaged_quantity >= 0 AND damaged_quantity <= quantity),
+    PRIMARY KEY(goods_receipt_line_id),
+    CONSTRAINT fk_goods_receipt_lines_receipts
+        FOREIGN KEY(goods_receipt_id)
+            REFERENCES goods_receipts(goods_receipt_id) ON DELETE CASCADE,
+    CONSTRAINT fk_goods_receipt_lines_purchase_order_lines
+        FOREIGN KEY(purchase_order_line_id)
+            REFERENCES purchase_order_lines(purchase_order_line_id)
+);
+CREATE INDEX IF NOT EXISTS goods_receipts_purchase_order_idx ON goods_receipts (purchase_order_id);
+CREATE TABLE IF NOT EXISTS stock_movements (
+    stock_movement_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    quantity_change INTEGER NOT NULL CHECK (quantity_change <> 0),
+    movement_type VARCHAR(32) NOT NULL
+        CHECK (movement_type IN ('sale', 'refund', 'receipt', 'adjustment', 'transfer')),
+    reason TEXT NOT NULL DEFAULT '',
+    reference_type VARCHAR(32) NOT NULL DEFAULT '',
+    reference_id INT,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(stock_movement_id),
+    CONSTRAINT fk_stock_movements_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE RESTRICT
+);
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, stock_movement_id);
+INSERT INTO stock_movements (product_id, quantity_change, movement_type, reason, reference_type, reference_id)
+SELECT p.product_id, p.quantity, 'adjustment', 'opening balance', 'product', p.product_id
+FROM products p
+WHERE p.quantity <> 0
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.product_id);
+CREATE TABLE IF NOT EXISTS warehouses (
+    warehouse_id INT GENERATED ALWAYS AS IDENTITY,
+    name VARCHAR(255) NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    latitude NUMERIC(9, 6) CHECK (latitude BETWEEN -90 AND 90),
+    longitude NUMERIC(9, 6) CHECK (longitude BETWEEN -180 AND 180),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(warehouse_id),
+    CONSTRAINT warehouses_name_key UNIQUE (name)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS warehouses_default_key ON warehouses (is_default) WHERE is_default;
+INSERT INTO warehouses (name, is_default)
+SELECT 'Main', TRUE
+WHERE NOT EXISTS (SELECT 1 FROM warehouses);
+CREATE TABLE IF NOT EXISTS warehouse_stock (
+    warehouse_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0 CONSTRAINT warehouse_stock_quantity_check CHECK (quantity >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(warehouse_id, product_id),
+    CONSTRAINT fk_warehouse_stock_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id),
+    CONSTRAINT fk_warehouse_stock_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS warehouse_stock_product_idx ON warehouse_stock (product_id);
+INSERT INTO warehouse_stock (warehouse_id, product_id, quantity)
+SELECT w.warehouse_id, p.product_id, p.quantity
+FROM products p
+JOIN warehouses w ON w.is_default
+WHERE p.quantity > 0
+  AND NOT EXISTS (SELECT 1 FROM warehouse_stock ws WHERE ws.product_id = p.product_id);
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    stock_transfer_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    from_warehouse_id INT NOT NULL,
+    to_warehouse_id INT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(stock_transfer_id),
+    CONSTRAINT stock_transfers_warehouses_check CHECK (from_warehouse_id <> to_warehouse_id),
+    CONSTRAINT fk_stock_transfers_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE,
+    CONSTRAINT fk_stock_transfers_from_warehouses
+        FOREIGN KEY(from_warehouse_id)
+            REFERENCES warehouses(warehouse_id),
+    CONSTRAINT fk_stock_transfers_to_warehouses
+        FOREIGN KEY(to_warehouse_id)
+            REFERENCES warehouses(warehouse_id)
+);
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS warehouse_id INT CONSTRAINT fk_stock_movements_warehouses REFERENCES warehouses(warehouse_id);
+UPDATE stock_movements SET warehouse_id = (SELECT warehouse_id FROM warehouses WHERE is_default) WHERE warehouse_id IS NULL;
+ALTER TABLE order_details ADD COLUMN IF NOT EXISTS warehouse_id INT CONSTRAINT fk_order_details_warehouses REFERENCES warehouses(warehouse_id);
+ALTER TABLE order_details ADD COLUMN IF NOT EXISTS backordered BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE order_details SET warehouse_id = (SELECT warehouse_id FROM warehouses WHERE is_default) WHERE warehouse_id IS NULL AND NOT backordered;
+ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS warehouse_id INT CONSTRAINT fk_purchase_orders_warehouses REFERENCES warehouses(warehouse_id);
+UPDATE purchase_orders SET warehouse_id = (SELECT warehouse_id FROM warehouses WHERE is_default) WHERE warehouse_id IS NULL;
+CREATE TABLE IF NOT EXISTS bin_locations (
+    bin_location_id INT GENERATED ALWAYS AS IDENTITY,
+    warehouse_id INT NOT NULL,
+    zone VARCHAR(16) NOT NULL DEFAULT '',
+    aisle VARCHAR(16) NOT NULL DEFAULT '',
+    shelf VARCHAR(16) NOT NULL DEFAULT '',
+    bin VARCHAR(16) NOT NULL DEFAULT '',
+    pick_sequence INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(bin_location_id),
+    CONSTRAINT bin_locations_key UNIQUE (warehouse_id, zone, aisle, shelf, bin),
+    CONSTRAINT fk_bin_locations_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id)
+);
+CREATE TABLE IF NOT EXISTS bin_stock (
+    bin_location_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0 CONSTRAINT bin_stock_quantity_check CHECK (quantity >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(bin_location_id, product_id),
+    CONSTRAINT fk_bin_stock_bin_locations
+        FOREIGN KEY(bin_location_id)
+            REFERENCES bin_locations(bin_location_id),
+    CONSTRAINT fk_bin_stock_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS bin_stock_product_idx ON bin_stock (product_id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS picked_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS track_lots BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS lots (
+    lot_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    lot_number VARCHAR(64) NOT NULL,
+    expires_at DATE,
+    quantity INTEGER NOT NULL DEFAULT 0 CONSTRAINT lots_quantity_check CHECK (quantity >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(lot_id),
+    CONSTRAINT lots_key UNIQUE (product_id, warehouse_id, lot_number),
+    CONSTRAINT fk_lots_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE,
+    CONSTRAINT fk_lots_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id)
+);
+CREATE INDEX IF NOT EXISTS lots_expires_idx ON lots (expires_at) WHERE quantity > 0;
+CREATE TABLE IF NOT EXISTS order_detail_lots (
+    order_detail_id INT NOT NULL,
+    lot_id INT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY(order_detail_id, lot_id),
+    CONSTRAINT fk_order_detail_lots_order_details
+        FOREIGN KEY(order_detail_id)
+            REFERENCES order_details(order_detail_id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_detail_lots_lots
+        FOREIGN KEY(lot_id)
+            REFERENCES lots(lot_id) ON DELETE CASCADE
+);
+ALTER TABLE goods_receipt_lines ADD COLUMN IF NOT EXISTS lot_id INT REFERENCES lots(lot_id) ON DELETE SET NULL;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS track_serials BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS serial_numbers (
+    serial_number_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    serial_number VARCHAR(64) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'in_stock'
+        CHECK (status IN ('in_stock', 'sold', 'written_off')),
+    warehouse_id INT,
+    order_detail_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(serial_number_id),
+    CONSTRAINT serial_numbers_key UNIQUE (product_id, serial_number),
+    CONSTRAINT serial_numbers_warehouse_check CHECK ((status = 'in_stock') = (warehouse_id IS NOT NULL)),
+    CONSTRAINT fk_serial_numbers_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE,
+    CONSTRAINT fk_serial_numbers_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id),
+    CONSTRAINT fk_serial_numbers_order_details
+        FOREIGN KEY(order_detail_id)
+            REFERENCES order_details(order_detail_id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS serial_numbers_number_idx ON serial_numbers (serial_number);
+CREATE TABLE IF NOT EXISTS serial_number_events (
+    serial_number_event_id INT GENERATED ALWAYS AS IDENTITY,
+    serial_number_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL
+        CHECK (event_type IN ('sale', 'refund', 'receipt', 'adjustment', 'transfer')),
+    status VARCHAR(16) NOT NULL,
+    warehouse_id INT,
+    reference_type VARCHAR(32) NOT NULL DEFAULT '',
+    reference_id INT,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(serial_number_event_id),
+    CONSTRAINT fk_serial_number_events_serial_numbers
+        FOREIGN KEY(serial_number_id)
+            REFERENCES serial_numbers(serial_number_id) ON DELETE CASCADE,
+    CONSTRAINT fk_serial_number_events_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id)
+);
+CREATE INDEX IF NOT EXISTS serial_number_events_serial_idx ON serial_number_events (serial_number_id, created_at);
+CREATE TABLE IF NOT EXISTS reservations (
+    reservation_id INT GENERATED ALWAYS AS IDENTITY,
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    customer_id INT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    price NUMERIC(10, 2) CHECK (price >= 0),
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'converted', 'released', 'expired')),
+    expires_at TIMESTAMP NOT NULL,
+    order_id INT,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(reservation_id),
+    CONSTRAINT fk_reservations_products
+        FOREIGN KEY(product_id)
+            REFERENCES products(product_id) ON DELETE CASCADE,
+    CONSTRAINT fk_reservations_warehouses
+        FOREIGN KEY(warehouse_id)
+            REFERENCES warehouses(warehouse_id),
+    CONSTRAINT fk_reservations_customers
+        FOREIGN KEY(customer_id)
+            REFERENCES customers(customer_id),
+    CONSTRAINT fk_reservations_orders
+        FOREIGN KEY(order_id)
+            REFERENCES orders(order_id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS reservations_active_idx ON reservations (product_id, warehouse_id) WHERE status = 'active';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS allow_backorders BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS orders_backordered_idx ON orders (created_at, order_id) WHERE status = 'backordered';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_point INTEGER CHECK (reorder_point >= 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_quantity INTEGER CHECK (reorder_quantity > 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS safety_stock INTEGER NOT NULL DEFAULT 0 CHECK (safety_stock >= 0);
+ALTER TABLE customers DROP CONSTRAINT IF EXISTS customers_email_key;
+ALTER TABLE customers DROP CONSTRAINT IF EXISTS customers_phone_key;
+ALTER TABLE suppliers DROP CONSTRAINT IF EXISTS suppliers_contact_email_key;
+ALTER TABLE suppliers DROP CONSTRAINT IF EXISTS suppliers_contact_phone_key;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_name_key;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_sku_key;
+DROP INDEX IF EXISTS products_barcode_key;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_key ON customers (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_phone_key ON customers (phone) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS suppliers_contact_email_key ON suppliers (contact_email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS suppliers_contact_phone_key ON suppliers (contact_phone) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS products_name_key ON products (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS products_sku_key ON products (sku) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS products_active_barcode_key ON products (LPAD(barcode, 14, '0')) WHERE deleted_at IS NULL;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint
+                   WHERE conname = 'fk_stock_movements_products'
+                     AND conrelid = 'stock_movements'::regclass
+                     AND confdeltype = 'r') THEN
+        ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS fk_stock_movements_products;
+        ALTER TABLE stock_movements ADD CONSTRAINT fk_stock_movements_products
+            FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE RESTRICT NOT VALID;
+    END IF;
+END
+$$;
+CREATE OR REPLACE VIEW product_current_costs AS
+SELECT DISTINCT ON (sp.product_id) sp.product_id, sp.cost_price
+FROM supplier_products sp
+JOIN products p ON p.product_id = sp.product_id
+ORDER BY sp.product_id, sp.supplier_id = p.supplier_id DESC, sp.cost_price;
+CREATE OR REPLACE VIEW sales_lines AS
+SELECT od.order_detail_id, od.order_id, od.product_id, o.customer_id, o.created_at, od.quantity, od.price,
+       COALESCE(od.unit_cost, cc.cost_price) AS unit_cost
+FROM order_details od
+JOIN orders o ON o.order_id = od.order_id
+LEFT JOIN product_current_costs cc ON cc.product_id = od.product_id
+WHERE o.status NOT IN ('refunded', 'cancelled', 'backordered');
@@ -1,2 +1,2 @@
 SELECT customer_id FROM customers
-WHERE email = $1;
\ No newline at end of file
+WHERE email = $1 AND deleted_at IS NULL;
\ No newline at end of file
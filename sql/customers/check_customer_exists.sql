@@ -1 +1 @@
-SELECT EXISTS(SELECT 1 FROM customers WHERE customer_id = $1);
\ No newline at end of file
+SELECT EXISTS(SELECT 1 FROM customers WHERE customer_id = $1 AND deleted_at IS NULL);
\ No newline at end of file
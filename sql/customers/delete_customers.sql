@@ -1,2 +1,3 @@
-DELETE FROM customers
-WHERE customer_id = $1;
\ No newline at end of file
+UPDATE customers
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE customer_id = $1 AND deleted_at IS NULL;
\ No newline at end of file
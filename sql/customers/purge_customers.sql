@@ -0,0 +1,4 @@
+DELETE FROM customers c
+WHERE c.deleted_at < $1
+  AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.customer_id = c.customer_id)
+  AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.customer_id = c.customer_id);
\ No newline at end of file
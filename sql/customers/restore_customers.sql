@@ -0,0 +1,3 @@
+UPDATE customers
+SET deleted_at = NULL
+WHERE customer_id = $1 AND deleted_at IS NOT NULL;
\ No newline at end of file
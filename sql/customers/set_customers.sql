@@ -4,4 +4,4 @@ SET
     email = COALESCE($3, email),
     phone = COALESCE($4, phone),
     address = COALESCE($5, address)
-WHERE customer_id = $1;
\ No newline at end of file
+WHERE customer_id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -1,3 +1,3 @@
-SELECT customer_id, name, email, phone, address
+SELECT customer_id, name, email, phone, address, deleted_at
 FROM customers
-LIMIT COALESCE($1, 1000);
\ No newline at end of file
+WHERE ($1 OR deleted_at IS NULL)
@@ -0,0 +1,7 @@
+INSERT INTO lots (product_id, warehouse_id, lot_number, expires_at, quantity, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (product_id, warehouse_id, lot_number) DO UPDATE
+SET quantity = lots.quantity + EXCLUDED.quantity,
+    expires_at = COALESCE(lots.expires_at, EXCLUDED.expires_at),
+    updated_at = CURRENT_TIMESTAMP
+RETURNING lot_id;
@@ -0,0 +1,4 @@
+INSERT INTO order_detail_lots (order_detail_id, lot_id, quantity)
+VALUES ($1, $2, $3)
+ON CONFLICT (order_detail_id, lot_id) DO UPDATE
+SET quantity = order_detail_lots.quantity + EXCLUDED.quantity;
@@ -0,0 +1,5 @@
+SELECT lot_id, lot_number, expires_at, quantity, COALESCE(expires_at < CURRENT_DATE, FALSE)
+FROM lots
+WHERE product_id = $1 AND warehouse_id = $2 AND quantity > 0
+ORDER BY expires_at NULLS LAST, lot_id
+FOR UPDATE;
@@ -0,0 +1,5 @@
+UPDATE lots
+SET quantity = quantity - $4,
+    updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1 AND warehouse_id = $2 AND lot_number = $3 AND quantity >= $4
+RETURNING lot_id;
@@ -0,0 +1,10 @@
+SELECT l.lot_id, l.product_id, p.name, l.warehouse_id, w.name, l.lot_number, l.expires_at, l.quantity,
+       COALESCE(l.expires_at < CURRENT_DATE, FALSE) AS expired, l.created_at, l.updated_at
+FROM lots l
+JOIN products p ON p.product_id = l.product_id
+JOIN warehouses w ON w.warehouse_id = l.warehouse_id
+WHERE ($1::INT IS NULL OR l.product_id = $1)
+  AND ($2::INT IS NULL OR l.warehouse_id = $2)
+  AND ($3 OR l.quantity > 0)
+  AND ($4::INT IS NULL OR l.expires_at <= CURRENT_DATE + $4::INT)
+ORDER BY l.expires_at NULLS LAST, l.product_id, l.warehouse_id, l.lot_id;
@@ -0,0 +1,4 @@
+UPDATE lots
+SET quantity = quantity - $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE lot_id = $1 AND quantity >= $2;
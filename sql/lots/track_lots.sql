@@ -0,0 +1 @@
+SELECT track_lots FROM products WHERE product_id = $1;
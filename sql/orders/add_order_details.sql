@@ -1,2 +1,4 @@
-INSERT INTO order_details (order_id, product_id, quantity, price)
-VALUES ($1, $2, $3, $4) RETURNING order_detail_id;
\ No newline at end of file
+INSERT INTO order_details (order_id, product_id, quantity, price, warehouse_id, backordered, unit_cost)
+VALUES ($1, $2, $3, $4, $5, $6, (
+    SELECT cost_price FROM product_current_costs WHERE product_id = $2
+)) RETURNING order_detail_id;
@@ -1,2 +1,2 @@
 INSERT INTO orders (customer_id, status, created_at, updated_at)
-VALUES ($1, 'new', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING order_id;
\ No newline at end of file
+VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING order_id;
\ No newline at end of file
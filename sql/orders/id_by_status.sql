@@ -1,4 +1,3 @@
-SELECT order_id, customer_id, created_at
+SELECT order_id, status, created_at
 FROM orders
 WHERE status = $1
-LIMIT COALESCE($2, 1000);
\ No newline at end of file
@@ -1,3 +0,0 @@
-UPDATE products
-SET quantity = quantity - $2
-WHERE product_id = $1;
\ No newline at end of file
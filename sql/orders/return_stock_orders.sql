@@ -0,0 +1,59 @@
+WITH updated_orders AS (
+    UPDATE orders SET status = $3::TEXT, updated_at = CURRENT_TIMESTAMP
+    WHERE order_id = $1 AND status = ANY($4::TEXT[])
+    RETURNING order_id
+), updated_products AS (
+    UPDATE products
+        SET quantity = products.quantity + od.quantity
+        FROM order_details AS od
+        JOIN updated_orders AS o ON o.order_id = od.order_id
+        WHERE products.product_id = od.product_id AND NOT od.backordered
+        RETURNING products.product_id
+), updated_stock AS (
+    INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+    SELECT od.warehouse_id, od.product_id, SUM(od.quantity), CURRENT_TIMESTAMP
+    FROM order_details AS od
+    JOIN updated_orders AS o ON o.order_id = od.order_id
+    WHERE od.quantity > 0 AND NOT od.backordered
+    GROUP BY od.warehouse_id, od.product_id
+    ON CONFLICT (warehouse_id, product_id) DO UPDATE
+        SET quantity = warehouse_stock.quantity + EXCLUDED.quantity,
+            updated_at = CURRENT_TIMESTAMP
+    RETURNING warehouse_stock.product_id
+), updated_lots AS (
+    UPDATE lots
+        SET quantity = lots.quantity + returned.quantity,
+            updated_at = CURRENT_TIMESTAMP
+        FROM (
+            SELECT odl.lot_id, SUM(odl.quantity) AS quantity
+            FROM order_detail_lots AS odl
+            JOIN order_details AS od ON od.order_detail_id = odl.order_detail_id
+            JOIN updated_orders AS o ON o.order_id = od.order_id
+            GROUP BY odl.lot_id
+        ) AS returned
+        WHERE lots.lot_id = returned.lot_id
+        RETURNING lots.lot_id
+), returned_serials AS (
+    UPDATE serial_numbers
+        SET status = 'in_stock',
+            warehouse_id = od.warehouse_id,
+            order_detail_id = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        FROM order_details AS od
+        JOIN updated_orders AS o ON o.order_id = od.order_id
+        WHERE serial_numbers.order_detail_id = od.order_detail_id AND serial_numbers.status = 'sold'
+        RETURNING serial_numbers.serial_number_id, od.warehouse_id
+), serial_events AS (
+    INSERT INTO serial_number_events (serial_number_id, event_type, status, warehouse_id, reference_type, reference_id, created_by, created_at)
+    SELECT serial_number_id, 'refund', 'in_stock', warehouse_id, 'order', $1, $2, CURRENT_TIMESTAMP
+    FROM returned_serials
+    RETURNING serial_number_event_id
+), movements AS (
+    INSERT INTO stock_movements (product_id, warehouse_id, quantity_change, movement_type, reason, reference_type, reference_id, created_by, created_at)
+    SELECT od.product_id, od.warehouse_id, od.quantity, 'refund', 'order ' || $3::TEXT, 'order', od.order_id, $2, CURRENT_TIMESTAMP
+    FROM order_details AS od
+    JOIN updated_orders AS o ON o.order_id = od.order_id
+    WHERE od.quantity > 0 AND NOT od.backordered
+    RETURNING stock_movement_id
+)
+SELECT COUNT(*) FROM updated_orders;
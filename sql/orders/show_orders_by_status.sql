@@ -1,3 +1,2 @@
 SELECT order_id, customer_id, status, created_at, updated_at FROM orders
 WHERE status = $1
-LIMIT COALESCE($2, 1000);
\ No newline at end of file
@@ -1,3 +1,3 @@
 UPDATE orders
 SET status = $2, updated_at = CURRENT_TIMESTAMP
-WHERE order_id = $1;
\ No newline at end of file
+WHERE order_id = $1 AND status = ANY($3::TEXT[]);
\ No newline at end of file
@@ -1,2 +1,2 @@
-INSERT INTO products (supplier_id, name, description, price, quantity, category, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING product_id;
+INSERT INTO products (supplier_id, name, description, price, quantity, category_id, sku, barcode, unit_of_measure, pack_size, track_lots, track_serials, allow_backorders, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING product_id;
@@ -0,0 +1,19 @@
+WITH due AS (
+    UPDATE product_prices pp
+    SET applied_at = CURRENT_TIMESTAMP
+    FROM products p
+    WHERE pp.product_id = p.product_id
+      AND p.deleted_at IS NULL
+      AND pp.applied_at IS NULL
+      AND pp.effective_from <= $1
+    RETURNING pp.product_id, pp.price, pp.effective_from, pp.price_id
+), latest AS (
+    SELECT DISTINCT ON (product_id) product_id, price
+    FROM due
+    ORDER BY product_id, effective_from DESC, price_id DESC
+)
+UPDATE products p
+SET price = latest.price,
+    updated_at = CURRENT_TIMESTAMP
+FROM latest
+WHERE p.product_id = latest.product_id;
@@ -0,0 +1 @@
+SELECT allow_backorders FROM products WHERE product_id = $1 AND deleted_at IS NULL;
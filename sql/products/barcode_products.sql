@@ -0,0 +1,4 @@
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at
+FROM products p
+LEFT JOIN categories c ON c.category_id = p.category_id
+WHERE LPAD(p.barcode, 14, '0') = LPAD($1, 14, '0') AND p.deleted_at IS NULL;
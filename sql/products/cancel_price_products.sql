@@ -0,0 +1,2 @@
+DELETE FROM product_prices
+WHERE price_id = $1 AND applied_at IS NULL;
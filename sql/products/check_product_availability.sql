@@ -1 +1,7 @@
-SELECT quantity FROM products WHERE product_id = $1;
\ No newline at end of file
+SELECT p.quantity - COALESCE((
+    SELECT SUM(r.quantity)
+    FROM reservations r
+    WHERE r.product_id = p.product_id AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+), 0)
+FROM products p
+WHERE p.product_id = $1 AND p.deleted_at IS NULL;
@@ -1,4 +1,5 @@
-SELECT product_id, supplier_id, name, description, price, quantity, category, created_at, updated_at
-FROM products
-WHERE quantity <= $1
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at
+FROM products p
+LEFT JOIN categories c ON c.category_id = p.category_id
+WHERE p.quantity <= $1 AND p.deleted_at IS NULL
 LIMIT COALESCE($2, 1000);
\ No newline at end of file
@@ -1,5 +1,5 @@
 SELECT EXISTS (
     SELECT 1
     FROM suppliers
-    WHERE supplier_id = $1
+    WHERE supplier_id = $1 AND deleted_at IS NULL
 );
\ No newline at end of file
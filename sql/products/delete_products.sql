@@ -1,2 +1,3 @@
-DELETE FROM products
-WHERE product_id = $1;
\ No newline at end of file
+UPDATE products
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -1,5 +1,5 @@
 SELECT EXISTS (
     SELECT 1
     FROM products
-    WHERE name = $1
+    WHERE name = $1 AND deleted_at IS NULL
 );
\ No newline at end of file
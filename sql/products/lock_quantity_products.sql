@@ -0,0 +1 @@
+SELECT quantity, track_serials FROM products WHERE product_id = $1 FOR UPDATE;
@@ -0,0 +1,13 @@
+SELECT price_id, product_id, price, effective_from, effective_to, applied_at, created_at
+FROM (
+    SELECT price_id, product_id, price, effective_from,
+           CASE WHEN applied_at IS NOT NULL THEN
+               LEAD(effective_from) OVER (PARTITION BY applied_at IS NULL ORDER BY effective_from, price_id)
+           END AS effective_to,
+           applied_at, created_at
+    FROM product_prices
+    WHERE product_id = $1
+) history
+WHERE ($2::TIMESTAMP IS NULL OR effective_to IS NULL OR effective_to > $2)
+  AND ($3::TIMESTAMP IS NULL OR effective_from < $3)
+ORDER BY effective_from DESC, price_id DESC;
@@ -1,4 +1,9 @@
 SELECT p.product_id, p.name, s.supplier_id, s.contact_email
 FROM products p
 JOIN suppliers s ON p.supplier_id = s.supplier_id
-WHERE p.quantity < $1;
\ No newline at end of file
+LEFT JOIN warehouse_stock ws ON ws.product_id = p.product_id AND ws.warehouse_id = $2
+CROSS JOIN LATERAL (
+    SELECT CASE WHEN $2::INT IS NULL THEN p.quantity ELSE COALESCE(ws.quantity, 0) END AS quantity
+) stock
+WHERE CASE WHEN $1::INT IS NULL THEN stock.quantity <= p.reorder_point ELSE stock.quantity < $1 END
+  AND p.deleted_at IS NULL;
@@ -0,0 +1,5 @@
+DELETE FROM products p
+WHERE p.deleted_at < $1
+  AND NOT EXISTS (SELECT 1 FROM order_details od WHERE od.product_id = p.product_id)
+  AND NOT EXISTS (SELECT 1 FROM purchase_order_lines l WHERE l.product_id = p.product_id)
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.product_id);
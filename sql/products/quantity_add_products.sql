@@ -0,0 +1,4 @@
+UPDATE products
+SET quantity = quantity + $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1;
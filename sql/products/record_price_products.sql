@@ -0,0 +1,11 @@
+INSERT INTO product_prices (product_id, price, effective_from, applied_at)
+SELECT p.product_id, p.price, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM products p
+WHERE p.product_id = $1
+  AND p.price IS DISTINCT FROM (
+      SELECT pp.price
+      FROM product_prices pp
+      WHERE pp.product_id = p.product_id AND pp.applied_at IS NOT NULL
+      ORDER BY pp.effective_from DESC, pp.price_id DESC
+      LIMIT 1
+  );
@@ -0,0 +1,6 @@
+UPDATE products
+SET reorder_point = $2,
+    reorder_quantity = $3,
+    safety_stock = $4,
+    updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1 AND deleted_at IS NULL;
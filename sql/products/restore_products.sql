@@ -0,0 +1,3 @@
+UPDATE products
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1 AND deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,5 @@
+INSERT INTO product_prices (product_id, price, effective_from)
+SELECT product_id, $2, $3
+FROM products
+WHERE product_id = $1 AND deleted_at IS NULL
+RETURNING price_id;
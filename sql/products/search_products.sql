@@ -0,0 +1,41 @@
+WITH search AS (
+    SELECT websearch_to_tsquery('english', $1) AS query
+)
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at,
+    ts_rank_cd(
+        setweight(to_tsvector('english', p.name), 'A') ||
+        setweight(to_tsvector('english', COALESCE(p.sku, '')), 'A') ||
+        setweight(to_tsvector('english', p.description), 'C') ||
+        setweight(to_tsvector('english', COALESCE(c.name, '')), 'B'),
+        search.query
+    ) + GREATEST(
+        word_similarity($1, p.name),
+        word_similarity($1, COALESCE(p.sku, '')),
+        word_similarity($1, COALESCE(c.name, '')) * 0.5
+    ) AS rank,
+    ts_headline('english', p.name, search.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+    ts_headline('english', p.description, search.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+FROM products p
+LEFT JOIN categories c ON c.category_id = p.category_id
+CROSS JOIN search
+WHERE (
+        (
+            setweight(to_tsvector('english', p.name), 'A') ||
+            setweight(to_tsvector('english', COALESCE(p.sku, '')), 'A') ||
+            setweight(to_tsvector('english', p.description), 'C')
+        ) @@ search.query
+        OR to_tsvector('english', COALESCE(c.name, '')) @@ search.query
+        OR $1 <% p.name
+        OR $1 <% p.sku
+        OR $1 <% c.name
+    )
+    AND ($2::NUMERIC IS NULL OR p.price >= $2)
+    AND ($3::NUMERIC IS NULL OR p.price <= $3)
+    AND (NOT $4 OR CASE
+        WHEN $7::INT IS NULL THEN p.quantity > 0
+        ELSE EXISTS (SELECT 1 FROM warehouse_stock ws WHERE ws.product_id = p.product_id AND ws.warehouse_id = $7 AND ws.quantity > 0)
+    END)
+    AND ($5::INT IS NULL OR p.supplier_id = $5)
+    AND p.deleted_at IS NULL
+ORDER BY rank DESC, p.product_id
+LIMIT COALESCE($6, 50);
@@ -5,6 +5,13 @@ SET
     description = COALESCE($4, description),
     price = COALESCE($5, price),
     quantity = COALESCE($6, quantity),
-    category = COALESCE($7, category),
+    category_id = COALESCE($7, category_id),
+    sku = COALESCE($8, sku),
+    barcode = COALESCE($9, barcode),
+    unit_of_measure = COALESCE($10, unit_of_measure),
+    pack_size = COALESCE($11, pack_size),
+    track_lots = COALESCE($12, track_lots),
+    track_serials = COALESCE($13, track_serials),
+    allow_backorders = COALESCE($14, allow_backorders),
     updated_at = CURRENT_TIMESTAMP
-WHERE product_id =   $1;
\ No newline at end of file
+WHERE product_id = $1 AND deleted_at IS NULL;
@@ -1,4 +1,14 @@
-SELECT product_id, supplier_id, name, description, price, quantity, category, created_at, updated_at
-FROM products
-WHERE category = $1
-LIMIT COALESCE($2, 1000);
\ No newline at end of file
+WITH RECURSIVE tree AS (
+    SELECT category_id
+    FROM categories
+    WHERE category_id = $1 OR ($1 IS NULL AND LOWER(name) = LOWER($2))
+    UNION
+    SELECT c.category_id
+    FROM categories c
+    JOIN tree t ON c.parent_id = t.category_id
+    WHERE $3
+)
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at
+FROM products p
+LEFT JOIN categories c ON c.category_id = p.category_id
+WHERE p.category_id IN (SELECT category_id FROM tree) AND ($4 OR p.deleted_at IS NULL)
@@ -1,3 +1,17 @@
-SELECT product_id, supplier_id, name, description, price, quantity, category, created_at, updated_at FROM products
-WHERE quantity > 0
-LIMIT COALESCE($1, 1000);
\ No newline at end of file
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at,
+       GREATEST(p.quantity - p.reserved, 0)
+FROM (
+    SELECT pr.product_id, pr.supplier_id, pr.name, pr.description, pr.price,
+           CASE WHEN $2::INT IS NULL THEN pr.quantity ELSE COALESCE(ws.quantity, 0) END AS quantity,
+           COALESCE((
+               SELECT SUM(r.quantity)
+               FROM reservations r
+               WHERE r.product_id = pr.product_id AND ($2::INT IS NULL OR r.warehouse_id = $2)
+                 AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+           ), 0) AS reserved,
+           pr.category_id, pr.sku, pr.barcode, pr.unit_of_measure, pr.pack_size, pr.track_lots, pr.track_serials, pr.allow_backorders, pr.reorder_point, pr.reorder_quantity, pr.safety_stock, pr.created_at, pr.updated_at, pr.deleted_at
+    FROM products pr
+    LEFT JOIN warehouse_stock ws ON ws.product_id = pr.product_id AND ws.warehouse_id = $2
+) p
+LEFT JOIN categories c ON c.category_id = p.category_id
+WHERE p.quantity > 0 AND ($1 OR p.deleted_at IS NULL)
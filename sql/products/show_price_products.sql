@@ -1,3 +1,4 @@
-SELECT product_id, supplier_id, name, description, price, quantity, category, created_at, updated_at FROM products
-WHERE price BETWEEN $1 AND $2
-LIMIT COALESCE($3, 1000)
\ No newline at end of file
+SELECT p.product_id, p.supplier_id, p.name, p.description, p.price, p.quantity, p.category_id, c.name, p.sku, p.barcode, p.unit_of_measure, p.pack_size, p.track_lots, p.track_serials, p.allow_backorders, p.reorder_point, p.reorder_quantity, p.safety_stock, p.created_at, p.updated_at, p.deleted_at
+FROM products p
+LEFT JOIN categories c ON c.category_id = p.category_id
+WHERE p.price BETWEEN $1 AND $2 AND ($3 OR p.deleted_at IS NULL)
@@ -0,0 +1,2 @@
+INSERT INTO goods_receipt_lines (goods_receipt_id, purchase_order_line_id, quantity, damaged_quantity, lot_id)
+VALUES ($1, $2, $3, $4, $5) RETURNING goods_receipt_line_id;
@@ -0,0 +1,2 @@
+INSERT INTO goods_receipts (purchase_order_id, notes, received_at)
+VALUES ($1, $2, CURRENT_TIMESTAMP) RETURNING goods_receipt_id, received_at;
@@ -0,0 +1,12 @@
+INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity, unit_cost)
+SELECT $1, $2, $3, cost
+FROM (
+    SELECT COALESCE($4::NUMERIC, (
+        SELECT sp.cost_price
+        FROM supplier_products sp
+        JOIN purchase_orders po ON po.supplier_id = sp.supplier_id
+        WHERE po.purchase_order_id = $1 AND sp.product_id = $2
+    )) AS cost
+) line
+WHERE cost IS NOT NULL
+RETURNING purchase_order_line_id;
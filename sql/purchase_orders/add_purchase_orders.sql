@@ -0,0 +1,2 @@
+INSERT INTO purchase_orders (supplier_id, warehouse_id, status, notes, expected_at, created_at, updated_at)
+VALUES ($1, $2, 'draft', $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING purchase_order_id;
@@ -0,0 +1,8 @@
+SELECT l.purchase_order_line_id, l.product_id, p.name, p.sku, sp.supplier_sku, l.quantity, l.received_quantity, l.unit_cost,
+       l.quantity * l.unit_cost AS line_total
+FROM purchase_order_lines l
+JOIN purchase_orders po ON po.purchase_order_id = l.purchase_order_id
+JOIN products p ON p.product_id = l.product_id
+LEFT JOIN supplier_products sp ON sp.supplier_id = po.supplier_id AND sp.product_id = l.product_id
+WHERE l.purchase_order_id = $1
+ORDER BY l.purchase_order_line_id;
@@ -0,0 +1 @@
+SELECT status, warehouse_id FROM purchase_orders WHERE purchase_order_id = $1 FOR UPDATE;
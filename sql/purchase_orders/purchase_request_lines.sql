@@ -0,0 +1,23 @@
+SELECT p.product_id, p.supplier_id,
+       GREATEST($2 - stock.quantity, COALESCE(sp.min_order_quantity, 1)) AS quantity,
+       sp.cost_price
+FROM products p
+JOIN suppliers s ON s.supplier_id = p.supplier_id AND s.deleted_at IS NULL
+LEFT JOIN supplier_products sp ON sp.product_id = p.product_id AND sp.supplier_id = p.supplier_id
+CROSS JOIN LATERAL (
+    SELECT CASE
+        WHEN $3::INT IS NULL THEN p.quantity
+        ELSE COALESCE((SELECT ws.quantity FROM warehouse_stock ws WHERE ws.warehouse_id = $3 AND ws.product_id = p.product_id), 0)
+    END AS quantity
+) stock
+WHERE stock.quantity < $1
+  AND p.deleted_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM purchase_order_lines l
+      JOIN purchase_orders po ON po.purchase_order_id = l.purchase_order_id
+      WHERE l.product_id = p.product_id
+        AND po.status IN ('draft', 'sent', 'partially_received')
+        AND ($3::INT IS NULL OR po.warehouse_id = $3)
+  )
+ORDER BY p.supplier_id, p.product_id;
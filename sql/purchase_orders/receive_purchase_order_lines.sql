@@ -0,0 +1,4 @@
+UPDATE purchase_order_lines
+SET received_quantity = received_quantity + $3
+WHERE purchase_order_line_id = $2 AND purchase_order_id = $1
+RETURNING product_id, quantity, received_quantity;
@@ -0,0 +1,11 @@
+UPDATE purchase_orders po
+SET status = CASE
+        WHEN $2 OR NOT EXISTS (
+            SELECT 1 FROM purchase_order_lines l
+            WHERE l.purchase_order_id = po.purchase_order_id AND l.received_quantity < l.quantity
+        ) THEN 'received'
+        ELSE 'partially_received'
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE po.purchase_order_id = $1
+RETURNING po.status;
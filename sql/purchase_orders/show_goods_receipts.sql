@@ -0,0 +1,10 @@
+SELECT gr.goods_receipt_id, gr.purchase_order_id, gr.notes, gr.received_at,
+       grl.goods_receipt_line_id, grl.purchase_order_line_id, l.product_id, p.name,
+       grl.quantity, grl.damaged_quantity, grl.lot_id, lt.lot_number, lt.expires_at
+FROM goods_receipts gr
+JOIN goods_receipt_lines grl ON grl.goods_receipt_id = gr.goods_receipt_id
+JOIN purchase_order_lines l ON l.purchase_order_line_id = grl.purchase_order_line_id
+JOIN products p ON p.product_id = l.product_id
+LEFT JOIN lots lt ON lt.lot_id = grl.lot_id
+WHERE gr.purchase_order_id = $1
+ORDER BY gr.received_at, gr.goods_receipt_id, grl.goods_receipt_line_id;
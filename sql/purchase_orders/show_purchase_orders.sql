@@ -0,0 +1,9 @@
+SELECT po.purchase_order_id, po.supplier_id, s.name, s.contact_name, s.contact_email,
+       po.warehouse_id, w.name, w.address, po.status, po.notes,
+       po.expected_at, po.sent_at,
+       COALESCE((SELECT SUM(l.quantity * l.unit_cost) FROM purchase_order_lines l WHERE l.purchase_order_id = po.purchase_order_id), 0) AS total_cost,
+       po.created_at, po.updated_at
+FROM purchase_orders po
+JOIN suppliers s ON s.supplier_id = po.supplier_id
+JOIN warehouses w ON w.warehouse_id = po.warehouse_id
+WHERE ($1::INT IS NULL OR po.purchase_order_id = $1)
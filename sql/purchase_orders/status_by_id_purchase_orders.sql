@@ -0,0 +1 @@
+SELECT status FROM purchase_orders WHERE purchase_order_id = $1;
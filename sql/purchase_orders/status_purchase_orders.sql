@@ -0,0 +1,5 @@
+UPDATE purchase_orders
+SET status = $2,
+    sent_at = CASE WHEN $2 = 'sent' THEN CURRENT_TIMESTAMP ELSE sent_at END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE purchase_order_id = $1 AND status = ANY($3);
@@ -0,0 +1,28 @@
+SELECT p.product_id, p.name, s.supplier_id, s.name, s.contact_email,
+       p.quantity - COALESCE(reserved.quantity, 0) AS available,
+       COALESCE(on_order.quantity, 0) AS on_order,
+       COALESCE(backordered.quantity, 0) AS backordered,
+       p.reorder_point, p.reorder_quantity, p.safety_stock,
+       COALESCE(sp.lead_time_days, 0), COALESCE(sp.min_order_quantity, 1), sp.cost_price
+FROM products p
+JOIN suppliers s ON s.supplier_id = p.supplier_id AND s.deleted_at IS NULL
+LEFT JOIN supplier_products sp ON sp.product_id = p.product_id AND sp.supplier_id = p.supplier_id
+LEFT JOIN LATERAL (
+    SELECT SUM(r.quantity) AS quantity
+    FROM reservations r
+    WHERE r.product_id = p.product_id AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+) reserved ON TRUE
+LEFT JOIN LATERAL (
+    SELECT SUM(GREATEST(l.quantity - l.received_quantity, 0)) AS quantity
+    FROM purchase_order_lines l
+    JOIN purchase_orders po ON po.purchase_order_id = l.purchase_order_id
+    WHERE l.product_id = p.product_id AND po.status IN ('draft', 'sent', 'partially_received')
+) on_order ON TRUE
+LEFT JOIN LATERAL (
+    SELECT SUM(od.quantity) AS quantity
+    FROM order_details od
+    JOIN orders o ON o.order_id = od.order_id
+    WHERE od.product_id = p.product_id AND o.status = 'backordered' AND od.backordered
+) backordered ON TRUE
+WHERE p.deleted_at IS NULL
+ORDER BY s.supplier_id, p.product_id;
@@ -0,0 +1,3 @@
+INSERT INTO reservations (product_id, warehouse_id, customer_id, quantity, price, reference, status, expires_at, created_by, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, 'active', CURRENT_TIMESTAMP + $7 * INTERVAL '1 second', $8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+RETURNING reservation_id, status, expires_at, created_at, updated_at;
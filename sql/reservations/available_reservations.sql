@@ -0,0 +1,9 @@
+SELECT ws.quantity - COALESCE((
+    SELECT SUM(r.quantity)
+    FROM reservations r
+    WHERE r.product_id = ws.product_id AND r.warehouse_id = ws.warehouse_id
+      AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+), 0)
+FROM warehouse_stock ws
+WHERE ws.warehouse_id = $1 AND ws.product_id = $2
+FOR UPDATE OF ws;
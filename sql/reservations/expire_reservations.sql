@@ -0,0 +1,4 @@
+UPDATE reservations
+SET status = 'expired',
+    updated_at = CURRENT_TIMESTAMP
+WHERE status = 'active' AND expires_at <= CURRENT_TIMESTAMP;
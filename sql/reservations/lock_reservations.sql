@@ -0,0 +1,5 @@
+SELECT r.product_id, r.warehouse_id, r.customer_id, r.quantity, COALESCE(r.price, p.price), r.status, r.expires_at <= CURRENT_TIMESTAMP
+FROM reservations r
+JOIN products p ON p.product_id = r.product_id
+WHERE r.reservation_id = $1
+FOR UPDATE OF r;
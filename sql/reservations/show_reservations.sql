@@ -0,0 +1,11 @@
+SELECT r.reservation_id, r.product_id, p.name, r.warehouse_id, w.name, r.customer_id, r.quantity, r.price, r.reference,
+       CASE WHEN r.status = 'active' AND r.expires_at <= CURRENT_TIMESTAMP THEN 'expired' ELSE r.status END AS status,
+       r.expires_at, r.order_id, r.created_by, r.created_at, r.updated_at
+FROM reservations r
+JOIN products p ON p.product_id = r.product_id
+JOIN warehouses w ON w.warehouse_id = r.warehouse_id
+WHERE ($1::INT IS NULL OR r.product_id = $1)
+  AND ($2::INT IS NULL OR r.warehouse_id = $2)
+  AND ($3::INT IS NULL OR r.customer_id = $3)
+  AND ($4::TEXT = '' OR (CASE WHEN r.status = 'active' AND r.expires_at <= CURRENT_TIMESTAMP THEN 'expired' ELSE r.status END) = $4)
+ORDER BY r.reservation_id DESC;
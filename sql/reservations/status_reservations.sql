@@ -0,0 +1,5 @@
+UPDATE reservations
+SET status = $2,
+    order_id = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE reservation_id = $1;
@@ -0,0 +1,2 @@
+INSERT INTO serial_number_events (serial_number_id, event_type, status, warehouse_id, reference_type, reference_id, created_by, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP);
@@ -0,0 +1,7 @@
+UPDATE serial_numbers
+SET status = $4,
+    warehouse_id = $5,
+    order_detail_id = $6,
+    updated_at = CURRENT_TIMESTAMP
+WHERE product_id = $1 AND serial_number = $2 AND status = 'in_stock' AND warehouse_id = $3
+RETURNING serial_number_id;
@@ -0,0 +1,9 @@
+INSERT INTO serial_numbers (product_id, serial_number, status, warehouse_id, created_at, updated_at)
+VALUES ($1, $2, 'in_stock', $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (product_id, serial_number) DO UPDATE
+SET status = 'in_stock',
+    warehouse_id = EXCLUDED.warehouse_id,
+    order_detail_id = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE serial_numbers.status <> 'in_stock'
+RETURNING serial_number_id;
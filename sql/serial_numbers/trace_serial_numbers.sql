@@ -0,0 +1,15 @@
+SELECT s.serial_number_id, s.product_id, p.name, s.serial_number, s.status, s.warehouse_id, w.name,
+       od.order_id, o.customer_id, c.name, s.created_at, s.updated_at,
+       e.event_type, e.status, e.warehouse_id, ew.name, e.reference_type, e.reference_id, eo.customer_id, e.created_by, e.created_at
+FROM serial_numbers s
+JOIN products p ON p.product_id = s.product_id
+LEFT JOIN warehouses w ON w.warehouse_id = s.warehouse_id
+LEFT JOIN order_details od ON od.order_detail_id = s.order_detail_id
+LEFT JOIN orders o ON o.order_id = od.order_id
+LEFT JOIN customers c ON c.customer_id = o.customer_id
+JOIN serial_number_events e ON e.serial_number_id = s.serial_number_id
+LEFT JOIN warehouses ew ON ew.warehouse_id = e.warehouse_id
+LEFT JOIN orders eo ON eo.order_id = e.reference_id AND e.reference_type = 'order'
+WHERE s.serial_number = $1
+  AND ($2::INT IS NULL OR s.product_id = $2)
+ORDER BY s.product_id, s.serial_number_id, e.created_at, e.serial_number_event_id;
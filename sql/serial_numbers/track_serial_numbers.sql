@@ -0,0 +1 @@
+SELECT track_serials FROM products WHERE product_id = $1;
@@ -0,0 +1,2 @@
+INSERT INTO stock_movements (product_id, quantity_change, movement_type, reason, reference_type, reference_id, created_by, warehouse_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP) RETURNING stock_movement_id;
@@ -0,0 +1,5 @@
+SELECT p.product_id, p.name, p.quantity,
+       COALESCE((SELECT SUM(m.quantity_change) FROM stock_movements m WHERE m.product_id = p.product_id), 0) AS ledger_quantity
+FROM products p
+WHERE p.quantity <> COALESCE((SELECT SUM(m.quantity_change) FROM stock_movements m WHERE m.product_id = p.product_id), 0)
+ORDER BY p.product_id;
@@ -0,0 +1,24 @@
+WITH unpicked AS (
+    SELECT od.warehouse_id, od.product_id, SUM(od.quantity) AS quantity
+    FROM order_details od
+    JOIN orders o ON o.order_id = od.order_id
+    WHERE o.picked_at IS NULL AND o.status NOT IN ('refunded', 'cancelled', 'backordered') AND NOT od.backordered
+    GROUP BY od.warehouse_id, od.product_id
+), binned AS (
+    SELECT bs.bin_location_id, bs.product_id, bs.quantity,
+           SUM(bs.quantity) OVER (PARTITION BY b.warehouse_id, bs.product_id
+                                  ORDER BY b.pick_sequence, b.zone, b.aisle, b.shelf, b.bin, b.bin_location_id) AS running,
+           SUM(bs.quantity) OVER (PARTITION BY b.warehouse_id, bs.product_id)
+               - GREATEST(COALESCE(ws.quantity, 0), 0) - COALESCE(u.quantity, 0) AS excess
+    FROM bin_stock bs
+    JOIN bin_locations b ON b.bin_location_id = bs.bin_location_id
+    LEFT JOIN warehouse_stock ws ON ws.warehouse_id = b.warehouse_id AND ws.product_id = bs.product_id
+    LEFT JOIN unpicked u ON u.warehouse_id = b.warehouse_id AND u.product_id = bs.product_id
+    WHERE bs.quantity > 0
+)
+UPDATE bin_stock bs
+SET quantity = bs.quantity - LEAST(t.quantity, t.excess - (t.running - t.quantity)),
+    updated_at = CURRENT_TIMESTAMP
+FROM binned t
+WHERE bs.bin_location_id = t.bin_location_id AND bs.product_id = t.product_id
+  AND t.excess > 0 AND t.running - t.quantity < t.excess;
@@ -0,0 +1,13 @@
+WITH lotted AS (
+    SELECT l.lot_id, l.quantity,
+           SUM(l.quantity) OVER (PARTITION BY l.warehouse_id, l.product_id ORDER BY l.expires_at NULLS LAST, l.lot_id) AS running,
+           SUM(l.quantity) OVER (PARTITION BY l.warehouse_id, l.product_id) - GREATEST(COALESCE(ws.quantity, 0), 0) AS excess
+    FROM lots l
+    LEFT JOIN warehouse_stock ws ON ws.warehouse_id = l.warehouse_id AND ws.product_id = l.product_id
+    WHERE l.quantity > 0
+)
+UPDATE lots l
+SET quantity = l.quantity - LEAST(t.quantity, t.excess - (t.running - t.quantity)),
+    updated_at = CURRENT_TIMESTAMP
+FROM lotted t
+WHERE l.lot_id = t.lot_id AND t.excess > 0 AND t.running - t.quantity < t.excess;
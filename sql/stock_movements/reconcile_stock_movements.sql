@@ -0,0 +1,9 @@
+UPDATE products p
+SET quantity = ledger.total,
+    updated_at = CURRENT_TIMESTAMP
+FROM (
+    SELECT p2.product_id,
+           COALESCE((SELECT SUM(m.quantity_change) FROM stock_movements m WHERE m.product_id = p2.product_id), 0) AS total
+    FROM products p2
+) ledger
+WHERE p.product_id = ledger.product_id AND p.quantity <> ledger.total;
@@ -0,0 +1,21 @@
+WITH missing AS (
+    INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+    SELECT m.warehouse_id, m.product_id, SUM(m.quantity_change), CURRENT_TIMESTAMP
+    FROM stock_movements m
+    WHERE NOT EXISTS (
+        SELECT 1 FROM warehouse_stock ws WHERE ws.warehouse_id = m.warehouse_id AND ws.product_id = m.product_id
+    )
+    GROUP BY m.warehouse_id, m.product_id
+    HAVING SUM(m.quantity_change) <> 0
+    RETURNING product_id
+)
+UPDATE warehouse_stock ws
+SET quantity = ledger.total,
+    updated_at = CURRENT_TIMESTAMP
+FROM (
+    SELECT ws2.warehouse_id, ws2.product_id,
+           COALESCE((SELECT SUM(m.quantity_change) FROM stock_movements m
+                     WHERE m.warehouse_id = ws2.warehouse_id AND m.product_id = ws2.product_id), 0) AS total
+    FROM warehouse_stock ws2
+) ledger
+WHERE ws.warehouse_id = ledger.warehouse_id AND ws.product_id = ledger.product_id AND ws.quantity <> ledger.total;
@@ -0,0 +1,9 @@
+SELECT m.stock_movement_id, m.product_id, m.warehouse_id, m.quantity_change, m.balance, m.movement_type, m.reason,
+       m.reference_type, m.reference_id, m.created_by, m.created_at
+FROM (
+    SELECT sm.*,
+           SUM(sm.quantity_change) OVER (PARTITION BY sm.product_id ORDER BY sm.stock_movement_id) AS balance
+    FROM stock_movements sm
+    WHERE ($1::INT IS NULL OR sm.product_id = $1)
+) m
+WHERE TRUE
@@ -0,0 +1,25 @@
+SELECT m.product_id, p.name, p.category_id, COALESCE(c.name, p.category, ''), m.warehouse_id, w.name,
+       m.quantity_change, m.movement_type, m.reference_id,
+       CASE
+           WHEN m.movement_type = 'receipt' AND m.reference_type = 'goods_receipt' THEN (
+               SELECT pol.unit_cost
+               FROM goods_receipt_lines grl
+               JOIN purchase_order_lines pol ON pol.purchase_order_line_id = grl.purchase_order_line_id
+               WHERE grl.goods_receipt_id = m.reference_id AND pol.product_id = m.product_id
+               LIMIT 1
+           )
+           WHEN m.movement_type = 'refund' AND m.reference_type = 'order' THEN (
+               SELECT od.unit_cost
+               FROM order_details od
+               WHERE od.order_id = m.reference_id AND od.product_id = m.product_id AND od.unit_cost IS NOT NULL
+               LIMIT 1
+           )
+       END AS unit_cost,
+       cc.cost_price
+FROM stock_movements m
+JOIN products p ON p.product_id = m.product_id
+LEFT JOIN categories c ON c.category_id = p.category_id
+JOIN warehouses w ON w.warehouse_id = m.warehouse_id
+LEFT JOIN product_current_costs cc ON cc.product_id = m.product_id
+WHERE m.created_at <= $1
+ORDER BY m.product_id, m.stock_movement_id;
@@ -0,0 +1,2 @@
+INSERT INTO supplier_products (supplier_id, product_id, cost_price, supplier_sku, lead_time_days, min_order_quantity, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING supplier_product_id;
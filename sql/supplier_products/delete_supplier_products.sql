@@ -0,0 +1,2 @@
+DELETE FROM supplier_products
+WHERE supplier_product_id = $1;
@@ -0,0 +1,8 @@
+UPDATE supplier_products
+SET
+    cost_price = COALESCE($2, cost_price),
+    supplier_sku = COALESCE($3, supplier_sku),
+    lead_time_days = COALESCE($4, lead_time_days),
+    min_order_quantity = COALESCE($5, min_order_quantity),
+    updated_at = CURRENT_TIMESTAMP
+WHERE supplier_product_id = $1;
@@ -0,0 +1,12 @@
+SELECT sp.supplier_product_id, sp.supplier_id, s.name, sp.product_id, p.name,
+       sp.cost_price, sp.supplier_sku, sp.lead_time_days, sp.min_order_quantity,
+       sp.supplier_id = p.supplier_id AS is_primary,
+       sp.created_at, sp.updated_at
+FROM supplier_products sp
+JOIN suppliers s ON s.supplier_id = sp.supplier_id
+JOIN products p ON p.product_id = sp.product_id
+WHERE ($1::INT IS NULL OR sp.product_id = $1)
+  AND ($2::INT IS NULL OR sp.supplier_id = $2)
+  AND s.deleted_at IS NULL
+  AND p.deleted_at IS NULL
+ORDER BY sp.product_id, is_primary DESC, sp.cost_price, sp.supplier_id;
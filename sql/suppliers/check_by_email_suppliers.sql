@@ -1,2 +1,2 @@
 SELECT supplier_id FROM suppliers
-WHERE contact_email = $1;
\ No newline at end of file
+WHERE contact_email = $1 AND deleted_at IS NULL;
\ No newline at end of file
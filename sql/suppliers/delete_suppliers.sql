@@ -1,2 +1,3 @@
-DELETE FROM suppliers
-WHERE supplier_id = $1;
\ No newline at end of file
+UPDATE suppliers
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE supplier_id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DELETE FROM suppliers s
+WHERE s.deleted_at < $1
+  AND NOT EXISTS (SELECT 1 FROM products p WHERE p.supplier_id = s.supplier_id)
+  AND NOT EXISTS (SELECT 1 FROM purchase_orders po WHERE po.supplier_id = s.supplier_id);
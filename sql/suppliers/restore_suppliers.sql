@@ -0,0 +1,3 @@
+UPDATE suppliers
+SET deleted_at = NULL
+WHERE supplier_id = $1 AND deleted_at IS NOT NULL;
\ No newline at end of file
@@ -4,4 +4,4 @@ SET
     contact_name = COALESCE($3, contact_name),
     contact_email = COALESCE($4, contact_email),
     contact_phone = COALESCE($5, contact_phone)
-WHERE supplier_id = $1;
\ No newline at end of file
+WHERE supplier_id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -1,3 +1,3 @@
-SELECT supplier_id, name, contact_name, contact_email, contact_phone
+SELECT supplier_id, name, contact_name, contact_email, contact_phone, deleted_at
 FROM suppliers
-LIMIT COALESCE($1, 1000);
\ No newline at end of file
+WHERE ($1 OR deleted_at IS NULL)
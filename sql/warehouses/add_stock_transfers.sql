@@ -0,0 +1,2 @@
+INSERT INTO stock_transfers (product_id, from_warehouse_id, to_warehouse_id, quantity, reason, created_by, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP) RETURNING stock_transfer_id;
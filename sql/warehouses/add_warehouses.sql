@@ -0,0 +1,2 @@
+INSERT INTO warehouses (name, address, latitude, longitude, created_at, updated_at)
+VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING warehouse_id;
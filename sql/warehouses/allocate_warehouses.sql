@@ -0,0 +1,29 @@
+SELECT ws.warehouse_id
+FROM warehouse_stock ws
+JOIN warehouses w ON w.warehouse_id = ws.warehouse_id
+WHERE ws.product_id = $1
+  AND ($3::INT IS NULL OR ws.warehouse_id = $3)
+  AND ws.quantity - COALESCE((
+        SELECT SUM(r.quantity)
+        FROM reservations r
+        WHERE r.product_id = ws.product_id AND r.warehouse_id = ws.warehouse_id
+          AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+    ), 0) >= $2
+  AND ($3::INT IS NOT NULL OR ws.quantity - COALESCE((
+        SELECT SUM(r.quantity)
+        FROM reservations r
+        WHERE r.product_id = ws.product_id AND r.warehouse_id = ws.warehouse_id
+          AND r.status = 'active' AND r.expires_at > CURRENT_TIMESTAMP
+    ), 0) - COALESCE((
+        SELECT SUM(l.quantity)
+        FROM lots l
+        WHERE l.product_id = ws.product_id AND l.warehouse_id = ws.warehouse_id AND l.expires_at < CURRENT_DATE
+    ), 0) >= $2)
+ORDER BY
+    POWER(w.latitude::FLOAT8 - $4::FLOAT8, 2)
+        + POWER((w.longitude::FLOAT8 - $5::FLOAT8) * COS(RADIANS((w.latitude::FLOAT8 + $4::FLOAT8) / 2)), 2) NULLS LAST,
+    w.is_default DESC,
+    ws.quantity DESC,
+    ws.warehouse_id
+LIMIT 1
+FOR UPDATE OF ws;
@@ -0,0 +1,4 @@
+UPDATE warehouses
+SET is_default = (warehouse_id = $1),
+    updated_at = CURRENT_TIMESTAMP
+WHERE is_default OR warehouse_id = $1;
@@ -0,0 +1,3 @@
+SELECT warehouse_id
+FROM warehouses
+WHERE ($1::INT IS NULL AND is_default) OR warehouse_id = $1;
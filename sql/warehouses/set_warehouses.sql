@@ -0,0 +1,7 @@
+UPDATE warehouses
+SET name = COALESCE($2, name),
+    address = COALESCE($3, address),
+    latitude = COALESCE($4, latitude),
+    longitude = COALESCE($5, longitude),
+    updated_at = CURRENT_TIMESTAMP
+WHERE warehouse_id = $1;
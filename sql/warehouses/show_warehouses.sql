@@ -0,0 +1,5 @@
+SELECT w.warehouse_id, w.name, w.address, w.latitude, w.longitude, w.is_default,
+       COALESCE((SELECT SUM(ws.quantity) FROM warehouse_stock ws WHERE ws.warehouse_id = w.warehouse_id), 0) AS total_quantity,
+       w.created_at, w.updated_at
+FROM warehouses w
+ORDER BY w.warehouse_id;
@@ -0,0 +1,5 @@
+INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+ON CONFLICT (warehouse_id, product_id) DO UPDATE
+SET quantity = warehouse_stock.quantity + EXCLUDED.quantity,
+    updated_at = CURRENT_TIMESTAMP;
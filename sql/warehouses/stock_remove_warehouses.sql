@@ -0,0 +1,4 @@
+UPDATE warehouse_stock
+SET quantity = quantity - $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE warehouse_id = $1 AND product_id = $2 AND quantity >= $3;
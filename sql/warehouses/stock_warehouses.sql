@@ -0,0 +1,8 @@
+SELECT ws.warehouse_id, w.name, ws.product_id, p.name, ws.quantity, ws.updated_at
+FROM warehouse_stock ws
+JOIN warehouses w ON w.warehouse_id = ws.warehouse_id
+JOIN products p ON p.product_id = ws.product_id
+WHERE ($1::INT IS NULL OR ws.warehouse_id = $1)
+  AND ($2::INT IS NULL OR ws.product_id = $2)
+  AND ws.quantity > 0
+ORDER BY ws.warehouse_id, ws.product_id;
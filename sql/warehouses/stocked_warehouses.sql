@@ -0,0 +1,5 @@
+SELECT warehouse_id
+FROM warehouse_stock
+WHERE product_id = $1 AND quantity > 0
+ORDER BY warehouse_id
+FOR UPDATE;